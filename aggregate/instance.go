@@ -9,6 +9,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/firewall"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/placement"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"gopkg.in/mgo.v2/bson"
 	"sort"
@@ -260,5 +261,28 @@ func GetInstancePaged(db *database.Database, query *bson.M, page,
 		insts = append(insts, inst)
 	}
 
+	groupIds := []bson.ObjectId{}
+	groupIdsSet := set.NewSet()
+	for _, inst := range insts {
+		if inst.PlacementGroup == "" ||
+			groupIdsSet.Contains(inst.PlacementGroup) {
+
+			continue
+		}
+		groupIdsSet.Add(inst.PlacementGroup)
+		groupIds = append(groupIds, inst.PlacementGroup)
+	}
+
+	violations, err := placement.GetViolations(db, groupIds)
+	if err != nil {
+		return
+	}
+
+	for _, inst := range insts {
+		if _, ok := violations[inst.Id]; ok {
+			inst.PlacementViolation = true
+		}
+	}
+
 	return
 }
@@ -9,22 +9,29 @@ import (
 	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 	"gopkg.in/mgo.v2/bson"
 	"strconv"
 	"strings"
 )
 
 type diskData struct {
-	Id           bson.ObjectId `json:"id"`
-	Name         string        `json:"name"`
-	Organization bson.ObjectId `json:"organization"`
-	Instance     bson.ObjectId `json:"instance"`
-	Index        string        `json:"index"`
-	Node         bson.ObjectId `json:"node"`
-	Image        bson.ObjectId `json:"image"`
-	State        string        `json:"state"`
-	Size         int           `json:"size"`
+	Id                bson.ObjectId `json:"id"`
+	Name              string        `json:"name"`
+	Organization      bson.ObjectId `json:"organization"`
+	Instance          bson.ObjectId `json:"instance"`
+	Index             string        `json:"index"`
+	Node              bson.ObjectId `json:"node"`
+	Image             bson.ObjectId `json:"image"`
+	State             string        `json:"state"`
+	Size              int           `json:"size"`
+	SnapshotSchedule  string        `json:"snapshot_schedule"`
+	SnapshotRetention int           `json:"snapshot_retention"`
+	RestoreImage      bson.ObjectId `json:"restore_image"`
 }
 
 type disksMultiData struct {
@@ -66,9 +73,58 @@ func diskPut(c *gin.Context) {
 	dsk.Name = dta.Name
 	dsk.Instance = dta.Instance
 	dsk.Index = dta.Index
+	dsk.SnapshotSchedule = dta.SnapshotSchedule
+	dsk.SnapshotRetention = dta.SnapshotRetention
 
 	if dsk.State == disk.Available && dta.State == disk.Snapshot {
 		dsk.State = disk.Snapshot
+	} else if dsk.State == disk.Available && dta.Size != 0 &&
+		dta.Size != dsk.Size {
+
+		dsk.State = disk.Resize
+		dsk.NewSize = dta.Size
+	} else if dsk.State == disk.RestoreFailed &&
+		dta.State == disk.Available {
+
+		dsk.State = disk.Available
+		dsk.RestoreImage = ""
+	} else if (dsk.State == disk.Available ||
+		dsk.State == disk.RestoreFailed) && dta.State == disk.Restore {
+
+		img, err := image.Get(db, dta.RestoreImage)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if img.Type != storage.Private {
+			errData := &errortypes.ErrorData{
+				Error:   "restore_image_invalid",
+				Message: "Restore image must be a snapshot",
+			}
+			c.JSON(400, errData)
+			return
+		}
+
+		if dsk.Instance != "" {
+			inst, err := instance.Get(db, dsk.Instance)
+			if err != nil {
+				utils.AbortWithError(c, 500, err)
+				return
+			}
+
+			if inst.State != instance.Stop || inst.VmState != vm.Stopped {
+				errData := &errortypes.ErrorData{
+					Error:   "instance_running",
+					Message: "Instance must be stopped to restore disk",
+				}
+				c.JSON(400, errData)
+				return
+			}
+		}
+
+		dsk.State = disk.Restore
+		dsk.RestoreImage = img.Id
 	}
 
 	fields := set.NewSet(
@@ -76,6 +132,10 @@ func diskPut(c *gin.Context) {
 		"name",
 		"instance",
 		"index",
+		"new_size",
+		"restore_image",
+		"snapshot_schedule",
+		"snapshot_retention",
 	)
 
 	errData, err := dsk.Validate(db)
@@ -117,13 +177,15 @@ func diskPost(c *gin.Context) {
 	}
 
 	dsk := &disk.Disk{
-		Name:         dta.Name,
-		Organization: dta.Organization,
-		Instance:     dta.Instance,
-		Index:        dta.Index,
-		Node:         dta.Node,
-		Image:        dta.Image,
-		Size:         dta.Size,
+		Name:              dta.Name,
+		Organization:      dta.Organization,
+		Instance:          dta.Instance,
+		Index:             dta.Index,
+		Node:              dta.Node,
+		Image:             dta.Image,
+		Size:              dta.Size,
+		SnapshotSchedule:  dta.SnapshotSchedule,
+		SnapshotRetention: dta.SnapshotRetention,
 	}
 
 	errData, err := dsk.Validate(db)
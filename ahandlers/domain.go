@@ -15,12 +15,19 @@ import (
 )
 
 type domainData struct {
-	Id           bson.ObjectId `json:"id"`
-	Name         string        `json:"name"`
-	Organization bson.ObjectId `json:"organization"`
-	Type         string        `json:"type"`
-	AwsId        string        `json:"aws_id"`
-	AwsSecret    string        `json:"aws_secret"`
+	Id              bson.ObjectId `json:"id"`
+	Name            string        `json:"name"`
+	Organization    bson.ObjectId `json:"organization"`
+	Type            string        `json:"type"`
+	AwsId           string        `json:"aws_id"`
+	AwsSecret       string        `json:"aws_secret"`
+	CloudflareToken string        `json:"cloudflare_token"`
+	Rfc2136Server   string        `json:"rfc2136_server"`
+	TsigName        string        `json:"tsig_name"`
+	TsigAlgorithm   string        `json:"tsig_algorithm"`
+	TsigSecret      string        `json:"tsig_secret"`
+	PowerDnsUrl     string        `json:"powerdns_url"`
+	PowerDnsKey     string        `json:"powerdns_key"`
 }
 
 type domainsData struct {
@@ -59,6 +66,13 @@ func domainPut(c *gin.Context) {
 	domn.Type = data.Type
 	domn.AwsId = data.AwsId
 	domn.AwsSecret = data.AwsSecret
+	domn.CloudflareToken = data.CloudflareToken
+	domn.Rfc2136Server = data.Rfc2136Server
+	domn.TsigName = data.TsigName
+	domn.TsigAlgorithm = data.TsigAlgorithm
+	domn.TsigSecret = data.TsigSecret
+	domn.PowerDnsUrl = data.PowerDnsUrl
+	domn.PowerDnsKey = data.PowerDnsKey
 
 	fields := set.NewSet(
 		"name",
@@ -66,6 +80,13 @@ func domainPut(c *gin.Context) {
 		"type",
 		"aws_id",
 		"aws_secret",
+		"cloudflare_token",
+		"rfc2136_server",
+		"tsig_name",
+		"tsig_algorithm",
+		"tsig_secret",
+		"powerdns_url",
+		"powerdns_key",
 	)
 
 	errData, err := domn.Validate(db)
@@ -107,11 +128,18 @@ func domainPost(c *gin.Context) {
 	}
 
 	domn := &domain.Domain{
-		Name:         data.Name,
-		Organization: data.Organization,
-		Type:         data.Type,
-		AwsId:        data.AwsId,
-		AwsSecret:    data.AwsSecret,
+		Name:            data.Name,
+		Organization:    data.Organization,
+		Type:            data.Type,
+		AwsId:           data.AwsId,
+		AwsSecret:       data.AwsSecret,
+		CloudflareToken: data.CloudflareToken,
+		Rfc2136Server:   data.Rfc2136Server,
+		TsigName:        data.TsigName,
+		TsigAlgorithm:   data.TsigAlgorithm,
+		TsigSecret:      data.TsigSecret,
+		PowerDnsUrl:     data.PowerDnsUrl,
+		PowerDnsKey:     data.PowerDnsKey,
 	}
 
 	errData, err := domn.Validate(db)
@@ -20,6 +20,8 @@ type firewallData struct {
 	Organization bson.ObjectId    `json:"organization"`
 	NetworkRoles []string         `json:"network_roles"`
 	Ingress      []*firewall.Rule `json:"ingress"`
+	Egress       []*firewall.Rule `json:"egress"`
+	LogDrop      bool             `json:"log_drop"`
 }
 
 type firewallsData struct {
@@ -57,6 +59,8 @@ func firewallPut(c *gin.Context) {
 	fire.Organization = data.Organization
 	fire.NetworkRoles = data.NetworkRoles
 	fire.Ingress = data.Ingress
+	fire.Egress = data.Egress
+	fire.LogDrop = data.LogDrop
 
 	fields := set.NewSet(
 		"state",
@@ -64,6 +68,8 @@ func firewallPut(c *gin.Context) {
 		"organization",
 		"network_roles",
 		"ingress",
+		"egress",
+		"log_drop",
 	)
 
 	errData, err := fire.Validate(db)
@@ -109,6 +115,8 @@ func firewallPost(c *gin.Context) {
 		Organization: data.Organization,
 		NetworkRoles: data.NetworkRoles,
 		Ingress:      data.Ingress,
+		Egress:       data.Egress,
+		LogDrop:      data.LogDrop,
 	}
 
 	errData, err := fire.Validate(db)
@@ -197,6 +205,12 @@ func firewallGet(c *gin.Context) {
 		return
 	}
 
+	err = fire.LoadCounters(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	c.JSON(200, fire)
 }
 
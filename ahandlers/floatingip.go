@@ -0,0 +1,276 @@
+package ahandlers
+
+import (
+	"fmt"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/floatingip"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"gopkg.in/mgo.v2/bson"
+	"strconv"
+	"strings"
+)
+
+type floatingIpData struct {
+	Id           bson.ObjectId `json:"id"`
+	Name         string        `json:"name"`
+	Organization bson.ObjectId `json:"organization"`
+	Zone         bson.ObjectId `json:"zone"`
+	Node         bson.ObjectId `json:"node"`
+	Instance     bson.ObjectId `json:"instance"`
+	Address      string        `json:"address"`
+	Gateway      string        `json:"gateway"`
+	Address6     string        `json:"address6"`
+	Gateway6     string        `json:"gateway6"`
+}
+
+type floatingIpsData struct {
+	FloatingIps []*floatingip.FloatingIp `json:"floating_ips"`
+	Count       int                      `json:"count"`
+}
+
+func floatingIpPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &floatingIpData{}
+
+	fipId, ok := utils.ParseObjectId(c.Param("fip_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	fip, err := floatingip.Get(db, fipId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	fip.Name = data.Name
+	fip.Organization = data.Organization
+	fip.Zone = data.Zone
+	fip.Node = data.Node
+	fip.Instance = data.Instance
+	fip.Address = data.Address
+	fip.Gateway = data.Gateway
+	fip.Address6 = data.Address6
+	fip.Gateway6 = data.Gateway6
+
+	fields := set.NewSet(
+		"name",
+		"organization",
+		"zone",
+		"node",
+		"instance",
+		"address",
+		"gateway",
+		"address6",
+		"gateway6",
+	)
+
+	errData, err := fip.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = fip.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "floating_ip.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, fip)
+}
+
+func floatingIpPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &floatingIpData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	fip := &floatingip.FloatingIp{
+		Name:         data.Name,
+		Organization: data.Organization,
+		Zone:         data.Zone,
+		Node:         data.Node,
+		Instance:     data.Instance,
+		Address:      data.Address,
+		Gateway:      data.Gateway,
+		Address6:     data.Address6,
+		Gateway6:     data.Gateway6,
+	}
+
+	errData, err := fip.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = fip.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "floating_ip.change")
+
+	c.JSON(200, fip)
+}
+
+func floatingIpDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	fipId, ok := utils.ParseObjectId(c.Param("fip_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := floatingip.Remove(db, fipId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "floating_ip.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, nil)
+}
+
+func floatingIpsDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := []bson.ObjectId{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = floatingip.RemoveMulti(db, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "floating_ip.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, nil)
+}
+
+func floatingIpGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	fipId, ok := utils.ParseObjectId(c.Param("fip_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	fip, err := floatingip.Get(db, fipId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, fip)
+}
+
+func floatingIpsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageCount, _ := strconv.Atoi(c.Query("page_count"))
+
+	query := bson.M{}
+
+	fipId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = fipId
+	}
+
+	address := strings.TrimSpace(c.Query("address"))
+	if address != "" {
+		query["address"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", address),
+			"$options": "i",
+		}
+	}
+
+	organization, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["organization"] = organization
+	}
+
+	zone, ok := utils.ParseObjectId(c.Query("zone"))
+	if ok {
+		query["zone"] = zone
+	}
+
+	nde, ok := utils.ParseObjectId(c.Query("node"))
+	if ok {
+		query["node"] = nde
+	}
+
+	inst, ok := utils.ParseObjectId(c.Query("instance"))
+	if ok {
+		query["instance"] = inst
+	}
+
+	fips, count, err := floatingip.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &floatingIpsData{
+		FloatingIps: fips,
+		Count:       count,
+	}
+
+	c.JSON(200, data)
+}
@@ -90,6 +90,13 @@ func Register(engine *gin.Engine) {
 	csrfGroup.DELETE("/firewall", firewallsDelete)
 	csrfGroup.DELETE("/firewall/:firewall_id", firewallDelete)
 
+	csrfGroup.GET("/floating_ip", floatingIpsGet)
+	csrfGroup.GET("/floating_ip/:fip_id", floatingIpGet)
+	csrfGroup.PUT("/floating_ip/:fip_id", floatingIpPut)
+	csrfGroup.POST("/floating_ip", floatingIpPost)
+	csrfGroup.DELETE("/floating_ip", floatingIpsDelete)
+	csrfGroup.DELETE("/floating_ip/:fip_id", floatingIpDelete)
+
 	csrfGroup.GET("/image", imagesGet)
 	csrfGroup.GET("/image/:image_id", imageGet)
 	csrfGroup.PUT("/image/:image_id", imagePut)
@@ -100,6 +107,7 @@ func Register(engine *gin.Engine) {
 	csrfGroup.PUT("/instance", instancesPut)
 	csrfGroup.GET("/instance/:instance_id", instanceGet)
 	csrfGroup.PUT("/instance/:instance_id", instancePut)
+	csrfGroup.GET("/instance/:instance_id/console", instanceConsoleGet)
 	csrfGroup.POST("/instance", instancePost)
 	csrfGroup.DELETE("/instance", instancesDelete)
 	csrfGroup.DELETE("/instance/:instance_id", instanceDelete)
@@ -116,10 +124,18 @@ func Register(engine *gin.Engine) {
 
 	csrfGroup.GET("/organization", organizationsGet)
 	csrfGroup.GET("/organization/:org_id", organizationGet)
+	csrfGroup.GET("/organization/:org_id/usage", organizationUsageGet)
 	csrfGroup.PUT("/organization/:org_id", organizationPut)
 	csrfGroup.POST("/organization", organizationPost)
 	csrfGroup.DELETE("/organization/:org_id", organizationDelete)
 
+	csrfGroup.GET("/placement_group", placementGroupsGet)
+	csrfGroup.GET("/placement_group/:group_id", placementGroupGet)
+	csrfGroup.PUT("/placement_group/:group_id", placementGroupPut)
+	csrfGroup.POST("/placement_group", placementGroupPost)
+	csrfGroup.DELETE("/placement_group", placementGroupsDelete)
+	csrfGroup.DELETE("/placement_group/:group_id", placementGroupDelete)
+
 	csrfGroup.GET("/policy", policiesGet)
 	csrfGroup.GET("/policy/:policy_id", policyGet)
 	csrfGroup.PUT("/policy/:policy_id", policyPut)
@@ -142,6 +158,13 @@ func Register(engine *gin.Engine) {
 	csrfGroup.GET("/subscription/update", subscriptionUpdateGet)
 	csrfGroup.POST("/subscription", subscriptionPost)
 
+	csrfGroup.GET("/template", templatesGet)
+	csrfGroup.GET("/template/:template_id", templateGet)
+	csrfGroup.PUT("/template/:template_id", templatePut)
+	csrfGroup.POST("/template", templatePost)
+	csrfGroup.DELETE("/template", templatesDelete)
+	csrfGroup.DELETE("/template/:template_id", templateDelete)
+
 	csrfGroup.PUT("/theme", themePut)
 
 	csrfGroup.GET("/user", usersGet)
@@ -152,11 +175,18 @@ func Register(engine *gin.Engine) {
 
 	csrfGroup.GET("/vpc", vpcsGet)
 	csrfGroup.GET("/vpc/:vpc_id", vpcGet)
+	csrfGroup.GET("/vpc/:vpc_id/link_history", vpcLinkHistoryGet)
 	csrfGroup.PUT("/vpc/:vpc_id", vpcPut)
 	csrfGroup.POST("/vpc", vpcPost)
 	csrfGroup.DELETE("/vpc", vpcsDelete)
 	csrfGroup.DELETE("/vpc/:vpc_id", vpcDelete)
 
+	csrfGroup.GET("/vpc_peering", peeringsGet)
+	csrfGroup.GET("/vpc_peering/:peering_id", peeringGet)
+	csrfGroup.PUT("/vpc_peering/:peering_id", peeringPut)
+	csrfGroup.POST("/vpc_peering", peeringPost)
+	csrfGroup.DELETE("/vpc_peering/:peering_id", peeringDelete)
+
 	csrfGroup.GET("/zone", zonesGet)
 	csrfGroup.GET("/zone/:zone_id", zoneGet)
 	csrfGroup.PUT("/zone/:zone_id", zonePut)
@@ -191,6 +191,9 @@ func imagesGet(c *gin.Context) {
 		if dc.PrivateStorage != "" {
 			query = &bson.M{
 				"storage": dc.PrivateStorage,
+				"deleted": &bson.M{
+					"$ne": true,
+				},
 			}
 
 			images2, err := image.GetAllNames(db, query)
@@ -245,6 +248,10 @@ func imagesGet(c *gin.Context) {
 			query["organization"] = organization
 		}
 
+		query["deleted"] = &bson.M{
+			"$ne": true,
+		}
+
 		images, count, err := image.GetAll(db, &query, page, pageCount)
 		if err != nil {
 			utils.AbortWithError(c, 500, err)
@@ -5,32 +5,88 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/pritunl-cloud/aggregate"
+	"github.com/pritunl/pritunl-cloud/console"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/placement"
+	"github.com/pritunl/pritunl-cloud/scheduler"
+	"github.com/pritunl/pritunl-cloud/template"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/zone"
 	"gopkg.in/mgo.v2/bson"
 	"strconv"
 	"strings"
 )
 
 type instanceData struct {
-	Id           bson.ObjectId `json:"id"`
-	Organization bson.ObjectId `json:"organization"`
-	Zone         bson.ObjectId `json:"zone"`
-	Vpc          bson.ObjectId `json:"vpc"`
-	Node         bson.ObjectId `json:"node"`
-	Image        bson.ObjectId `json:"image"`
-	Domain       bson.ObjectId `json:"domain"`
-	Name         string        `json:"name"`
-	State        string        `json:"state"`
-	InitDiskSize int           `json:"init_disk_size"`
-	Memory       int           `json:"memory"`
-	Processors   int           `json:"processors"`
-	NetworkRoles []string      `json:"network_roles"`
-	Count        int           `json:"count"`
+	Id             bson.ObjectId   `json:"id"`
+	Organization   bson.ObjectId   `json:"organization"`
+	Zone           bson.ObjectId   `json:"zone"`
+	Vpc            bson.ObjectId   `json:"vpc"`
+	SecondaryVpcs  []bson.ObjectId `json:"secondary_vpcs"`
+	Node           bson.ObjectId   `json:"node"`
+	Image          bson.ObjectId   `json:"image"`
+	Domain         bson.ObjectId   `json:"domain"`
+	PlacementGroup bson.ObjectId   `json:"placement_group"`
+	Name           string          `json:"name"`
+	State          string          `json:"state"`
+	InitDiskSize   int             `json:"init_disk_size"`
+	Memory         int             `json:"memory"`
+	Processors     int             `json:"processors"`
+	NetworkRoles   []string        `json:"network_roles"`
+	Vnc            bool            `json:"vnc"`
+	PrivateOnly    bool            `json:"private_only"`
+	AutoRecover    bool            `json:"auto_recover"`
+	UserData       string          `json:"user_data"`
+	DnsServers     []string        `json:"dns_servers"`
+	SearchDomains  []string        `json:"search_domains"`
+	MigrateNode    bson.ObjectId   `json:"migrate_node"`
+	Template       bson.ObjectId   `json:"template"`
+	Scheduler      string          `json:"scheduler"`
+	Count          int             `json:"count"`
+}
+
+func (d *instanceData) applyTemplate(tmpl *template.Template, keys set.Set) {
+	if !keys.Contains("organization") {
+		d.Organization = tmpl.Organization
+	}
+	if !keys.Contains("zone") {
+		d.Zone = tmpl.Zone
+	}
+	if !keys.Contains("vpc") {
+		d.Vpc = tmpl.Vpc
+	}
+	if !keys.Contains("node") {
+		d.Node = tmpl.Node
+	}
+	if !keys.Contains("image") {
+		d.Image = tmpl.Image
+	}
+	if !keys.Contains("domain") {
+		d.Domain = tmpl.Domain
+	}
+	if !keys.Contains("init_disk_size") {
+		d.InitDiskSize = tmpl.InitDiskSize
+	}
+	if !keys.Contains("memory") {
+		d.Memory = tmpl.Memory
+	}
+	if !keys.Contains("processors") {
+		d.Processors = tmpl.Processors
+	}
+	if !keys.Contains("network_roles") {
+		d.NetworkRoles = tmpl.NetworkRoles
+	}
+	if !keys.Contains("vnc") {
+		d.Vnc = tmpl.Vnc
+	}
+	if !keys.Contains("user_data") {
+		d.UserData = tmpl.UserData
+	}
 }
 
 type instanceMultiData struct {
@@ -69,27 +125,69 @@ func instancePut(c *gin.Context) {
 		return
 	}
 
+	if inst.State == instance.Migrate {
+		errData := &errortypes.ErrorData{
+			Error:   "instance_migrating",
+			Message: "Instance cannot be modified while migrating",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
 	inst.PreCommit()
 
 	inst.Name = data.Name
+	inst.PlacementGroup = data.PlacementGroup
 	inst.Vpc = data.Vpc
-	if data.State != "" {
+	inst.SecondaryVpcs = data.SecondaryVpcs
+	if data.State == instance.Migrate {
+		errData, err := inst.SetMigrate(db, data.MigrateNode)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if errData != nil {
+			c.JSON(400, errData)
+			return
+		}
+	} else if data.State != "" {
 		inst.State = data.State
 	}
 	inst.Memory = data.Memory
 	inst.Processors = data.Processors
 	inst.NetworkRoles = data.NetworkRoles
+	inst.Vnc = data.Vnc
+	inst.PrivateOnly = data.PrivateOnly
+	inst.AutoRecover = data.AutoRecover
+	inst.UserData = data.UserData
+	inst.DnsServers = data.DnsServers
+	inst.SearchDomains = data.SearchDomains
 	inst.Domain = data.Domain
 
 	fields := set.NewSet(
 		"name",
 		"vpc",
+		"secondary_vpcs",
 		"state",
 		"restart",
 		"memory",
 		"processors",
 		"network_roles",
+		"vnc",
+		"private_only",
+		"auto_recover",
+		"user_data",
+		"dns_servers",
+		"search_domains",
 		"domain",
+		"placement_group",
+		"migrate_node",
+		"migrate_state",
+		"migrate_addr",
+		"migrate_nbd_addr",
+		"migrate_tls",
+		"migrate_disks",
 	)
 
 	errData, err := inst.Validate(db)
@@ -103,6 +201,24 @@ func instancePut(c *gin.Context) {
 		return
 	}
 
+	lockId, err := zone.ReserveLock(db, inst.Zone)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer zone.ReserveUnlock(db, inst.Zone, lockId)
+
+	errData, err = scheduler.CheckInstance(db, inst)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
 	err = inst.PostCommit(db)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -130,18 +246,63 @@ func instancePost(c *gin.Context) {
 		Name: "New Instance",
 	}
 
-	err := c.Bind(data)
+	keys, err := utils.BindKeys(c, data)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	if data.Template != "" {
+		tmpl, err := template.Get(db, data.Template)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		data.applyTemplate(tmpl, keys)
+	}
+
 	insts := []*instance.Instance{}
 
 	if data.Count == 0 {
 		data.Count = 1
 	}
 
+	lockId, err := zone.ReserveLock(db, data.Zone)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer zone.ReserveUnlock(db, data.Zone, lockId)
+
+	var sch *scheduler.Scheduler
+	if data.Node == "" {
+		schr, errData, err := scheduler.New(db, data.Zone, data.Scheduler)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if errData != nil {
+			c.JSON(400, errData)
+			return
+		}
+
+		sch = schr
+	}
+
+	// Remove the created instances if any replica fails
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+
+		for _, inst := range insts {
+			instance.Remove(db, inst.Id)
+		}
+	}()
+
 	for i := 0; i < data.Count; i++ {
 		name := ""
 		if strings.Contains(data.Name, "%") {
@@ -151,18 +312,53 @@ func instancePost(c *gin.Context) {
 		}
 
 		inst := &instance.Instance{
-			State:        data.State,
-			Organization: data.Organization,
-			Zone:         data.Zone,
-			Vpc:          data.Vpc,
-			Node:         data.Node,
-			Image:        data.Image,
-			Name:         name,
-			InitDiskSize: data.InitDiskSize,
-			Memory:       data.Memory,
-			Processors:   data.Processors,
-			NetworkRoles: data.NetworkRoles,
-			Domain:       data.Domain,
+			State:          data.State,
+			Organization:   data.Organization,
+			Zone:           data.Zone,
+			Vpc:            data.Vpc,
+			SecondaryVpcs:  data.SecondaryVpcs,
+			Node:           data.Node,
+			Image:          data.Image,
+			Name:           name,
+			InitDiskSize:   data.InitDiskSize,
+			Memory:         data.Memory,
+			Processors:     data.Processors,
+			NetworkRoles:   data.NetworkRoles,
+			Vnc:            data.Vnc,
+			PrivateOnly:    data.PrivateOnly,
+			AutoRecover:    data.AutoRecover,
+			UserData:       data.UserData,
+			DnsServers:     data.DnsServers,
+			SearchDomains:  data.SearchDomains,
+			Domain:         data.Domain,
+			PlacementGroup: data.PlacementGroup,
+		}
+
+		if sch != nil {
+			if data.PlacementGroup != "" {
+				filter, errData, err := placement.GetFilter(
+					db, data.Organization, data.PlacementGroup)
+				if err != nil {
+					utils.AbortWithError(c, 500, err)
+					return
+				}
+
+				if errData != nil {
+					c.JSON(400, errData)
+					return
+				}
+
+				sch.Filter = filter
+			}
+
+			inst.DefaultResources()
+
+			nde, errData := sch.Schedule(inst.Processors, inst.Memory)
+			if errData != nil {
+				c.JSON(400, errData)
+				return
+			}
+			inst.Node = nde.Id
 		}
 
 		errData, err := inst.Validate(db)
@@ -176,6 +372,19 @@ func instancePost(c *gin.Context) {
 			return
 		}
 
+		if sch == nil {
+			errData, err = scheduler.CheckInstance(db, inst)
+			if err != nil {
+				utils.AbortWithError(c, 500, err)
+				return
+			}
+
+			if errData != nil {
+				c.JSON(400, errData)
+				return
+			}
+		}
+
 		err = inst.Insert(db)
 		if err != nil {
 			utils.AbortWithError(c, 500, err)
@@ -184,6 +393,7 @@ func instancePost(c *gin.Context) {
 
 		insts = append(insts, inst)
 	}
+	committed = true
 
 	event.PublishDispatch(db, "instance.change")
 
@@ -208,6 +418,15 @@ func instancesPut(c *gin.Context) {
 		return
 	}
 
+	if data.State == instance.Migrate {
+		errData := &errortypes.ErrorData{
+			Error:   "invalid_state",
+			Message: "Migration must be started per instance",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
 	doc := bson.M{
 		"state": data.State,
 	}
@@ -302,6 +521,12 @@ func instanceGet(c *gin.Context) {
 		return
 	}
 
+	err = inst.LoadPlacementViolation(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	if demo.IsDemo() {
 		inst.State = instance.Start
 		inst.VmState = vm.Running
@@ -317,6 +542,46 @@ func instanceGet(c *gin.Context) {
 	c.JSON(200, inst)
 }
 
+func instanceConsoleGet(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	typ := c.Query("type")
+	if typ == "" {
+		typ = console.Serial
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if inst.VmState != vm.Running {
+		errData := &errortypes.ErrorData{
+			Error:   "instance_not_running",
+			Message: "Instance must be running to open console",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = console.Proxy(db, c.Writer, c.Request, inst, typ)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+}
+
 func instancesGet(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 
@@ -32,6 +32,9 @@ type nodeData struct {
 	ForwardedForHeader string          `json:"forwarded_for_header"`
 	Firewall           bool            `json:"firewall"`
 	NetworkRoles       []string        `json:"network_roles"`
+	CpuOvercommit      float64         `json:"cpu_overcommit"`
+	MemoryOvercommit   float64         `json:"memory_overcommit"`
+	Fenced             bool            `json:"fenced"`
 }
 
 type nodesData struct {
@@ -78,6 +81,9 @@ func nodePut(c *gin.Context) {
 	nde.ForwardedForHeader = data.ForwardedForHeader
 	nde.Firewall = data.Firewall
 	nde.NetworkRoles = data.NetworkRoles
+	nde.CpuOvercommit = data.CpuOvercommit
+	nde.MemoryOvercommit = data.MemoryOvercommit
+	nde.Fenced = data.Fenced
 
 	fields := set.NewSet(
 		"name",
@@ -94,6 +100,9 @@ func nodePut(c *gin.Context) {
 		"forwarded_for_header",
 		"firewall",
 		"network_roles",
+		"cpu_overcommit",
+		"memory_overcommit",
+		"fenced",
 	)
 
 	if data.Zone != "" && data.Zone != nde.Zone {
@@ -12,9 +12,17 @@ import (
 )
 
 type organizationData struct {
-	Id    bson.ObjectId `json:"id"`
-	Name  string        `json:"name"`
-	Roles []string      `json:"roles"`
+	Id                bson.ObjectId       `json:"id"`
+	Name              string              `json:"name"`
+	Roles             []string            `json:"roles"`
+	SnapshotSchedule  string              `json:"snapshot_schedule"`
+	SnapshotRetention int                 `json:"snapshot_retention"`
+	Quota             *organization.Quota `json:"quota"`
+}
+
+type organizationUsageData struct {
+	Quota *organization.Quota `json:"quota"`
+	Usage *organization.Usage `json:"usage"`
 }
 
 func organizationPut(c *gin.Context) {
@@ -45,10 +53,16 @@ func organizationPut(c *gin.Context) {
 
 	org.Name = data.Name
 	org.Roles = data.Roles
+	org.SnapshotSchedule = data.SnapshotSchedule
+	org.SnapshotRetention = data.SnapshotRetention
+	org.Quota = data.Quota
 
 	fields := set.NewSet(
 		"name",
 		"roles",
+		"snapshot_schedule",
+		"snapshot_retention",
+		"quota",
 	)
 
 	errData, err := org.Validate(db)
@@ -90,8 +104,11 @@ func organizationPost(c *gin.Context) {
 	}
 
 	org := &organization.Organization{
-		Name:  data.Name,
-		Roles: data.Roles,
+		Name:              data.Name,
+		Roles:             data.Roles,
+		SnapshotSchedule:  data.SnapshotSchedule,
+		SnapshotRetention: data.SnapshotRetention,
+		Quota:             data.Quota,
 	}
 
 	errData, err := org.Validate(db)
@@ -158,6 +175,33 @@ func organizationGet(c *gin.Context) {
 	c.JSON(200, org)
 }
 
+func organizationUsageGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	orgId, ok := utils.ParseObjectId(c.Param("org_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	org, err := organization.Get(db, orgId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usage, err := organization.GetUsage(db, orgId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, &organizationUsageData{
+		Quota: org.Quota,
+		Usage: usage,
+	})
+}
+
 func organizationsGet(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 
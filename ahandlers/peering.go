@@ -0,0 +1,214 @@
+package ahandlers
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type peeringData struct {
+	Id           bson.ObjectId `json:"id"`
+	Name         string        `json:"name"`
+	Vpc          bson.ObjectId `json:"vpc"`
+	PeerVpc      bson.ObjectId `json:"peer_vpc"`
+	Accepted     bool          `json:"accepted"`
+	PeerAccepted bool          `json:"peer_accepted"`
+}
+
+func peeringPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &peeringData{}
+
+	peeringId, ok := utils.ParseObjectId(c.Param("peering_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	peer, err := vpc.GetPeering(db, peeringId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	peer.Name = data.Name
+	peer.Accepted = data.Accepted
+	peer.PeerAccepted = data.PeerAccepted
+
+	fields := set.NewSet(
+		"name",
+		"organization",
+		"peer_organization",
+		"accepted",
+		"peer_accepted",
+		"active",
+	)
+
+	errData, err := peer.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = peer.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "vpc.change")
+
+	c.JSON(200, peer)
+}
+
+func peeringPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &peeringData{
+		Name:         "New Peering",
+		Accepted:     true,
+		PeerAccepted: true,
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	peer := &vpc.Peering{
+		Name:         data.Name,
+		Vpc:          data.Vpc,
+		PeerVpc:      data.PeerVpc,
+		Accepted:     data.Accepted,
+		PeerAccepted: data.PeerAccepted,
+	}
+
+	errData, err := peer.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = peer.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "vpc.change")
+
+	c.JSON(200, peer)
+}
+
+func peeringDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	peeringId, ok := utils.ParseObjectId(c.Param("peering_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := vpc.RemovePeering(db, peeringId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "vpc.change")
+
+	c.JSON(200, nil)
+}
+
+func peeringGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	peeringId, ok := utils.ParseObjectId(c.Param("peering_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	peer, err := vpc.GetPeering(db, peeringId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, peer)
+}
+
+func peeringsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	query := bson.M{}
+
+	vpcId, ok := utils.ParseObjectId(c.Query("vpc"))
+	if ok {
+		query["$or"] = []*bson.M{
+			&bson.M{
+				"vpc": vpcId,
+			},
+			&bson.M{
+				"peer_vpc": vpcId,
+			},
+		}
+	}
+
+	organization, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["$and"] = []*bson.M{
+			&bson.M{
+				"$or": []*bson.M{
+					&bson.M{
+						"organization": organization,
+					},
+					&bson.M{
+						"peer_organization": organization,
+					},
+				},
+			},
+		}
+	}
+
+	peers, err := vpc.GetPeeringAll(db, &query)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, peers)
+}
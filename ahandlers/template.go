@@ -0,0 +1,276 @@
+package ahandlers
+
+import (
+	"fmt"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/template"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"gopkg.in/mgo.v2/bson"
+	"strconv"
+	"strings"
+)
+
+type templateData struct {
+	Id           bson.ObjectId `json:"id"`
+	Name         string        `json:"name"`
+	Organization bson.ObjectId `json:"organization"`
+	Zone         bson.ObjectId `json:"zone"`
+	Vpc          bson.ObjectId `json:"vpc"`
+	Node         bson.ObjectId `json:"node"`
+	Image        bson.ObjectId `json:"image"`
+	Domain       bson.ObjectId `json:"domain"`
+	InitDiskSize int           `json:"init_disk_size"`
+	Memory       int           `json:"memory"`
+	Processors   int           `json:"processors"`
+	NetworkRoles []string      `json:"network_roles"`
+	Vnc          bool          `json:"vnc"`
+	UserData     string        `json:"user_data"`
+}
+
+type templatesData struct {
+	Templates []*template.Template `json:"templates"`
+	Count     int                  `json:"count"`
+}
+
+func templatePut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &templateData{}
+
+	templateId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	tmpl, err := template.Get(db, templateId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	tmpl.Name = data.Name
+	tmpl.Organization = data.Organization
+	tmpl.Zone = data.Zone
+	tmpl.Vpc = data.Vpc
+	tmpl.Node = data.Node
+	tmpl.Image = data.Image
+	tmpl.Domain = data.Domain
+	tmpl.InitDiskSize = data.InitDiskSize
+	tmpl.Memory = data.Memory
+	tmpl.Processors = data.Processors
+	tmpl.NetworkRoles = data.NetworkRoles
+	tmpl.Vnc = data.Vnc
+	tmpl.UserData = data.UserData
+
+	fields := set.NewSet(
+		"name",
+		"organization",
+		"zone",
+		"vpc",
+		"node",
+		"image",
+		"domain",
+		"init_disk_size",
+		"memory",
+		"processors",
+		"network_roles",
+		"vnc",
+		"user_data",
+	)
+
+	errData, err := tmpl.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = tmpl.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, tmpl)
+}
+
+func templatePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &templateData{
+		Name: "New Template",
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	tmpl := &template.Template{
+		Name:         data.Name,
+		Organization: data.Organization,
+		Zone:         data.Zone,
+		Vpc:          data.Vpc,
+		Node:         data.Node,
+		Image:        data.Image,
+		Domain:       data.Domain,
+		InitDiskSize: data.InitDiskSize,
+		Memory:       data.Memory,
+		Processors:   data.Processors,
+		NetworkRoles: data.NetworkRoles,
+		Vnc:          data.Vnc,
+		UserData:     data.UserData,
+	}
+
+	errData, err := tmpl.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = tmpl.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, tmpl)
+}
+
+func templateDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	templateId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := template.Remove(db, templateId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, nil)
+}
+
+func templatesDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := []bson.ObjectId{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = template.RemoveMulti(db, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, nil)
+}
+
+func templateGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	templateId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	tmpl, err := template.Get(db, templateId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, tmpl)
+}
+
+func templatesGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageCount, _ := strconv.Atoi(c.Query("page_count"))
+
+	query := bson.M{}
+
+	templateId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = templateId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	organization, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["organization"] = organization
+	}
+
+	templates, count, err := template.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &templatesData{
+		Templates: templates,
+		Count:     count,
+	}
+
+	c.JSON(200, data)
+}
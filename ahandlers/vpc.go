@@ -15,13 +15,17 @@ import (
 )
 
 type vpcData struct {
-	Id           bson.ObjectId `json:"id"`
-	Name         string        `json:"name"`
-	Network      string        `json:"network"`
-	Organization bson.ObjectId `json:"organization"`
-	Datacenter   bson.ObjectId `json:"datacenter"`
-	Routes       []*vpc.Route  `json:"routes"`
-	LinkUris     []string      `json:"link_uris"`
+	Id            bson.ObjectId   `json:"id"`
+	Name          string          `json:"name"`
+	Network       string          `json:"network"`
+	Organization  bson.ObjectId   `json:"organization"`
+	Datacenter    bson.ObjectId   `json:"datacenter"`
+	Routes        []*vpc.Route    `json:"routes"`
+	LinkUris      []string        `json:"link_uris"`
+	LinkConfig    *vpc.LinkConfig `json:"link_config"`
+	DnsServers    []string        `json:"dns_servers"`
+	SearchDomains []string        `json:"search_domains"`
+	DnsResolver   bool            `json:"dns_resolver"`
 }
 
 type vpcsData struct {
@@ -29,6 +33,11 @@ type vpcsData struct {
 	Count int        `json:"count"`
 }
 
+type linkHistoryData struct {
+	History []*vpc.LinkHistory `json:"history"`
+	Count   int                `json:"count"`
+}
+
 func vpcPut(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -58,12 +67,22 @@ func vpcPut(c *gin.Context) {
 	vc.Name = data.Name
 	vc.Routes = data.Routes
 	vc.LinkUris = data.LinkUris
+	if data.LinkConfig != nil {
+		vc.LinkConfig = data.LinkConfig
+	}
+	vc.DnsServers = data.DnsServers
+	vc.SearchDomains = data.SearchDomains
+	vc.DnsResolver = data.DnsResolver
 
 	fields := set.NewSet(
 		"state",
 		"name",
 		"routes",
 		"link_uris",
+		"link_config",
+		"dns_servers",
+		"search_domains",
+		"dns_resolver",
 	)
 
 	errData, err := vc.Validate(db)
@@ -107,12 +126,16 @@ func vpcPost(c *gin.Context) {
 	}
 
 	vc := &vpc.Vpc{
-		Name:         data.Name,
-		Network:      data.Network,
-		Organization: data.Organization,
-		Datacenter:   data.Datacenter,
-		Routes:       data.Routes,
-		LinkUris:     data.LinkUris,
+		Name:          data.Name,
+		Network:       data.Network,
+		Organization:  data.Organization,
+		Datacenter:    data.Datacenter,
+		Routes:        data.Routes,
+		LinkUris:      data.LinkUris,
+		LinkConfig:    data.LinkConfig,
+		DnsServers:    data.DnsServers,
+		SearchDomains: data.SearchDomains,
+		DnsResolver:   data.DnsResolver,
 	}
 
 	vc.GenerateVpcId()
@@ -275,3 +298,43 @@ func vpcsGet(c *gin.Context) {
 		c.JSON(200, data)
 	}
 }
+
+func vpcLinkHistoryGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	vpcId, ok := utils.ParseObjectId(c.Param("vpc_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageCount, _ := strconv.Atoi(c.Query("page_count"))
+
+	query := bson.M{
+		"vpc": vpcId,
+	}
+
+	lnk := strings.TrimSpace(c.Query("link"))
+	if lnk != "" {
+		query["link"] = lnk
+	}
+
+	status := strings.TrimSpace(c.Query("status"))
+	if status != "" {
+		query["status"] = status
+	}
+
+	hists, count, err := vpc.GetLinkHistoryPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &linkHistoryData{
+		History: hists,
+		Count:   count,
+	}
+
+	c.JSON(200, data)
+}
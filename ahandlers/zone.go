@@ -12,9 +12,12 @@ import (
 )
 
 type zoneData struct {
-	Id            bson.ObjectId   `json:"id"`
-	Datacenter    bson.ObjectId   `json:"datacenter"`
-	Name          string          `json:"name"`
+	Id               bson.ObjectId `json:"id"`
+	Datacenter       bson.ObjectId `json:"datacenter"`
+	Name             string        `json:"name"`
+	CpuOvercommit    float64       `json:"cpu_overcommit"`
+	MemoryOvercommit float64       `json:"memory_overcommit"`
+	SharedStorage    bool          `json:"shared_storage"`
 }
 
 func zonePut(c *gin.Context) {
@@ -44,9 +47,15 @@ func zonePut(c *gin.Context) {
 	}
 
 	zne.Name = data.Name
+	zne.CpuOvercommit = data.CpuOvercommit
+	zne.MemoryOvercommit = data.MemoryOvercommit
+	zne.SharedStorage = data.SharedStorage
 
 	fields := set.NewSet(
 		"name",
+		"cpu_overcommit",
+		"memory_overcommit",
+		"shared_storage",
 	)
 
 	errData, err := zne.Validate(db)
@@ -88,8 +97,11 @@ func zonePost(c *gin.Context) {
 	}
 
 	zne := &zone.Zone{
-		Datacenter:    data.Datacenter,
-		Name:          data.Name,
+		Datacenter:       data.Datacenter,
+		Name:             data.Name,
+		CpuOvercommit:    data.CpuOvercommit,
+		MemoryOvercommit: data.MemoryOvercommit,
+		SharedStorage:    data.SharedStorage,
 	}
 
 	errData, err := zne.Validate(db)
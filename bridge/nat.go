@@ -0,0 +1,146 @@
+package bridge
+
+import (
+	"fmt"
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/iptables"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	natLock = sync.Mutex{}
+)
+
+func getNatPidPath() string {
+	return fmt.Sprintf("/var/run/dnsmasq-%s.pid",
+		settings.Hypervisor.NatBridgeName)
+}
+
+func natDhcpRunning() bool {
+	pidData, _ := ioutil.ReadFile(getNatPidPath())
+	if pidData == nil {
+		return false
+	}
+
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(pidData)))
+	if pid == 0 {
+		return false
+	}
+
+	exists, _ := utils.Exists(fmt.Sprintf("/proc/%d/status", pid))
+	return exists
+}
+
+func ConfigureNat() (bridgeName string, err error) {
+	natLock.Lock()
+	defer natLock.Unlock()
+
+	bridgeName = settings.Hypervisor.NatBridgeName
+
+	gatewayAddr, natNet, err := net.ParseCIDR(settings.Hypervisor.NatNetwork)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bridge: Failed to parse NAT network"),
+		}
+		return
+	}
+
+	natCidr, _ := natNet.Mask.Size()
+
+	startAddr := utils.CopyIpAddress(gatewayAddr)
+	utils.IncIpAddress(startAddr)
+	endAddr := utils.GetLastIpAddress(natNet)
+	utils.DecIpAddress(endAddr)
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"already exists"},
+		"brctl", "addbr", bridgeName,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "addr",
+		"add", fmt.Sprintf("%s/%d", gatewayAddr.String(), natCidr),
+		"dev", bridgeName,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"set", "dev", bridgeName, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"sysctl", "-w", "net.ipv4.ip_forward=1",
+	)
+	if err != nil {
+		return
+	}
+
+	iptables.Lock()
+	_, e := utils.ExecCombinedOutput("",
+		"iptables", "-t", "nat",
+		"-C", "POSTROUTING",
+		"-s", natNet.String(),
+		"!", "-o", bridgeName,
+		"-j", "MASQUERADE",
+	)
+	if e != nil {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"iptables", "-t", "nat",
+			"-A", "POSTROUTING",
+			"-s", natNet.String(),
+			"!", "-o", bridgeName,
+			"-j", "MASQUERADE",
+		)
+	}
+	iptables.Unlock()
+	if err != nil {
+		return
+	}
+
+	if !natDhcpRunning() {
+		logrus.WithFields(logrus.Fields{
+			"bridge":  bridgeName,
+			"network": natNet.String(),
+		}).Info("bridge: Starting NAT bridge DHCP server")
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"dnsmasq",
+			"--conf-file=/dev/null",
+			"--port=0",
+			"--bind-interfaces",
+			"--interface="+bridgeName,
+			"--except-interface=lo",
+			"--dhcp-range="+startAddr.String()+","+endAddr.String()+",12h",
+			"--dhcp-leasefile="+fmt.Sprintf(
+				"/var/lib/misc/dnsmasq-%s.leases", bridgeName),
+			"--pid-file="+getNatPidPath(),
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -85,6 +85,10 @@ func selfCert(parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (
 	return
 }
 
+func GetSelfCert() string {
+	return string(selfCertPem)
+}
+
 func SelfCert() (certPem, keyPem []byte, err error) {
 	if selfCertPem != nil && selfKeyPem != nil {
 		certPem = selfCertPem
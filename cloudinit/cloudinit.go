@@ -10,6 +10,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/resolver"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/vpc"
@@ -33,22 +34,23 @@ MIME-Version: 1.0
 
 const netConfigTmpl = `version: 1
 config:
-  - type: physical
-    name: eth0
+{{range .Adapters}}  - type: physical
+    name: {{.Name}}
     mac_address: {{.Mac}}
     subnets:
       - type: static
         address: {{.Address}}
         netmask: {{.Netmask}}
         network: {{.Network}}
-        gateway: {{.Gateway}}
-        dns_nameservers:
-          - 8.8.8.8
-          - 8.8.4.4
-      - type: static
+{{if .Gateway}}        gateway: {{.Gateway}}
+{{end}}{{if .DnsServers}}        dns_nameservers:
+{{range .DnsServers}}          - {{.}}
+{{end}}{{end}}{{if .SearchDomains}}        dns_search:
+{{range .SearchDomains}}          - {{.}}
+{{end}}{{end}}      - type: static
         address: {{.Address6}}
-        gateway: {{.Gateway6}}
-`
+{{if .Gateway6}}        gateway: {{.Gateway6}}
+{{end}}{{end}}`
 
 const cloudConfigTmpl = `#cloud-config
 ssh_deletekeys: false
@@ -73,6 +75,8 @@ users:
 const cloudScriptTmpl = `#!/bin/bash
 %s`
 
+const userMergeType = "list(append)+dict(no_replace,recurse_list)+str()"
+
 const teeTmpl = `sudo tee %s << EOF
 %s
 EOF
@@ -84,13 +88,20 @@ var (
 )
 
 type netConfigData struct {
-	Mac      string
-	Address  string
-	Netmask  string
-	Network  string
-	Gateway  string
-	Address6 string
-	Gateway6 string
+	Adapters []*netConfigAdapter
+}
+
+type netConfigAdapter struct {
+	Name          string
+	Mac           string
+	Address       string
+	Netmask       string
+	Network       string
+	Gateway       string
+	Address6      string
+	Gateway6      string
+	DnsServers    []string
+	SearchDomains []string
 }
 
 type cloudConfigData struct {
@@ -106,63 +117,76 @@ func getUserData(db *database.Database, inst *instance.Instance,
 		return
 	}
 
-	if len(authrs) == 0 {
+	if len(authrs) == 0 && inst.UserData == "" {
 		return
 	}
 
-	trusted := ""
-	principals := ""
-	cloudScript := ""
+	items := []string{}
 
-	data := cloudConfigData{
-		Keys: []string{},
-	}
+	if len(authrs) != 0 {
+		trusted := ""
+		principals := ""
+		cloudScript := ""
 
-	for _, authr := range authrs {
-		switch authr.Type {
-		case authority.SshKey:
-			for _, key := range strings.Split(authr.Key, "\n") {
-				data.Keys = append(data.Keys, key)
+		data := cloudConfigData{
+			Keys: []string{},
+		}
+
+		for _, authr := range authrs {
+			switch authr.Type {
+			case authority.SshKey:
+				for _, key := range strings.Split(authr.Key, "\n") {
+					data.Keys = append(data.Keys, key)
+				}
+				break
+			case authority.SshCertificate:
+				trusted += authr.Certificate + "\n"
+				principals += strings.Join(authr.Roles, "\n") + "\n"
+				break
 			}
-			break
-		case authority.SshCertificate:
-			trusted += authr.Certificate + "\n"
-			principals += strings.Join(authr.Roles, "\n") + "\n"
-			break
 		}
-	}
 
-	items := []string{}
+		output := &bytes.Buffer{}
+		err = cloudConfig.Execute(output, data)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "cloudinit: Failed to exec cloud template"),
+			}
+			return
+		}
+		items = append(items, output.String())
 
-	output := &bytes.Buffer{}
-	err = cloudConfig.Execute(output, data)
-	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "cloudinit: Failed to exec cloud template"),
+		if trusted != "" {
+			cloudScript += fmt.Sprintf(teeTmpl, "/etc/ssh/trusted", trusted)
+		}
+		if principals != "" {
+			cloudScript += fmt.Sprintf(
+				teeTmpl, "/etc/ssh/principals", principals)
 		}
-		return
-	}
-	items = append(items, output.String())
 
-	if trusted != "" {
-		cloudScript += fmt.Sprintf(teeTmpl, "/etc/ssh/trusted", trusted)
-	}
-	if principals != "" {
-		cloudScript += fmt.Sprintf(teeTmpl, "/etc/ssh/principals", principals)
+		if cloudScript != "" {
+			items = append(items, fmt.Sprintf(cloudScriptTmpl, cloudScript))
+		}
 	}
 
-	if cloudScript != "" {
-		items = append(items, fmt.Sprintf(cloudScriptTmpl, cloudScript))
+	userItem := -1
+	if inst.UserData != "" {
+		userItem = len(items)
+		items = append(items, inst.UserData)
 	}
 
 	buffer := &bytes.Buffer{}
 	message := multipart.NewWriter(buffer)
-	for _, item := range items {
+	for i, item := range items {
 		header := textproto.MIMEHeader{}
 
 		header.Set("Content-Transfer-Encoding", "base64")
 		header.Set("MIME-Version", "1.0")
 
+		if i == userItem {
+			header.Set("Merge-Type", userMergeType)
+		}
+
 		if strings.HasPrefix(item, "#!") {
 			header.Set("Content-Type",
 				"text/x-shellscript; charset=\"utf-8\"")
@@ -206,37 +230,29 @@ func getUserData(db *database.Database, inst *instance.Instance,
 	return
 }
 
-func getNetData(db *database.Database, inst *instance.Instance,
-	virt *vm.VirtualMachine) (netData string, err error) {
+func getNetAdapter(db *database.Database, inst *instance.Instance,
+	vc *vpc.Vpc, primary bool) (adapter *netConfigAdapter, err error) {
 
-	if len(virt.NetworkAdapters) == 0 {
-		err = &errortypes.NotFoundError{
-			errors.Wrap(err, "cloudinit: Instance missing network adapters"),
-		}
-		return
-	}
-
-	adapter := virt.NetworkAdapters[0]
-
-	if adapter.VpcId == "" {
-		err = &errortypes.NotFoundError{
-			errors.Wrap(err, "cloudinit: Instance missing VPC"),
-		}
+	vcNet, err := vc.GetNetwork()
+	if err != nil {
 		return
 	}
 
-	vc, err := vpc.Get(db, adapter.VpcId)
+	addr, err := vc.GetIp(db, vpc.Instance, inst.Id)
 	if err != nil {
 		return
 	}
 
-	vcNet, err := vc.GetNetwork()
-	if err != nil {
-		return
+	addr6 := vc.GetIp6(addr)
+
+	adapter = &netConfigAdapter{
+		Address:  addr.String(),
+		Netmask:  net.IP(vcNet.Mask).String(),
+		Network:  vcNet.IP.String(),
+		Address6: addr6.String(),
 	}
 
-	addr, err := vc.GetIp(db, vpc.Instance, inst.Id)
-	if err != nil {
+	if !primary {
 		return
 	}
 
@@ -245,24 +261,82 @@ func getNetData(db *database.Database, inst *instance.Instance,
 		return
 	}
 
-	addr6 := vc.GetIp6(addr)
-	if err != nil {
-		return
+	gatewayAddr6 := vc.GetIp6(gatewayAddr)
+
+	dnsServers := inst.DnsServers
+	if len(dnsServers) == 0 && vc.DnsResolver {
+		resolverAddr, e := vc.GetIp(db, vpc.Gateway, vc.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		dnsServers = []string{resolverAddr.String()}
+	}
+	if len(dnsServers) == 0 {
+		dnsServers = vc.DnsServers
+	}
+	if len(dnsServers) == 0 {
+		dnsServers = vpc.DefaultDnsServers
 	}
 
-	gatewayAddr6 := vc.GetIp6(gatewayAddr)
-	if err != nil {
+	searchDomains := inst.SearchDomains
+	if len(searchDomains) == 0 {
+		searchDomains = vc.SearchDomains
+	}
+	if len(searchDomains) == 0 && vc.DnsResolver {
+		domain := resolver.GetDomain(vc.Name)
+		if domain != "" {
+			searchDomains = []string{domain}
+		}
+	}
+
+	adapter.Gateway = gatewayAddr.String()
+	adapter.Gateway6 = gatewayAddr6.String()
+	adapter.DnsServers = dnsServers
+	adapter.SearchDomains = searchDomains
+
+	return
+}
+
+func getNetData(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine) (netData string, err error) {
+
+	if len(virt.NetworkAdapters) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.Wrap(err, "cloudinit: Instance missing network adapters"),
+		}
 		return
 	}
 
 	data := netConfigData{
-		Mac:      adapter.MacAddress,
-		Address:  addr.String(),
-		Netmask:  net.IP(vcNet.Mask).String(),
-		Network:  vcNet.IP.String(),
-		Gateway:  gatewayAddr.String(),
-		Address6: addr6.String(),
-		Gateway6: gatewayAddr6.String(),
+		Adapters: []*netConfigAdapter{},
+	}
+
+	for n, adapter := range virt.NetworkAdapters {
+		if adapter.VpcId == "" {
+			err = &errortypes.NotFoundError{
+				errors.Wrap(err, "cloudinit: Instance missing VPC"),
+			}
+			return
+		}
+
+		vc, e := vpc.Get(db, adapter.VpcId)
+		if e != nil {
+			err = e
+			return
+		}
+
+		adapterData, e := getNetAdapter(db, inst, vc, n == 0)
+		if e != nil {
+			err = e
+			return
+		}
+
+		adapterData.Name = fmt.Sprintf("eth%d", n)
+		adapterData.Mac = adapter.MacAddress
+
+		data.Adapters = append(data.Adapters, adapterData)
 	}
 
 	output := &bytes.Buffer{}
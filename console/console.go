@@ -0,0 +1,370 @@
+package console
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gorilla/websocket"
+	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	upgrader = websocket.Upgrader{
+		HandshakeTimeout: 30 * time.Second,
+		ReadBufferSize:   bufferSize,
+		WriteBufferSize:  bufferSize,
+		Subprotocols:     []string{"binary"},
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+	}
+)
+
+type wsConn struct {
+	conn   *websocket.Conn
+	reader io.Reader
+}
+
+func (w *wsConn) Read(p []byte) (n int, err error) {
+	for {
+		if w.reader == nil {
+			_, w.reader, err = w.conn.NextReader()
+			if err != nil {
+				return
+			}
+		}
+
+		n, err = w.reader.Read(p)
+		if err == io.EOF {
+			w.reader = nil
+			if n > 0 {
+				err = nil
+				return
+			}
+			continue
+		}
+
+		return
+	}
+}
+
+func (w *wsConn) Write(p []byte) (n int, err error) {
+	w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	err = w.conn.WriteMessage(websocket.BinaryMessage, p)
+	if err != nil {
+		return
+	}
+	n = len(p)
+
+	return
+}
+
+func (w *wsConn) Close() error {
+	return w.conn.Close()
+}
+
+func getSockPath(instId bson.ObjectId, typ string) (
+	sockPath string, err error) {
+
+	switch typ {
+	case Serial:
+		sockPath = paths.GetSerialPath(instId)
+		break
+	case Vnc:
+		sockPath = paths.GetVncPath(instId)
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("console: Unknown console type %s", typ),
+		}
+		break
+	}
+
+	return
+}
+
+func dial(instId bson.ObjectId, typ string) (
+	conn io.ReadWriteCloser, err error) {
+
+	sockPath, err := getSockPath(instId, typ)
+	if err != nil {
+		return
+	}
+
+	conn, err = net.DialTimeout(
+		"unix",
+		sockPath,
+		3*time.Second,
+	)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "console: Failed to open socket"),
+		}
+		return
+	}
+
+	return
+}
+
+func getNodePins(db *database.Database, nde *node.Node) (
+	pins [][]byte, err error) {
+
+	pins = [][]byte{}
+	certPems := []string{}
+
+	for _, certId := range nde.Certificates {
+		cert, e := certificate.Get(db, certId)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				continue
+			}
+			err = e
+			return
+		}
+
+		certPems = append(certPems, cert.Certificate)
+	}
+
+	if nde.SelfCertificate != "" {
+		certPems = append(certPems, nde.SelfCertificate)
+	}
+
+	for _, certPem := range certPems {
+		block, _ := pem.Decode([]byte(certPem))
+		if block == nil || block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		pins = append(pins, block.Bytes)
+	}
+
+	return
+}
+
+func newDialer(pins [][]byte) *websocket.Dialer {
+	return &websocket.Dialer{
+		HandshakeTimeout: 30 * time.Second,
+		ReadBufferSize:   bufferSize,
+		WriteBufferSize:  bufferSize,
+		TLSClientConfig: &tls.Config{
+			// Peer certificate is pinned in VerifyPeerCertificate
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte,
+				_ [][]*x509.Certificate) error {
+
+				if len(rawCerts) == 0 {
+					return &errortypes.VerificationError{
+						errors.New("console: Missing node certificate"),
+					}
+				}
+
+				for _, pin := range pins {
+					if bytes.Equal(rawCerts[0], pin) {
+						return nil
+					}
+				}
+
+				return &errortypes.VerificationError{
+					errors.New("console: Unknown node certificate"),
+				}
+			},
+		},
+	}
+}
+
+func dialNode(db *database.Database, inst *instance.Instance,
+	typ string) (conn io.ReadWriteCloser, err error) {
+
+	nde, err := node.Get(db, inst.Node)
+	if err != nil {
+		return
+	}
+
+	if len(nde.PrivateIps) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("console: Instance node missing internal address"),
+		}
+		return
+	}
+
+	tokn, err := NewToken(db, inst.Id, nde.Id, typ)
+	if err != nil {
+		return
+	}
+
+	scheme := "wss"
+	if nde.Protocol == "http" {
+		scheme = "ws"
+	}
+
+	port := nde.Port
+	if port == 0 {
+		port = 443
+	}
+
+	pins, err := getNodePins(db, nde)
+	if err != nil {
+		return
+	}
+
+	uri := &url.URL{
+		Scheme: scheme,
+		Host:   fmt.Sprintf("%s:%d", nde.PrivateIps[0], port),
+		Path:   fmt.Sprintf("/console/%s", inst.Id.Hex()),
+	}
+
+	header := http.Header{}
+	header.Set(tokenHeader, tokn.Id)
+
+	remote, _, err := newDialer(pins).Dial(uri.String(), header)
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "console: Failed to connect to instance node"),
+		}
+		return
+	}
+
+	conn = &wsConn{
+		conn: remote,
+	}
+
+	return
+}
+
+func pump(socket *websocket.Conn, conn io.ReadWriteCloser) {
+	done := make(chan bool, 2)
+
+	socket.SetReadDeadline(time.Time{})
+
+	go func() {
+		defer func() {
+			done <- true
+		}()
+
+		for {
+			_, data, err := socket.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			_, err = conn.Write(data)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer func() {
+			done <- true
+		}()
+
+		buf := make([]byte, bufferSize)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+
+			socket.SetWriteDeadline(time.Now().Add(writeTimeout))
+			err = socket.WriteMessage(websocket.BinaryMessage, buf[:n])
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	<-done
+
+	socket.Close()
+	conn.Close()
+}
+
+func Proxy(db *database.Database, w http.ResponseWriter, r *http.Request,
+	inst *instance.Instance, typ string) (err error) {
+
+	_, err = getSockPath(inst.Id, typ)
+	if err != nil {
+		return
+	}
+
+	var conn io.ReadWriteCloser
+	if inst.Node == node.Self.Id {
+		conn, err = dial(inst.Id, typ)
+	} else {
+		conn, err = dialNode(db, inst, typ)
+	}
+	if err != nil {
+		return
+	}
+
+	socket, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		conn.Close()
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "console: Failed to upgrade request"),
+		}
+		return
+	}
+
+	pump(socket, conn)
+
+	return
+}
+
+func ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	instIdStr := strings.TrimPrefix(r.URL.Path, "/console/")
+	if !bson.IsObjectIdHex(instIdStr) {
+		utils.WriteStatus(w, 400)
+		return
+	}
+	instId := bson.ObjectIdHex(instIdStr)
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	tokn, err := ClaimToken(db, r.Header.Get(tokenHeader), instId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": instId.Hex(),
+			"error":       err,
+		}).Error("console: Failed to authenticate console request")
+		utils.WriteStatus(w, 401)
+		return
+	}
+
+	conn, err := dial(instId, tokn.Type)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": instId.Hex(),
+			"error":       err,
+		}).Error("console: Failed to connect instance console")
+		utils.WriteStatus(w, 500)
+		return
+	}
+
+	socket, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	pump(socket, conn)
+}
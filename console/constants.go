@@ -0,0 +1,16 @@
+package console
+
+import (
+	"time"
+)
+
+const (
+	Serial = "serial"
+	Vnc    = "vnc"
+
+	tokenHeader = "Pritunl-Console-Token"
+
+	tokenTtl     = 30 * time.Second
+	writeTimeout = 10 * time.Second
+	bufferSize   = 32768
+)
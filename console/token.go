@@ -0,0 +1,87 @@
+package console
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+type Token struct {
+	Id        string        `bson:"_id"`
+	Instance  bson.ObjectId `bson:"instance"`
+	Node      bson.ObjectId `bson:"node"`
+	Type      string        `bson:"type"`
+	Timestamp time.Time     `bson:"timestamp"`
+}
+
+func (t *Token) Insert(db *database.Database) (err error) {
+	coll := db.ConsoleTokens()
+
+	err = coll.Insert(t)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func NewToken(db *database.Database, instId, nodeId bson.ObjectId,
+	typ string) (tokn *Token, err error) {
+
+	token, err := utils.RandStr(64)
+	if err != nil {
+		return
+	}
+
+	tokn = &Token{
+		Id:        token,
+		Instance:  instId,
+		Node:      nodeId,
+		Type:      typ,
+		Timestamp: time.Now(),
+	}
+
+	err = tokn.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func ClaimToken(db *database.Database, token string,
+	instId bson.ObjectId) (tokn *Token, err error) {
+
+	coll := db.ConsoleTokens()
+	tokn = &Token{}
+
+	change := mgo.Change{
+		Remove: true,
+	}
+
+	_, err = coll.Find(&bson.M{
+		"_id":      token,
+		"instance": instId,
+		"node":     node.Self.Id,
+		"timestamp": &bson.M{
+			"$gte": time.Now().Add(-tokenTtl),
+		},
+	}).Apply(change, tokn)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = &errortypes.AuthenticationError{
+				errors.New("console: Invalid console token"),
+			}
+		}
+		return
+	}
+
+	return
+}
@@ -1,13 +1,23 @@
 package data
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
+type diskInfo struct {
+	VirtualSize int64 `json:"virtual-size"`
+}
+
 func CreateDisk(db *database.Database, dsk *disk.Disk) (err error) {
 	diskPath := paths.GetDiskPath(dsk.Id)
 
@@ -26,3 +36,112 @@ func CreateDisk(db *database.Database, dsk *disk.Disk) (err error) {
 
 	return
 }
+
+func RestoreDisk(db *database.Database, dsk *disk.Disk) (err error) {
+	diskPath := paths.GetDiskPath(dsk.Id)
+	diskTempPath := paths.GetDiskTempPath()
+
+	img, err := image.Get(db, dsk.RestoreImage)
+	if err != nil {
+		return
+	}
+
+	if img.Type != storage.Private {
+		err = &errortypes.ParseError{
+			errors.New("data: Restore image must be a snapshot"),
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"disk_id":   dsk.Id.Hex(),
+		"disk_path": diskPath,
+		"image_id":  img.Id.Hex(),
+	}).Info("data: Restoring disk from snapshot")
+
+	err = utils.ExistsMkdir(paths.GetTempPath(), 0755)
+	if err != nil {
+		return
+	}
+
+	err = getSnapshot(db, img, diskTempPath)
+	if err != nil {
+		return
+	}
+
+	imgSize, err := GetDiskSize(diskTempPath)
+	if err != nil {
+		utils.Remove(diskTempPath)
+		return
+	}
+
+	if int64(dsk.Size)*1073741824 > imgSize {
+		_, err = utils.ExecCombinedOutputLogged(nil, "qemu-img",
+			"resize", diskTempPath, fmt.Sprintf("%dG", dsk.Size))
+		if err != nil {
+			utils.Remove(diskTempPath)
+			return
+		}
+	}
+
+	err = utils.Exec("", "mv", "-f", diskTempPath, diskPath)
+	if err != nil {
+		utils.Remove(diskTempPath)
+		return
+	}
+
+	return
+}
+
+func GetDiskSize(diskPath string) (size int64, err error) {
+	output, err := utils.ExecOutput("", "qemu-img", "info", "-U",
+		"--output=json", diskPath)
+	if err != nil {
+		return
+	}
+
+	info := &diskInfo{}
+	err = json.Unmarshal([]byte(output), info)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to parse disk info"),
+		}
+		return
+	}
+
+	size = info.VirtualSize
+
+	return
+}
+
+func ResizeDisk(db *database.Database, dsk *disk.Disk, size int) (
+	err error) {
+
+	diskPath := paths.GetDiskPath(dsk.Id)
+
+	curSize, err := GetDiskSize(diskPath)
+	if err != nil {
+		return
+	}
+
+	if int64(size)*1073741824 < curSize {
+		err = &errortypes.ParseError{
+			errors.New("data: Cannot shrink disk"),
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"disk_id":   dsk.Id.Hex(),
+		"disk_path": diskPath,
+		"size":      size,
+	}).Info("data: Resizing disk")
+
+	_, err = utils.ExecCombinedOutputLogged(nil, "qemu-img",
+		"resize", diskPath, fmt.Sprintf("%dG", size))
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -3,20 +3,17 @@ package data
 import (
 	"fmt"
 	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/minio/minio-go"
 	"github.com/pritunl/pritunl-cloud/constants"
 	"github.com/pritunl/pritunl-cloud/database"
-	"github.com/pritunl/pritunl-cloud/datacenter"
-	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/errortypes"
-	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
-	"github.com/pritunl/pritunl-cloud/zone"
 	"golang.org/x/crypto/openpgp"
 	"gopkg.in/mgo.v2/bson"
 	"os"
@@ -262,7 +259,7 @@ func WriteImage(db *database.Database, imgId, dskId bson.ObjectId,
 			return
 		}
 
-		err = getImage(db, img, diskTempPath)
+		err = getSnapshot(db, img, diskTempPath)
 		if err != nil {
 			return
 		}
@@ -284,16 +281,7 @@ func WriteImage(db *database.Database, imgId, dskId bson.ObjectId,
 	return
 }
 
-func DeleteImage(db *database.Database, imgId bson.ObjectId) (err error) {
-	img, err := image.Get(db, imgId)
-	if err != nil {
-		return
-	}
-
-	if img.Type == storage.Public {
-		return
-	}
-
+func removeImage(db *database.Database, img *image.Image) (err error) {
 	store, err := storage.Get(db, img.Storage)
 	if err != nil {
 		return
@@ -313,6 +301,11 @@ func DeleteImage(db *database.Database, imgId bson.ObjectId) (err error) {
 		return
 	}
 
+	if img.Disk != "" {
+		client.RemoveObject(store.Bucket, getManifestKey(img.Key))
+		utils.Remove(getSnapshotCachePath(img.Key))
+	}
+
 	err = image.Remove(db, img.Id)
 	if err != nil {
 		return
@@ -321,175 +314,114 @@ func DeleteImage(db *database.Database, imgId bson.ObjectId) (err error) {
 	return
 }
 
-func DeleteImages(db *database.Database, imgIds []bson.ObjectId) (err error) {
-	for _, imgId := range imgIds {
-		err = DeleteImage(db, imgId)
-		if err != nil {
-			return
-		}
-	}
-
-	return
-}
-
-func DeleteImageOrg(db *database.Database, orgId, imgId bson.ObjectId) (
-	err error) {
-
-	img, err := image.GetOrg(db, orgId, imgId)
-	if err != nil {
-		return
-	}
-
+func deleteImage(db *database.Database, img *image.Image) (err error) {
 	if img.Type == storage.Public {
 		return
 	}
 
-	store, err := storage.Get(db, img.Storage)
+	hasChildren, err := image.HasChildren(db, img.Id)
 	if err != nil {
 		return
 	}
 
-	client, err := minio.New(
-		store.Endpoint, store.AccessKey, store.SecretKey, !store.Insecure)
-	if err != nil {
-		err = &errortypes.ConnectionError{
-			errors.Wrap(err, "data: Failed to connect to storage"),
+	if hasChildren {
+		img.Deleted = true
+		err = img.CommitFields(db, set.NewSet("deleted"))
+		if err != nil {
+			return
 		}
-		return
-	}
 
-	err = client.RemoveObject(store.Bucket, img.Key)
-	if err != nil {
 		return
 	}
 
-	err = image.Remove(db, img.Id)
-	if err != nil {
-		return
-	}
+	for {
+		err = removeImage(db, img)
+		if err != nil {
+			return
+		}
 
-	return
-}
+		if img.Parent == "" {
+			break
+		}
 
-func DeleteImagesOrg(db *database.Database, orgId bson.ObjectId,
-	imgIds []bson.ObjectId) (err error) {
+		parent, e := image.Get(db, img.Parent)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				break
+			}
+			err = e
+			return
+		}
 
-	for _, imgId := range imgIds {
-		err = DeleteImageOrg(db, orgId, imgId)
+		if !parent.Deleted {
+			break
+		}
+
+		hasChildren, err = image.HasChildren(db, parent.Id)
 		if err != nil {
 			return
 		}
+
+		if hasChildren {
+			break
+		}
+
+		img = parent
 	}
 
 	return
 }
 
-func CreateSnapshot(db *database.Database, dsk *disk.Disk) (err error) {
-	dskPth := paths.GetDiskPath(dsk.Id)
-	cacheDir := node.Self.GetCachePath()
-
-	logrus.WithFields(logrus.Fields{
-		"disk_id":     dsk.Id.Hex(),
-		"source_path": dskPth,
-	}).Info("data: Creating disk snapshot")
-
-	nde, err := node.Get(db, dsk.Node)
-	if err != nil {
-		return
-	}
-
-	zne, err := zone.Get(db, nde.Zone)
+func DeleteImage(db *database.Database, imgId bson.ObjectId) (err error) {
+	img, err := image.Get(db, imgId)
 	if err != nil {
 		return
 	}
 
-	dc, err := datacenter.Get(db, zne.Datacenter)
+	err = deleteImage(db, img)
 	if err != nil {
 		return
 	}
 
-	if dc.PrivateStorage == "" {
-		logrus.WithFields(logrus.Fields{
-			"disk_id": dsk.Id.Hex(),
-		}).Error("data: Cannot snapshot disk without private storage")
-		return
-	}
+	return
+}
 
-	store, err := storage.Get(db, dc.PrivateStorage)
-	if err != nil {
-		if _, ok := err.(*database.NotFoundError); ok {
-			err = nil
-			logrus.WithFields(logrus.Fields{
-				"disk_id": dsk.Id.Hex(),
-			}).Error("data: Cannot snapshot disk without private storage")
+func DeleteImages(db *database.Database, imgIds []bson.ObjectId) (err error) {
+	for _, imgId := range imgIds {
+		err = DeleteImage(db, imgId)
+		if err != nil {
+			return
 		}
-		return
 	}
 
-	imgId := bson.NewObjectId()
-	tmpPath := path.Join(cacheDir,
-		fmt.Sprintf("snapshot-%s", imgId.Hex()))
-	img := &image.Image{
-		Id: imgId,
-		Name: fmt.Sprintf("%s-%s", dsk.Name,
-			time.Now().Format("2006-01-02T15:04:05")),
-		Organization: dsk.Organization,
-		Type:         storage.Private,
-		Storage:      store.Id,
-		Key:          fmt.Sprintf("snapshot/%s.qcow2", imgId.Hex()),
-	}
-
-	defer utils.Remove(tmpPath)
-	err = utils.Exec("", "qemu-img", "convert", "-f", "qcow2",
-		"-O", "qcow2", "-c", dskPth, tmpPath)
-	if err != nil {
-		return
-	}
+	return
+}
 
-	logrus.WithFields(logrus.Fields{
-		"disk_id":     dsk.Id.Hex(),
-		"source_path": dskPth,
-		"storage_id":  store.Id.Hex(),
-		"object_key":  img.Key,
-	}).Info("data: Uploading disk snapshot")
+func DeleteImageOrg(db *database.Database, orgId, imgId bson.ObjectId) (
+	err error) {
 
-	client, err := minio.New(
-		store.Endpoint, store.AccessKey, store.SecretKey, !store.Insecure)
+	img, err := image.GetOrg(db, orgId, imgId)
 	if err != nil {
-		err = &errortypes.ConnectionError{
-			errors.Wrap(err, "data: Failed to connect to storage"),
-		}
 		return
 	}
 
-	_, err = client.FPutObject(store.Bucket, img.Key, tmpPath,
-		minio.PutObjectOptions{})
+	err = deleteImage(db, img)
 	if err != nil {
-		err = &errortypes.WriteError{
-			errors.Wrap(err, "data: Failed to write object"),
-		}
 		return
 	}
 
-	obj, err := client.StatObject(store.Bucket, img.Key,
-		minio.StatObjectOptions{})
-	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "data: Failed to stat object"),
-		}
-		return
-	}
+	return
+}
 
-	img.Etag = image.GetEtag(obj)
-	img.LastModified = obj.LastModified
+func DeleteImagesOrg(db *database.Database, orgId bson.ObjectId,
+	imgIds []bson.ObjectId) (err error) {
 
-	err = img.Insert(db)
-	if err != nil {
-		client.RemoveObject(store.Bucket, img.Key)
-		return
+	for _, imgId := range imgIds {
+		err = DeleteImageOrg(db, orgId, imgId)
+		if err != nil {
+			return
+		}
 	}
 
-	event.PublishDispatch(db, "image.change")
-
 	return
 }
@@ -0,0 +1,420 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/minio/minio-go"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/datacenter"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/storage"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+)
+
+type snapshotManifest struct {
+	Id     bson.ObjectId `json:"id"`
+	Disk   bson.ObjectId `json:"disk"`
+	Parent bson.ObjectId `json:"parent,omitempty"`
+	Chain  []string      `json:"chain"`
+}
+
+func getManifestKey(key string) string {
+	return strings.TrimSuffix(key, ".qcow2") + ".json"
+}
+
+func getSnapshotCachePath(key string) string {
+	return path.Join(node.Self.GetCachePath(),
+		fmt.Sprintf("snapshot-%s", path.Base(key)))
+}
+
+func getManifest(client *minio.Client, store *storage.Storage,
+	img *image.Image) (mnfst *snapshotManifest, err error) {
+
+	obj, err := client.GetObject(store.Bucket, getManifestKey(img.Key),
+		minio.GetObjectOptions{})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to get snapshot manifest"),
+		}
+		return
+	}
+	defer obj.Close()
+
+	data, err := ioutil.ReadAll(obj)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			err = nil
+			mnfst = &snapshotManifest{
+				Id:    img.Id,
+				Disk:  img.Disk,
+				Chain: []string{img.Key},
+			}
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to read snapshot manifest"),
+		}
+		return
+	}
+
+	mnfst = &snapshotManifest{}
+	err = json.Unmarshal(data, mnfst)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to parse snapshot manifest"),
+		}
+		return
+	}
+
+	if len(mnfst.Chain) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("data: Snapshot manifest chain empty"),
+		}
+		return
+	}
+
+	return
+}
+
+func putManifest(client *minio.Client, store *storage.Storage,
+	key string, mnfst *snapshotManifest) (err error) {
+
+	data, err := json.Marshal(mnfst)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to marshal snapshot manifest"),
+		}
+		return
+	}
+
+	_, err = client.PutObject(store.Bucket, getManifestKey(key),
+		bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+			ContentType: "application/json",
+		})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to write snapshot manifest"),
+		}
+		return
+	}
+
+	return
+}
+
+func getSnapshotChain(db *database.Database, store *storage.Storage,
+	client *minio.Client, img *image.Image) (chain []string,
+	headPath string, err error) {
+
+	mnfst, err := getManifest(client, store, img)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(node.Self.GetCachePath(), 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(paths.GetTempPath(), 0755)
+	if err != nil {
+		return
+	}
+
+	for _, key := range mnfst.Chain {
+		pth := getSnapshotCachePath(key)
+
+		err = getImage(db, &image.Image{
+			Storage: store.Id,
+			Key:     key,
+		}, pth)
+		if err != nil {
+			return
+		}
+
+		if headPath != "" {
+			_, err = utils.ExecCombinedOutputLogged(nil, "qemu-img",
+				"rebase", "-u", "-f", "qcow2", "-b", headPath,
+				"-F", "qcow2", pth)
+			if err != nil {
+				return
+			}
+		}
+
+		headPath = pth
+	}
+
+	chain = mnfst.Chain
+
+	return
+}
+
+func getSnapshot(db *database.Database, img *image.Image,
+	pth string) (err error) {
+
+	if img.Parent == "" {
+		err = getImage(db, img, pth)
+		return
+	}
+
+	store, err := storage.Get(db, img.Storage)
+	if err != nil {
+		return
+	}
+
+	client, err := minio.New(
+		store.Endpoint, store.AccessKey, store.SecretKey, !store.Insecure)
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "data: Failed to connect to storage"),
+		}
+		return
+	}
+
+	_, headPath, err := getSnapshotChain(db, store, client, img)
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"image_id": img.Id.Hex(),
+		"depth":    img.Depth,
+		"path":     pth,
+	}).Info("data: Assembling snapshot chain")
+
+	_, err = utils.ExecCombinedOutputLogged(nil, "qemu-img", "convert",
+		"-f", "qcow2", "-O", "qcow2", headPath, pth)
+	if err != nil {
+		utils.Remove(pth)
+		return
+	}
+
+	return
+}
+
+func pruneSnapshotCache(db *database.Database, dskId bson.ObjectId,
+	chain []string) (err error) {
+
+	keys, err := image.GetDiskKeys(db, dskId)
+	if err != nil {
+		return
+	}
+
+	chainKeys := set.NewSet()
+	for _, key := range chain {
+		chainKeys.Add(key)
+	}
+
+	for _, key := range keys {
+		if chainKeys.Contains(key) {
+			continue
+		}
+
+		err = utils.Remove(getSnapshotCachePath(key))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func CreateSnapshot(db *database.Database, dsk *disk.Disk) (err error) {
+	dskPth := paths.GetDiskPath(dsk.Id)
+	cacheDir := node.Self.GetCachePath()
+
+	logrus.WithFields(logrus.Fields{
+		"disk_id":     dsk.Id.Hex(),
+		"source_path": dskPth,
+	}).Info("data: Creating disk snapshot")
+
+	nde, err := node.Get(db, dsk.Node)
+	if err != nil {
+		return
+	}
+
+	zne, err := zone.Get(db, nde.Zone)
+	if err != nil {
+		return
+	}
+
+	dc, err := datacenter.Get(db, zne.Datacenter)
+	if err != nil {
+		return
+	}
+
+	if dc.PrivateStorage == "" {
+		logrus.WithFields(logrus.Fields{
+			"disk_id": dsk.Id.Hex(),
+		}).Error("data: Cannot snapshot disk without private storage")
+		return
+	}
+
+	store, err := storage.Get(db, dc.PrivateStorage)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			logrus.WithFields(logrus.Fields{
+				"disk_id": dsk.Id.Hex(),
+			}).Error("data: Cannot snapshot disk without private storage")
+		}
+		return
+	}
+
+	client, err := minio.New(
+		store.Endpoint, store.AccessKey, store.SecretKey, !store.Insecure)
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "data: Failed to connect to storage"),
+		}
+		return
+	}
+
+	err = utils.ExistsMkdir(cacheDir, 0755)
+	if err != nil {
+		return
+	}
+
+	imgId := bson.NewObjectId()
+	tmpPath := path.Join(cacheDir,
+		fmt.Sprintf("snapshot-%s", imgId.Hex()))
+	img := &image.Image{
+		Id: imgId,
+		Name: fmt.Sprintf("%s-%s", dsk.Name,
+			time.Now().Format("2006-01-02T15:04:05")),
+		Organization: dsk.Organization,
+		Type:         storage.Private,
+		Storage:      store.Id,
+		Key:          fmt.Sprintf("snapshot/%s.qcow2", imgId.Hex()),
+		Disk:         dsk.Id,
+		Scheduled:    dsk.SnapshotScheduled,
+	}
+
+	chain := []string{}
+	parentPath := ""
+
+	if settings.Hypervisor.SnapshotChain > 1 {
+		parent, e := image.GetDiskLatest(db, dsk.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if parent != nil && parent.Storage == store.Id &&
+			parent.Depth+1 < settings.Hypervisor.SnapshotChain {
+
+			chain, parentPath, err = getSnapshotChain(
+				db, store, client, parent)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"disk_id":   dsk.Id.Hex(),
+					"parent_id": parent.Id.Hex(),
+					"error":     err,
+				}).Warning("data: Failed to load parent snapshot, " +
+					"creating full snapshot")
+
+				err = nil
+				chain = []string{}
+				parentPath = ""
+			} else {
+				img.Parent = parent.Id
+				img.Depth = parent.Depth + 1
+			}
+		}
+	}
+
+	chain = append(chain, img.Key)
+
+	defer utils.Remove(tmpPath)
+	if parentPath != "" {
+		err = utils.Exec("", "qemu-img", "convert", "-f", "qcow2",
+			"-O", "qcow2", "-c", "-B", parentPath,
+			"-o", "backing_fmt=qcow2", dskPth, tmpPath)
+		if err != nil {
+			return
+		}
+	} else {
+		err = utils.Exec("", "qemu-img", "convert", "-f", "qcow2",
+			"-O", "qcow2", "-c", dskPth, tmpPath)
+		if err != nil {
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"disk_id":     dsk.Id.Hex(),
+		"source_path": dskPth,
+		"storage_id":  store.Id.Hex(),
+		"object_key":  img.Key,
+		"depth":       img.Depth,
+	}).Info("data: Uploading disk snapshot")
+
+	_, err = client.FPutObject(store.Bucket, img.Key, tmpPath,
+		minio.PutObjectOptions{})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to write object"),
+		}
+		return
+	}
+
+	obj, err := client.StatObject(store.Bucket, img.Key,
+		minio.StatObjectOptions{})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to stat object"),
+		}
+		return
+	}
+
+	img.Etag = image.GetEtag(obj)
+	img.LastModified = obj.LastModified
+
+	err = putManifest(client, store, img.Key, &snapshotManifest{
+		Id:     img.Id,
+		Disk:   dsk.Id,
+		Parent: img.Parent,
+		Chain:  chain,
+	})
+	if err != nil {
+		client.RemoveObject(store.Bucket, img.Key)
+		return
+	}
+
+	err = img.Insert(db)
+	if err != nil {
+		client.RemoveObject(store.Bucket, img.Key)
+		client.RemoveObject(store.Bucket, getManifestKey(img.Key))
+		return
+	}
+
+	err = utils.Exec("", "mv", tmpPath, getSnapshotCachePath(img.Key))
+	if err != nil {
+		return
+	}
+
+	err = pruneSnapshotCache(db, dsk.Id, chain)
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "image.change")
+
+	return
+}
@@ -72,6 +72,16 @@ func (d *Database) SecondaryTokens() (coll *Collection) {
 	return
 }
 
+func (d *Database) ConsoleTokens() (coll *Collection) {
+	coll = d.getCollection("console_tokens")
+	return
+}
+
+func (d *Database) FirewallCounters() (coll *Collection) {
+	coll = d.getCollection("firewall_counters")
+	return
+}
+
 func (d *Database) Nonces() (coll *Collection) {
 	coll = d.getCollection("nonces")
 	return
@@ -132,6 +142,11 @@ func (d *Database) Firewalls() (coll *Collection) {
 	return
 }
 
+func (d *Database) Templates() (coll *Collection) {
+	coll = d.getCollection("templates")
+	return
+}
+
 func (d *Database) Vpcs() (coll *Collection) {
 	coll = d.getCollection("vpcs")
 	return
@@ -142,6 +157,26 @@ func (d *Database) VpcsIp() (coll *Collection) {
 	return
 }
 
+func (d *Database) FloatingIps() (coll *Collection) {
+	coll = d.getCollection("floating_ips")
+	return
+}
+
+func (d *Database) VpcsPeering() (coll *Collection) {
+	coll = d.getCollection("vpcs_peering")
+	return
+}
+
+func (d *Database) PlacementGroups() (coll *Collection) {
+	coll = d.getCollection("placement_groups")
+	return
+}
+
+func (d *Database) VpcsLinkHistory() (coll *Collection) {
+	coll = d.getCollection("vpcs_link_history")
+	return
+}
+
 func (d *Database) Authorities() (coll *Collection) {
 	coll = d.getCollection("authorities")
 	return
@@ -418,6 +453,41 @@ func addIndexes() (err error) {
 		return
 	}
 
+	coll = db.ConsoleTokens()
+	err = coll.EnsureIndex(mgo.Index{
+		Key:         []string{"timestamp"},
+		ExpireAfter: 3 * time.Minute,
+		Background:  true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+		return
+	}
+
+	coll = db.FirewallCounters()
+	err = coll.EnsureIndex(mgo.Index{
+		Key:         []string{"timestamp"},
+		ExpireAfter: 10 * time.Minute,
+		Background:  true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+		return
+	}
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"organization", "network_roles"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+
 	coll = db.Nodes()
 	err = coll.EnsureIndex(mgo.Index{
 		Key:        []string{"name"},
@@ -481,6 +551,87 @@ func addIndexes() (err error) {
 			errors.Wrap(err, "database: Index error"),
 		}
 	}
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"disk"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"parent"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+
+	coll = db.Templates()
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"organization"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+
+	coll = db.FloatingIps()
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"address"},
+		Unique:     true,
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"zone", "organization"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"instance"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+
+	coll = db.PlacementGroups()
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"organization"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+
+	coll = db.Instances()
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"placement_group"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
 
 	coll = db.Disks()
 	err = coll.EnsureIndex(mgo.Index{
@@ -559,6 +710,47 @@ func addIndexes() (err error) {
 		}
 	}
 
+	coll = db.VpcsPeering()
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"vpc"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"peer_vpc"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+
+	coll = db.VpcsLinkHistory()
+	err = coll.EnsureIndex(mgo.Index{
+		Key:        []string{"vpc", "-timestamp"},
+		Background: true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+	err = coll.EnsureIndex(mgo.Index{
+		Key:         []string{"timestamp"},
+		ExpireAfter: 720 * time.Hour,
+		Background:  true,
+	})
+	if err != nil {
+		err = &IndexError{
+			errors.Wrap(err, "database: Index error"),
+		}
+	}
+
 	coll = db.Sessions()
 	err = coll.EnsureIndex(mgo.Index{
 		Key:        []string{"user"},
@@ -3,12 +3,17 @@ package deploy
 import (
 	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/qms"
 	"github.com/pritunl/pritunl-cloud/state"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"gopkg.in/mgo.v2/bson"
 	"time"
 )
 
@@ -67,7 +72,125 @@ func (d *Disks) snapshot(dsk *disk.Disk) {
 		}
 
 		dsk.State = disk.Available
-		err = dsk.CommitFields(db, set.NewSet("state"))
+		dsk.SnapshotScheduled = false
+		err = dsk.CommitFields(db,
+			set.NewSet("state", "snapshot_scheduled"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed update disk state")
+			time.Sleep(5 * time.Second)
+			return
+		}
+
+		event.PublishDispatch(db, "disk.change")
+	}()
+}
+
+func (d *Disks) resize(dsk *disk.Disk) {
+	if disksLock.Locked(dsk.Id.Hex()) {
+		return
+	}
+
+	var curDsk *vm.Disk
+	if dsk.Instance != "" && d.stat.DiskInUse(dsk.Instance, dsk.Id) {
+		curVirt := d.stat.GetVirt(dsk.Instance)
+		for _, vmDsk := range curVirt.Disks {
+			if vmDsk.GetId() == dsk.Id {
+				curDsk = vmDsk
+				break
+			}
+		}
+	}
+
+	lockId := disksLock.Lock(dsk.Id.Hex())
+	go func() {
+		defer disksLock.Unlock(dsk.Id.Hex(), lockId)
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		var err error
+		if curDsk != nil {
+			curSize, e := data.GetDiskSize(curDsk.Path)
+			if e != nil {
+				err = e
+			} else if int64(dsk.NewSize)*1073741824 < curSize {
+				err = &errortypes.ParseError{
+					errors.New("deploy: Cannot shrink disk"),
+				}
+			} else {
+				err = qms.ResizeDisk(dsk.Instance, curDsk, dsk.NewSize)
+			}
+		} else {
+			err = data.ResizeDisk(db, dsk, dsk.NewSize)
+		}
+
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to resize disk")
+		} else {
+			dsk.Size = dsk.NewSize
+		}
+
+		dsk.State = disk.Available
+		dsk.NewSize = 0
+		err = dsk.CommitFields(db, set.NewSet("state", "size", "new_size"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed update disk state")
+			time.Sleep(5 * time.Second)
+			return
+		}
+
+		event.PublishDispatch(db, "disk.change")
+	}()
+}
+
+func (d *Disks) restore(dsk *disk.Disk) {
+	if d.stat.DiskInUse(dsk.Instance, dsk.Id) ||
+		disksLock.Locked(dsk.Id.Hex()) {
+
+		return
+	}
+
+	// Prevent the instance from starting until the restore has finished
+	var instLockId bson.ObjectId
+	if dsk.Instance != "" {
+		if instancesLock.Locked(dsk.Instance.Hex()) {
+			return
+		}
+		instLockId = instancesLock.LockTimeout(
+			dsk.Instance.Hex(), 30*time.Minute)
+	}
+
+	lockId := disksLock.LockTimeout(dsk.Id.Hex(), 30*time.Minute)
+	go func() {
+		defer disksLock.Unlock(dsk.Id.Hex(), lockId)
+		if instLockId != "" {
+			defer instancesLock.Unlock(dsk.Instance.Hex(), instLockId)
+		}
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		err := data.RestoreDisk(db, dsk)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id":  dsk.Id.Hex(),
+				"image_id": dsk.RestoreImage.Hex(),
+				"error":    err,
+			}).Error("deploy: Failed to restore disk")
+
+			dsk.State = disk.RestoreFailed
+		} else {
+			dsk.State = disk.Available
+			dsk.RestoreImage = ""
+		}
+
+		err = dsk.CommitFields(db, set.NewSet("state", "restore_image"))
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"error": err,
@@ -118,6 +241,12 @@ func (d *Disks) Deploy() (err error) {
 		case disk.Snapshot:
 			d.snapshot(dsk)
 			break
+		case disk.Resize:
+			d.resize(dsk)
+			break
+		case disk.Restore:
+			d.restore(dsk)
+			break
 		case disk.Destroy:
 			d.destroy(dsk)
 			break
@@ -1,21 +1,30 @@
 package deploy
 
 import (
+	"fmt"
 	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/domain"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/floatingip"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/qemu"
 	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/scheduler"
+	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/state"
 	"github.com/pritunl/pritunl-cloud/store"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"gopkg.in/mgo.v2/bson"
+	"sort"
 	"strings"
 	"time"
 )
@@ -25,7 +34,34 @@ var (
 )
 
 type Instances struct {
-	stat *state.State
+	stat           *state.State
+	cpuUnits       int
+	memoryUnits    float64
+	cpuUnitsRun    int
+	memoryUnitsRun float64
+}
+
+func (s *Instances) reserve(inst *instance.Instance) bool {
+	memoryUnits := float64(inst.Memory) / float64(1024)
+
+	if (s.cpuUnits != 0 && s.cpuUnitsRun+inst.Processors > s.cpuUnits) ||
+		(s.memoryUnits != 0 &&
+			s.memoryUnitsRun+memoryUnits > s.memoryUnits) {
+
+		logrus.WithFields(logrus.Fields{
+			"instance_id":      inst.Id.Hex(),
+			"cpu_units":        s.cpuUnits,
+			"cpu_units_res":    s.cpuUnitsRun,
+			"memory_units":     s.memoryUnits,
+			"memory_units_res": s.memoryUnitsRun,
+		}).Warning("deploy: Node capacity exceeded, queuing instance start")
+		return false
+	}
+
+	s.cpuUnitsRun += inst.Processors
+	s.memoryUnitsRun += memoryUnits
+
+	return true
 }
 
 func (s *Instances) create(inst *instance.Instance) {
@@ -183,6 +219,14 @@ func (s *Instances) destroy(inst *instance.Instance) {
 			return
 		}
 
+		err = floatingip.DetachInstance(db, inst.Id)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to detach instance floating IP")
+			return
+		}
+
 		event.PublishDispatch(db, "instance.change")
 		event.PublishDispatch(db, "disk.change")
 	}()
@@ -218,6 +262,281 @@ func (s *Instances) diskRemove(inst *instance.Instance, remDisks []*vm.Disk) {
 	}()
 }
 
+func (s *Instances) migratePrepare(inst *instance.Instance,
+	curVirt *vm.VirtualMachine) {
+
+	if instancesLock.Locked(inst.Id.Hex()) {
+		return
+	}
+
+	lockId := instancesLock.Lock(inst.Id.Hex())
+	go func() {
+		defer func() {
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		var err error
+		if curVirt == nil || curVirt.State != vm.Running {
+			err = &errortypes.ReadError{
+				errors.New("deploy: Instance not running for migration"),
+			}
+		} else {
+			inst.MigrateDisks, err = qemu.MigratePrepare(inst.Virt)
+		}
+
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to prepare instance migration")
+
+			inst.State = instance.Start
+			inst.MigrateClear()
+		} else {
+			inst.MigrateState = instance.MigrateIncoming
+		}
+
+		err = inst.CommitFields(db, set.NewSet("state", "migrate_node",
+			"migrate_state", "migrate_addr", "migrate_nbd_addr", "migrate_tls",
+			"migrate_disks"))
+		if err != nil {
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Instances) migrateReceive(inst *instance.Instance) {
+	if instancesLock.Locked(inst.Id.Hex()) {
+		return
+	}
+
+	lockId := instancesLock.LockTimeout(inst.Id.Hex(), 10*time.Minute)
+	go func() {
+		defer func() {
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		addr, nbdAddr, tls, err := qemu.MigrateIncoming(
+			db, inst, inst.Virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to prepare instance migration target")
+
+			inst.MigrateState = instance.MigrateFailed
+		} else {
+			inst.MigrateAddr = addr
+			inst.MigrateNbdAddr = nbdAddr
+			inst.MigrateTls = tls
+			inst.MigrateState = instance.MigrateReady
+		}
+
+		err = inst.CommitFields(db, set.NewSet("migrate_state",
+			"migrate_addr", "migrate_nbd_addr", "migrate_tls"))
+		if err != nil {
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Instances) migrateSend(inst *instance.Instance) {
+	if instancesLock.Locked(inst.Id.Hex()) {
+		return
+	}
+
+	lockId := instancesLock.LockTimeout(inst.Id.Hex(),
+		time.Duration(settings.Hypervisor.MigrateTimeout)*time.Second+
+			10*time.Minute)
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		err := qemu.Migrate(db, inst, inst.Virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to migrate instance")
+
+			inst.MigrateState = instance.MigrateFailed
+			err = inst.CommitFields(db, set.NewSet("migrate_state"))
+			if err != nil {
+				return
+			}
+
+			event.PublishDispatch(db, "instance.change")
+			return
+		}
+
+		err = qemu.MigrateCleanup(db, inst.Virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to cleanup migrated instance")
+		}
+
+		inst.Node = inst.MigrateNode
+		inst.MigrateState = instance.MigrateComplete
+
+		for i := 0; i < 10; i++ {
+			err = inst.CommitFields(db, set.NewSet("node", "migrate_state"))
+			if err == nil {
+				break
+			}
+
+			time.Sleep(1 * time.Second)
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to hand over migrated instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Instances) migrateFinish(inst *instance.Instance) {
+	if instancesLock.Locked(inst.Id.Hex()) {
+		return
+	}
+
+	lockId := instancesLock.Lock(inst.Id.Hex())
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		dskIds := []bson.ObjectId{}
+		for _, dsk := range inst.MigrateDisks {
+			dskIds = append(dskIds, dsk.Id)
+		}
+
+		err := disk.UpdateMulti(db, dskIds, &bson.M{
+			"node": node.Self.Id,
+		})
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to move migrated instance disks")
+			return
+		}
+
+		err = domain.UpdateRecordNode(db, inst.Id, node.Self.Id)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to move migrated instance records")
+			return
+		}
+
+		err = qemu.MigrateFinish(db, inst, inst.Virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to complete instance migration")
+			return
+		}
+
+		inst.State = instance.Start
+		inst.MigrateClear()
+		err = inst.CommitFields(db, set.NewSet("state", "migrate_node",
+			"migrate_state", "migrate_addr", "migrate_nbd_addr", "migrate_tls",
+			"migrate_disks"))
+		if err != nil {
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+		event.PublishDispatch(db, "disk.change")
+	}()
+}
+
+func (s *Instances) migrateAbort(inst *instance.Instance) {
+	if instancesLock.Locked(inst.Id.Hex()) {
+		return
+	}
+
+	lockId := instancesLock.Lock(inst.Id.Hex())
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		err := qemu.MigrateAbort(db, inst, inst.Virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to abort instance migration")
+			return
+		}
+
+		inst.State = instance.Start
+		inst.MigrateClear()
+		err = inst.CommitFields(db, set.NewSet("state", "migrate_node",
+			"migrate_state", "migrate_addr", "migrate_nbd_addr", "migrate_tls",
+			"migrate_disks"))
+		if err != nil {
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Instances) migrate(inst *instance.Instance,
+	curVirt *vm.VirtualMachine) {
+
+	if inst.Node == node.Self.Id {
+		switch inst.MigrateState {
+		case instance.MigratePending:
+			s.migratePrepare(inst, curVirt)
+			break
+		case instance.MigrateReady:
+			if curVirt != nil && curVirt.State == vm.Running {
+				s.migrateSend(inst)
+			}
+			break
+		case instance.MigrateComplete:
+			if inst.MigrateNode == node.Self.Id {
+				s.migrateFinish(inst)
+			}
+			break
+		}
+	} else if inst.MigrateNode == node.Self.Id {
+		switch inst.MigrateState {
+		case instance.MigrateIncoming:
+			s.migrateReceive(inst)
+			break
+		case instance.MigrateFailed:
+			s.migrateAbort(inst)
+			break
+		}
+	}
+}
+
 func (s *Instances) diff(db *database.Database,
 	inst *instance.Instance) (err error) {
 
@@ -419,6 +738,48 @@ func (s *Instances) routes(inst *instance.Instance) (err error) {
 	return
 }
 
+func (s *Instances) peers(inst *instance.Instance) (err error) {
+	peers := s.stat.VpcPeers(inst.Vpc)
+
+	newPeers := []string{}
+	for _, peerVc := range peers {
+		newPeers = append(newPeers, fmt.Sprintf("%d:%s:%s",
+			peerVc.VpcId, peerVc.Id.Hex(), peerVc.Network))
+	}
+	sort.Strings(newPeers)
+
+	peersStore, ok := store.GetPeers(inst.Id)
+	if ok && strings.Join(peersStore.Peers, ",") ==
+		strings.Join(newPeers, ",") {
+
+		return
+	}
+
+	if instancesLock.Locked(inst.Id.Hex()) {
+		return
+	}
+
+	lockId := instancesLock.Lock(inst.Id.Hex())
+	go func() {
+		defer func() {
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		e := qemu.NetworkConfPeers(inst.Id, peers)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       e,
+			}).Error("deploy: Failed to deploy instance VPC peers")
+			return
+		}
+
+		store.SetPeers(inst.Id, newPeers)
+	}()
+
+	return
+}
+
 func (s *Instances) Deploy() (err error) {
 	db := database.GetDatabase()
 	defer db.Close()
@@ -431,6 +792,37 @@ func (s *Instances) Deploy() (err error) {
 		namespacesSet.Add(namespace)
 	}
 
+	var zne *zone.Zone
+	if node.Self.Zone != "" {
+		zne, err = zone.Get(db, node.Self.Zone)
+		if err != nil {
+			return
+		}
+	}
+
+	s.cpuUnits, s.memoryUnits = scheduler.GetCapacity(zne, node.Self)
+	s.cpuUnitsRun = 0
+	s.memoryUnitsRun = 0
+
+	for _, inst := range instances {
+		curVirt := s.stat.GetVirt(inst.Id)
+		if curVirt == nil || curVirt.State == vm.Stopped ||
+			curVirt.State == vm.Failed {
+
+			continue
+		}
+
+		s.cpuUnitsRun += inst.Processors
+		s.memoryUnitsRun += float64(inst.Memory) / float64(1024)
+	}
+
+	pendingDisks := set.NewSet()
+	for _, dsk := range s.stat.Disks() {
+		if dsk.Instance != "" && dsk.State != disk.Available {
+			pendingDisks.Add(dsk.Instance)
+		}
+	}
+
 	cpuUnits := 0
 	memoryUnits := 0.0
 
@@ -442,10 +834,25 @@ func (s *Instances) Deploy() (err error) {
 			continue
 		}
 
-		cpuUnits += inst.Processors
-		memoryUnits += float64(inst.Memory) / float64(1024)
+		if inst.State != instance.Stop {
+			cpuUnits += inst.Processors
+			memoryUnits += float64(inst.Memory) / float64(1024)
+		}
+
+		if inst.State == instance.Migrate {
+			s.migrate(inst, curVirt)
+			continue
+		}
 
 		if curVirt == nil {
+			if pendingDisks.Contains(inst.Id) {
+				continue
+			}
+
+			if inst.State == instance.Start && !s.reserve(inst) {
+				continue
+			}
+
 			s.create(inst)
 			continue
 		}
@@ -453,7 +860,9 @@ func (s *Instances) Deploy() (err error) {
 		switch inst.State {
 		case instance.Start:
 			if curVirt.State == vm.Stopped || curVirt.State == vm.Failed {
-				s.start(inst)
+				if s.reserve(inst) {
+					s.start(inst)
+				}
 				continue
 			}
 
@@ -476,6 +885,11 @@ func (s *Instances) Deploy() (err error) {
 				return
 			}
 
+			err = s.peers(inst)
+			if err != nil {
+				return
+			}
+
 			break
 		case instance.Stop:
 			if curVirt.State == vm.Running {
@@ -3,22 +3,104 @@ package deploy
 import (
 	"github.com/Sirupsen/logrus"
 	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/firewall"
 	"github.com/pritunl/pritunl-cloud/iptables"
+	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/state"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"time"
+)
+
+var (
+	countersLast time.Time
 )
 
 type Iptables struct {
 	stat *state.State
 }
 
+func (t *Iptables) counters(db *database.Database) {
+	if time.Since(countersLast) < 1*time.Minute {
+		return
+	}
+	countersLast = time.Now()
+
+	if node.Self.Firewall {
+		rules := map[string]int64{}
+
+		err := iptables.GetCounters("0", rules)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to load node firewall counters")
+		} else {
+			cntr := &firewall.Counters{
+				Id:           node.Self.Id,
+				Node:         node.Self.Id,
+				NetworkRoles: node.Self.NetworkRoles,
+				Rules:        rules,
+				Timestamp:    time.Now(),
+			}
+
+			err = cntr.Commit(db)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("deploy: Failed to store node firewall counters")
+			}
+		}
+	}
+
+	for _, inst := range t.stat.Instances() {
+		if !inst.IsActive() || inst.Virt == nil {
+			continue
+		}
+
+		rules := map[string]int64{}
+
+		var err error
+		for i := range inst.Virt.NetworkAdapters {
+			err = iptables.GetCounters(vm.GetNamespace(inst.Id, i), rules)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to load instance firewall counters")
+			continue
+		}
+
+		cntr := &firewall.Counters{
+			Id:           inst.Id,
+			Node:         node.Self.Id,
+			Organization: inst.Organization,
+			NetworkRoles: inst.NetworkRoles,
+			Rules:        rules,
+			Timestamp:    time.Now(),
+		}
+
+		err = cntr.Commit(db)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to store instance firewall counters")
+		}
+	}
+}
+
 func (t *Iptables) Deploy() (err error) {
 	db := database.GetDatabase()
 	defer db.Close()
 
 	instaces := t.stat.Instances()
+	vpcRoleIps := t.stat.VpcRoleIps()
 	namespaces := t.stat.Namespaces()
 
-	err = iptables.UpdateState(db, instaces, namespaces)
+	err = iptables.UpdateState(db, instaces, vpcRoleIps, namespaces)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"error": err,
@@ -37,6 +119,8 @@ func (t *Iptables) Deploy() (err error) {
 		return
 	}
 
+	t.counters(db)
+
 	return
 }
 
@@ -37,6 +37,11 @@ func (n *Namespace) Deploy() (err error) {
 		curVirtIfaces.Add(vm.GetIfaceVirt(inst.Id, 0))
 		curVirtIfaces.Add(vm.GetIfaceVirt(inst.Id, 1))
 		curExternalIfaces.Add(vm.GetIfaceExternal(inst.Id, 0))
+
+		for n := 1; n < len(inst.GetVpcs()); n++ {
+			curNamespaces.Add(vm.GetNamespace(inst.Id, n))
+			curVirtIfaces.Add(vm.GetIfaceVirt(inst.Id, n*2+1))
+		}
 	}
 
 	for _, iface := range interfaces {
@@ -4,5 +4,14 @@ const (
 	Provision = "provision"
 	Available = "available"
 	Snapshot  = "snapshot"
+	Resize    = "resize"
+	Restore   = "restore"
 	Destroy   = "destroy"
+
+	RestoreFailed = "restore_failed"
+
+	Disabled = "disabled"
+	Hourly   = "hourly"
+	Daily    = "daily"
+	Weekly   = "weekly"
 )
@@ -11,19 +11,26 @@ import (
 	"gopkg.in/mgo.v2/bson"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Disk struct {
-	Id             bson.ObjectId `bson:"_id,omitempty" json:"id"`
-	Name           string        `bson:"name" json:"name"`
-	State          string        `bson:"state" json:"state"`
-	Node           bson.ObjectId `bson:"node" json:"node"`
-	Organization   bson.ObjectId `bson:"organization,omitempty" json:"organization"`
-	Instance       bson.ObjectId `bson:"instance,omitempty" json:"instance"`
-	SourceInstance bson.ObjectId `bson:"source_instance,omitempty" json:"source_instance"`
-	Image          bson.ObjectId `bson:"image,omitempty" json:"image"`
-	Index          string        `bson:"index" json:"index"`
-	Size           int           `bson:"size" json:"size"`
+	Id                bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Name              string        `bson:"name" json:"name"`
+	State             string        `bson:"state" json:"state"`
+	Node              bson.ObjectId `bson:"node" json:"node"`
+	Organization      bson.ObjectId `bson:"organization,omitempty" json:"organization"`
+	Instance          bson.ObjectId `bson:"instance,omitempty" json:"instance"`
+	SourceInstance    bson.ObjectId `bson:"source_instance,omitempty" json:"source_instance"`
+	Image             bson.ObjectId `bson:"image,omitempty" json:"image"`
+	Index             string        `bson:"index" json:"index"`
+	Size              int           `bson:"size" json:"size"`
+	NewSize           int           `bson:"new_size" json:"new_size"`
+	SnapshotSchedule  string        `bson:"snapshot_schedule" json:"snapshot_schedule"`
+	SnapshotRetention int           `bson:"snapshot_retention" json:"snapshot_retention"`
+	SnapshotScheduled bool          `bson:"snapshot_scheduled" json:"snapshot_scheduled"`
+	LastSnapshot      time.Time     `bson:"last_snapshot" json:"last_snapshot"`
+	RestoreImage      bson.ObjectId `bson:"restore_image,omitempty" json:"restore_image"`
 }
 
 func (d *Disk) Validate(db *database.Database) (
@@ -62,6 +69,37 @@ func (d *Disk) Validate(db *database.Database) (
 		d.Size = 10
 	}
 
+	switch d.SnapshotSchedule {
+	case "", Disabled, Hourly, Daily, Weekly:
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "snapshot_schedule_invalid",
+			Message: "Disk snapshot schedule invalid",
+		}
+		return
+	}
+
+	if d.SnapshotRetention < 0 {
+		d.SnapshotRetention = 0
+	}
+
+	if d.State == Restore && d.RestoreImage == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "restore_image_required",
+			Message: "Missing required restore image",
+		}
+		return
+	}
+
+	if d.State == Resize && d.NewSize <= d.Size {
+		errData = &errortypes.ErrorData{
+			Error:   "size_shrink_invalid",
+			Message: "Disk size cannot be reduced",
+		}
+		return
+	}
+
 	return
 }
 
@@ -5,6 +5,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"gopkg.in/mgo.v2/bson"
+	"time"
 )
 
 func Get(db *database.Database, diskId bson.ObjectId) (dsk *Disk, err error) {
@@ -36,6 +37,34 @@ func GetOrg(db *database.Database, orgId, diskId bson.ObjectId) (
 	return
 }
 
+func SetSnapshotScheduled(db *database.Database, diskId bson.ObjectId,
+	timestamp time.Time) (scheduled bool, err error) {
+
+	coll := db.Disks()
+
+	err = coll.Update(&bson.M{
+		"_id":   diskId,
+		"state": Available,
+	}, &bson.M{
+		"$set": &bson.M{
+			"state":              Snapshot,
+			"snapshot_scheduled": true,
+			"last_snapshot":      timestamp,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		}
+		return
+	}
+
+	scheduled = true
+	return
+}
+
 func GetAll(db *database.Database, query *bson.M) (
 	disks []*Disk, err error) {
 
@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const cloudflareApi = "https://api.cloudflare.com/client/v4"
+
+type cloudflareError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type cloudflareResponse struct {
+	Success bool               `json:"success"`
+	Errors  []*cloudflareError `json:"errors"`
+	Result  json.RawMessage    `json:"result"`
+}
+
+type cloudflareZone struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type cloudflareRecord struct {
+	Id      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	Ttl     int    `json:"ttl"`
+}
+
+func cloudflareRequest(domain *Domain, method, pth string,
+	input, output interface{}) (err error) {
+
+	var body io.Reader
+	if input != nil {
+		data, e := json.Marshal(input)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "domain: Failed to marshal Cloudflare request"),
+			}
+			return
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequest(method, cloudflareApi+pth, body)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Cloudflare request failed"),
+		}
+		return
+	}
+
+	req.Header.Set("Authorization", "Bearer "+domain.CloudflareToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Cloudflare request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	respData := &cloudflareResponse{}
+	err = json.NewDecoder(resp.Body).Decode(respData)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrapf(err,
+				"domain: Failed to parse Cloudflare response %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	if !respData.Success {
+		msg := ""
+		if len(respData.Errors) > 0 {
+			msg = respData.Errors[0].Message
+		}
+
+		err = &errortypes.RequestError{
+			errors.Newf("domain: Cloudflare error %d '%s'",
+				resp.StatusCode, msg),
+		}
+		return
+	}
+
+	if output != nil {
+		err = json.Unmarshal(respData.Result, output)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "domain: Failed to parse Cloudflare result"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func cloudflareSyncRecords(domain *Domain, zoneId, recordName,
+	recordType, addr string) (err error) {
+
+	records := []*cloudflareRecord{}
+	err = cloudflareRequest(domain, "GET", fmt.Sprintf(
+		"/zones/%s/dns_records?type=%s&name=%s",
+		zoneId, recordType, url.QueryEscape(recordName)), nil, &records)
+	if err != nil {
+		return
+	}
+
+	if addr != "" && len(records) == 1 && records[0].Content == addr {
+		return
+	}
+
+	for i, record := range records {
+		if addr != "" && i == 0 {
+			err = cloudflareRequest(domain, "PUT", fmt.Sprintf(
+				"/zones/%s/dns_records/%s", zoneId, record.Id),
+				&cloudflareRecord{
+					Type:    recordType,
+					Name:    recordName,
+					Content: addr,
+					Ttl:     recordTtl,
+				}, nil)
+			if err != nil {
+				return
+			}
+
+			continue
+		}
+
+		err = cloudflareRequest(domain, "DELETE", fmt.Sprintf(
+			"/zones/%s/dns_records/%s", zoneId, record.Id), nil, nil)
+		if err != nil {
+			return
+		}
+	}
+
+	if addr != "" && len(records) == 0 {
+		err = cloudflareRequest(domain, "POST", fmt.Sprintf(
+			"/zones/%s/dns_records", zoneId),
+			&cloudflareRecord{
+				Type:    recordType,
+				Name:    recordName,
+				Content: addr,
+				Ttl:     recordTtl,
+			}, nil)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func CloudflareUpsertDomain(domain *Domain, name, addr, addr6 string) (
+	err error) {
+
+	zones := []*cloudflareZone{}
+	err = cloudflareRequest(domain, "GET",
+		"/zones?name="+url.QueryEscape(domain.Name), nil, &zones)
+	if err != nil {
+		return
+	}
+
+	if len(zones) == 0 {
+		err = &errortypes.RequestError{
+			errors.New("domain: Failed to find Cloudflare zone"),
+		}
+		return
+	}
+
+	zoneId := zones[0].Id
+	recordName := name + "." + domain.Name
+
+	err = cloudflareSyncRecords(domain, zoneId, recordName, "A", addr)
+	if err != nil {
+		return
+	}
+
+	err = cloudflareSyncRecords(domain, zoneId, recordName, "AAAA", addr6)
+	if err != nil {
+		return
+	}
+
+	return
+}
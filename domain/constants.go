@@ -1,5 +1,26 @@
 package domain
 
+import (
+	"github.com/dropbox/godropbox/container/set"
+)
+
 const (
-	Route53 = "route_53"
+	Route53    = "route_53"
+	Cloudflare = "cloudflare"
+	Rfc2136    = "rfc2136"
+	PowerDns   = "powerdns"
+
+	TsigHmacSha1   = "hmac-sha1"
+	TsigHmacSha256 = "hmac-sha256"
+	TsigHmacSha512 = "hmac-sha512"
+
+	recordTtl = 60
+)
+
+var (
+	tsigAlgorithms = set.NewSet(
+		TsigHmacSha1,
+		TsigHmacSha256,
+		TsigHmacSha512,
+	)
 )
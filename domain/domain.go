@@ -6,15 +6,24 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"gopkg.in/mgo.v2/bson"
+	"net"
+	"strings"
 )
 
 type Domain struct {
-	Id           bson.ObjectId `bson:"_id,omitempty" json:"id"`
-	Name         string        `bson:"name" json:"name"`
-	Organization bson.ObjectId `bson:"organization,omitempty" json:"organization"`
-	Type         string        `bson:"type" json:"type"`
-	AwsId        string        `bson:"aws_id" json:"aws_id"`
-	AwsSecret    string        `bson:"aws_secret" json:"aws_secret"`
+	Id              bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Name            string        `bson:"name" json:"name"`
+	Organization    bson.ObjectId `bson:"organization,omitempty" json:"organization"`
+	Type            string        `bson:"type" json:"type"`
+	AwsId           string        `bson:"aws_id" json:"aws_id"`
+	AwsSecret       string        `bson:"aws_secret" json:"aws_secret"`
+	CloudflareToken string        `bson:"cloudflare_token" json:"cloudflare_token"`
+	Rfc2136Server   string        `bson:"rfc2136_server" json:"rfc2136_server"`
+	TsigName        string        `bson:"tsig_name" json:"tsig_name"`
+	TsigAlgorithm   string        `bson:"tsig_algorithm" json:"tsig_algorithm"`
+	TsigSecret      string        `bson:"tsig_secret" json:"tsig_secret"`
+	PowerDnsUrl     string        `bson:"powerdns_url" json:"powerdns_url"`
+	PowerDnsKey     string        `bson:"powerdns_key" json:"powerdns_key"`
 }
 
 func (d *Domain) Validate(db *database.Database) (
@@ -31,7 +40,82 @@ func (d *Domain) Validate(db *database.Database) (
 	if d.Type != Route53 {
 		d.AwsId = ""
 		d.AwsSecret = ""
+	}
+	if d.Type != Cloudflare {
+		d.CloudflareToken = ""
+	}
+	if d.Type != Rfc2136 {
+		d.Rfc2136Server = ""
+		d.TsigName = ""
+		d.TsigAlgorithm = ""
+		d.TsigSecret = ""
+	}
+	if d.Type != PowerDns {
+		d.PowerDnsUrl = ""
+		d.PowerDnsKey = ""
+	}
+
+	switch d.Type {
+	case Route53:
+		break
+	case Cloudflare:
+		if d.CloudflareToken == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "cloudflare_token_required",
+				Message: "Missing required Cloudflare API token",
+			}
+			return
+		}
+		break
+	case Rfc2136:
+		if d.Rfc2136Server == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "rfc2136_server_required",
+				Message: "Missing required RFC 2136 server",
+			}
+			return
+		}
+
+		if _, _, e := net.SplitHostPort(d.Rfc2136Server); e != nil {
+			d.Rfc2136Server = net.JoinHostPort(d.Rfc2136Server, "53")
+		}
 
+		if d.TsigName != "" || d.TsigSecret != "" {
+			if d.TsigName == "" || d.TsigSecret == "" {
+				errData = &errortypes.ErrorData{
+					Error:   "tsig_key_invalid",
+					Message: "TSIG key requires both name and secret",
+				}
+				return
+			}
+
+			if d.TsigAlgorithm == "" {
+				d.TsigAlgorithm = TsigHmacSha256
+			}
+
+			if !tsigAlgorithms.Contains(d.TsigAlgorithm) {
+				errData = &errortypes.ErrorData{
+					Error:   "tsig_algorithm_invalid",
+					Message: "TSIG algorithm invalid",
+				}
+				return
+			}
+		} else {
+			d.TsigAlgorithm = ""
+		}
+		break
+	case PowerDns:
+		if d.PowerDnsUrl == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "powerdns_url_required",
+				Message: "Missing required PowerDNS API URL",
+			}
+			return
+		}
+
+		d.PowerDnsUrl = strings.TrimRight(d.PowerDnsUrl, "/")
+		break
+	default:
 		errData = &errortypes.ErrorData{
 			Error:   "unknown_type",
 			Message: "Unknown domain type",
@@ -42,6 +126,30 @@ func (d *Domain) Validate(db *database.Database) (
 	return
 }
 
+func (d *Domain) UpsertRecord(name, addr, addr6 string) (err error) {
+	switch d.Type {
+	case Route53:
+		err = AwsUpsertDomain(d, name, addr, addr6)
+		break
+	case Cloudflare:
+		err = CloudflareUpsertDomain(d, name, addr, addr6)
+		break
+	case Rfc2136:
+		err = Rfc2136UpsertDomain(d, name, addr, addr6)
+		break
+	case PowerDns:
+		err = PowerDnsUpsertDomain(d, name, addr, addr6)
+		break
+	default:
+		err = &errortypes.UnknownError{
+			errors.New("domain: Unknown domain type"),
+		}
+		break
+	}
+
+	return
+}
+
 func (d *Domain) Commit(db *database.Database) (err error) {
 	coll := db.Domains()
 
@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDomainValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		domn *Domain
+		want *Domain
+		err  string
+	}{
+		{
+			name: "organization_required",
+			domn: &Domain{Type: Route53},
+			err:  "organization_required",
+		},
+		{
+			name: "unknown_type",
+			domn: &Domain{Organization: "o", Type: "gandi"},
+			err:  "unknown_type",
+		},
+		{
+			name: "route53_clears_other",
+			domn: &Domain{
+				Organization:    "o",
+				Type:            Route53,
+				AwsId:           "id",
+				AwsSecret:       "secret",
+				CloudflareToken: "token",
+				TsigName:        "key",
+				PowerDnsUrl:     "https://dns.example.com",
+			},
+			want: &Domain{
+				Organization: "o",
+				Type:         Route53,
+				AwsId:        "id",
+				AwsSecret:    "secret",
+			},
+		},
+		{
+			name: "cloudflare_token_required",
+			domn: &Domain{Organization: "o", Type: Cloudflare, AwsId: "id"},
+			err:  "cloudflare_token_required",
+		},
+		{
+			name: "rfc2136_server_required",
+			domn: &Domain{Organization: "o", Type: Rfc2136},
+			err:  "rfc2136_server_required",
+		},
+		{
+			name: "rfc2136_default_port",
+			domn: &Domain{
+				Organization:  "o",
+				Type:          Rfc2136,
+				Rfc2136Server: "10.0.0.53",
+				TsigAlgorithm: TsigHmacSha512,
+			},
+			want: &Domain{
+				Organization:  "o",
+				Type:          Rfc2136,
+				Rfc2136Server: "10.0.0.53:53",
+			},
+		},
+		{
+			name: "rfc2136_tsig_default_algorithm",
+			domn: &Domain{
+				Organization:  "o",
+				Type:          Rfc2136,
+				Rfc2136Server: "[fd00::53]:5353",
+				TsigName:      "key.",
+				TsigSecret:    "c2VjcmV0",
+			},
+			want: &Domain{
+				Organization:  "o",
+				Type:          Rfc2136,
+				Rfc2136Server: "[fd00::53]:5353",
+				TsigName:      "key.",
+				TsigAlgorithm: TsigHmacSha256,
+				TsigSecret:    "c2VjcmV0",
+			},
+		},
+		{
+			name: "rfc2136_tsig_incomplete",
+			domn: &Domain{
+				Organization:  "o",
+				Type:          Rfc2136,
+				Rfc2136Server: "10.0.0.53",
+				TsigName:      "key.",
+			},
+			err: "tsig_key_invalid",
+		},
+		{
+			name: "rfc2136_tsig_algorithm",
+			domn: &Domain{
+				Organization:  "o",
+				Type:          Rfc2136,
+				Rfc2136Server: "10.0.0.53",
+				TsigName:      "key.",
+				TsigAlgorithm: "hmac-md5",
+				TsigSecret:    "c2VjcmV0",
+			},
+			err: "tsig_algorithm_invalid",
+		},
+		{
+			name: "powerdns_url_required",
+			domn: &Domain{Organization: "o", Type: PowerDns},
+			err:  "powerdns_url_required",
+		},
+		{
+			name: "powerdns_trim_url",
+			domn: &Domain{
+				Organization: "o",
+				Type:         PowerDns,
+				PowerDnsUrl:  "https://dns.example.com/",
+				PowerDnsKey:  "key",
+			},
+			want: &Domain{
+				Organization: "o",
+				Type:         PowerDns,
+				PowerDnsUrl:  "https://dns.example.com",
+				PowerDnsKey:  "key",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		errData, err := test.domn.Validate(nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+			continue
+		}
+
+		if test.err != "" {
+			if errData == nil || errData.Error != test.err {
+				t.Errorf("%s: error = %v, want %s",
+					test.name, errData, test.err)
+			}
+			continue
+		}
+
+		if errData != nil {
+			t.Errorf("%s: unexpected error %s", test.name, errData.Error)
+			continue
+		}
+
+		if !reflect.DeepEqual(test.domn, test.want) {
+			t.Errorf("%s: domain = %+v, want %+v",
+				test.name, test.domn, test.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+type powerDnsRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type powerDnsRrset struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Ttl        int               `json:"ttl,omitempty"`
+	ChangeType string            `json:"changetype"`
+	Records    []*powerDnsRecord `json:"records"`
+}
+
+type powerDnsPatch struct {
+	Rrsets []*powerDnsRrset `json:"rrsets"`
+}
+
+func powerDnsGetRrset(recordName, recordType, addr string) *powerDnsRrset {
+	if addr == "" {
+		return &powerDnsRrset{
+			Name:       recordName,
+			Type:       recordType,
+			ChangeType: "DELETE",
+			Records:    []*powerDnsRecord{},
+		}
+	}
+
+	return &powerDnsRrset{
+		Name:       recordName,
+		Type:       recordType,
+		Ttl:        recordTtl,
+		ChangeType: "REPLACE",
+		Records: []*powerDnsRecord{
+			&powerDnsRecord{
+				Content: addr,
+			},
+		},
+	}
+}
+
+func PowerDnsUpsertDomain(domain *Domain, name, addr, addr6 string) (
+	err error) {
+
+	zoneName := domain.Name + "."
+	recordName := name + "." + zoneName
+
+	data, err := json.Marshal(&powerDnsPatch{
+		Rrsets: []*powerDnsRrset{
+			powerDnsGetRrset(recordName, "A", addr),
+			powerDnsGetRrset(recordName, "AAAA", addr6),
+		},
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "domain: Failed to marshal PowerDNS request"),
+		}
+		return
+	}
+
+	req, err := http.NewRequest(
+		"PATCH",
+		fmt.Sprintf("%s/api/v1/servers/localhost/zones/%s",
+			domain.PowerDnsUrl, url.PathEscape(zoneName)),
+		bytes.NewBuffer(data),
+	)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: PowerDNS request failed"),
+		}
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if domain.PowerDnsKey != "" {
+		req.Header.Set("X-API-Key", domain.PowerDnsKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: PowerDNS request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 204 && resp.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		err = &errortypes.RequestError{
+			errors.Newf("domain: PowerDNS server error %d '%s'",
+				resp.StatusCode, string(body)),
+		}
+		return
+	}
+
+	return
+}
@@ -27,15 +27,8 @@ func (r *Record) Remove(db *database.Database) (err error) {
 		return
 	}
 
-	if domn.Type == Route53 {
-		err = AwsUpsertDomain(domn, r.Name, "", "")
-		if err != nil {
-			return
-		}
-	} else {
-		err = &errortypes.UnknownError{
-			errors.New("domain: Unknown domain type"),
-		}
+	err = domn.UpsertRecord(r.Name, "", "")
+	if err != nil {
 		return
 	}
 
@@ -59,15 +52,8 @@ func (r *Record) Upsert(db *database.Database, addr, addr6 string) (
 		}
 	}
 
-	if domn.Type == Route53 {
-		err = AwsUpsertDomain(domn, r.Name, addr, addr6)
-		if err != nil {
-			return
-		}
-	} else {
-		err = &errortypes.UnknownError{
-			errors.New("domain: Unknown domain type"),
-		}
+	err = domn.UpsertRecord(r.Name, addr, addr6)
+	if err != nil {
 		return
 	}
 
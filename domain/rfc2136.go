@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/miekg/dns"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"net"
+	"time"
+)
+
+func rfc2136GetRrs(recordName string, recordType uint16, addr string) (
+	remove []dns.RR, insert []dns.RR) {
+
+	hdr := dns.RR_Header{
+		Name:   recordName,
+		Rrtype: recordType,
+		Class:  dns.ClassINET,
+		Ttl:    recordTtl,
+	}
+
+	switch recordType {
+	case dns.TypeA:
+		remove = []dns.RR{&dns.A{Hdr: hdr}}
+		if addr != "" {
+			insert = []dns.RR{&dns.A{
+				Hdr: hdr,
+				A:   net.ParseIP(addr),
+			}}
+		}
+		break
+	case dns.TypeAAAA:
+		remove = []dns.RR{&dns.AAAA{Hdr: hdr}}
+		if addr != "" {
+			insert = []dns.RR{&dns.AAAA{
+				Hdr:  hdr,
+				AAAA: net.ParseIP(addr),
+			}}
+		}
+		break
+	}
+
+	return
+}
+
+func Rfc2136UpsertDomain(domain *Domain, name, addr, addr6 string) (
+	err error) {
+
+	zoneName := dns.Fqdn(domain.Name)
+	recordName := name + "." + zoneName
+
+	msg := &dns.Msg{}
+	msg.SetUpdate(zoneName)
+
+	remove, insert := rfc2136GetRrs(recordName, dns.TypeA, addr)
+	msg.RemoveRRset(remove)
+	if insert != nil {
+		msg.Insert(insert)
+	}
+
+	remove, insert = rfc2136GetRrs(recordName, dns.TypeAAAA, addr6)
+	msg.RemoveRRset(remove)
+	if insert != nil {
+		msg.Insert(insert)
+	}
+
+	clnt := &dns.Client{
+		Net:     "tcp",
+		Timeout: 20 * time.Second,
+	}
+
+	if domain.TsigName != "" {
+		tsigName := dns.Fqdn(domain.TsigName)
+		clnt.TsigSecret = map[string]string{
+			tsigName: domain.TsigSecret,
+		}
+		msg.SetTsig(tsigName, dns.Fqdn(domain.TsigAlgorithm),
+			300, time.Now().Unix())
+	}
+
+	resp, _, err := clnt.Exchange(msg, domain.Rfc2136Server)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: RFC 2136 update failed"),
+		}
+		return
+	}
+
+	if resp.Rcode != dns.RcodeSuccess {
+		err = &errortypes.RequestError{
+			errors.Newf("domain: RFC 2136 update error '%s'",
+				dns.RcodeToString[resp.Rcode]),
+		}
+		return
+	}
+
+	return
+}
@@ -4,6 +4,14 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"gopkg.in/mgo.v2/bson"
+	"net/http"
+	"time"
+)
+
+var (
+	client = &http.Client{
+		Timeout: 20 * time.Second,
+	}
 )
 
 func Get(db *database.Database, domnId bson.ObjectId) (
@@ -256,3 +264,23 @@ func RemoveRecord(db *database.Database, recrdId bson.ObjectId) (err error) {
 
 	return
 }
+
+func UpdateRecordNode(db *database.Database, instId, nodeId bson.ObjectId) (
+	err error) {
+
+	coll := db.DomainsRecord()
+
+	_, err = coll.UpdateAll(&bson.M{
+		"instance": instId,
+	}, &bson.M{
+		"$set": &bson.M{
+			"node": nodeId,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -5,4 +5,8 @@ const (
 	Icmp = "icmp"
 	Tcp  = "tcp"
 	Udp  = "udp"
+
+	Ingress = "ingress"
+	Egress  = "egress"
+	Drop    = "drop"
 )
@@ -0,0 +1,51 @@
+package firewall
+
+import (
+	"github.com/pritunl/pritunl-cloud/database"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+type Counters struct {
+	Id           bson.ObjectId    `bson:"_id"`
+	Node         bson.ObjectId    `bson:"node"`
+	Organization bson.ObjectId    `bson:"organization,omitempty"`
+	NetworkRoles []string         `bson:"network_roles"`
+	Rules        map[string]int64 `bson:"rules"`
+	Timestamp    time.Time        `bson:"timestamp"`
+}
+
+func (c *Counters) Commit(db *database.Database) (err error) {
+	coll := db.FirewallCounters()
+
+	_, err = coll.UpsertId(c.Id, c)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetCounters(db *database.Database, query *bson.M) (
+	counters []*Counters, err error) {
+
+	coll := db.FirewallCounters()
+	counters = []*Counters{}
+
+	cursor := coll.Find(query).Iter()
+
+	cntr := &Counters{}
+	for cursor.Next(cntr) {
+		counters = append(counters, cntr)
+		cntr = &Counters{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
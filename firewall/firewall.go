@@ -1,6 +1,8 @@
 package firewall
 
 import (
+	"crypto/md5"
+	"fmt"
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-cloud/database"
@@ -12,9 +14,164 @@ import (
 )
 
 type Rule struct {
-	SourceIps []string `bson:"source_ips" json:"source_ips"`
-	Protocol  string   `bson:"protocol" json:"protocol"`
-	Port      string   `bson:"port" json:"port"`
+	Id             string   `bson:"id" json:"id"`
+	SourceIps      []string `bson:"source_ips" json:"source_ips"`
+	SourceRoles    []string `bson:"source_roles" json:"source_roles"`
+	DestinationIps []string `bson:"destination_ips,omitempty" json:"destination_ips,omitempty"`
+	Protocol       string   `bson:"protocol" json:"protocol"`
+	Port           string   `bson:"port" json:"port"`
+	Log            bool     `bson:"log" json:"log"`
+	Hits           int64    `bson:"-" json:"hits"`
+	Keys           []string `bson:"-" json:"-"`
+}
+
+func (r *Rule) Name(direction string) string {
+	name := direction + "_" + r.Protocol
+	if r.Port != "" {
+		name += "_" + r.Port
+	}
+	return name
+}
+
+func portError(direction string) *errortypes.ErrorData {
+	if direction == Egress {
+		return &errortypes.ErrorData{
+			Error:   "invalid_egress_rule_port",
+			Message: "Invalid egress rule port",
+		}
+	}
+	return &errortypes.ErrorData{
+		Error:   "invalid_ingress_rule_port",
+		Message: "Invalid ingress rule port",
+	}
+}
+
+func protocolError(direction string) *errortypes.ErrorData {
+	if direction == Egress {
+		return &errortypes.ErrorData{
+			Error:   "invalid_egress_rule_protocol",
+			Message: "Invalid egress rule protocol",
+		}
+	}
+	return &errortypes.ErrorData{
+		Error:   "invalid_ingress_rule_protocol",
+		Message: "Invalid ingress rule protocol",
+	}
+}
+
+func ipError(direction string, empty bool) *errortypes.ErrorData {
+	if direction == Egress {
+		if empty {
+			return &errortypes.ErrorData{
+				Error:   "invalid_egress_rule_destination_ip",
+				Message: "Empty egress rule destination IP",
+			}
+		}
+		return &errortypes.ErrorData{
+			Error:   "invalid_egress_rule_destination_ip",
+			Message: "Invalid egress rule destination IP",
+		}
+	}
+	if empty {
+		return &errortypes.ErrorData{
+			Error:   "invalid_ingress_rule_source_ip",
+			Message: "Empty ingress rule source IP",
+		}
+	}
+	return &errortypes.ErrorData{
+		Error:   "invalid_ingress_rule_source_ip",
+		Message: "Invalid ingress rule source IP",
+	}
+}
+
+func (r *Rule) validate(direction string) (errData *errortypes.ErrorData) {
+	switch r.Protocol {
+	case All:
+		r.Port = ""
+		break
+	case Icmp:
+		r.Port = ""
+		break
+	case Tcp, Udp:
+		ports := strings.Split(r.Port, "-")
+
+		portInt, e := strconv.Atoi(ports[0])
+		if e != nil {
+			errData = portError(direction)
+			return
+		}
+
+		if portInt < 1 || portInt > 65535 {
+			errData = portError(direction)
+			return
+		}
+
+		parsedPort := strconv.Itoa(portInt)
+		if len(ports) > 1 {
+			portInt2, e := strconv.Atoi(ports[1])
+			if e != nil {
+				errData = portError(direction)
+				return
+			}
+
+			if portInt < 1 || portInt > 65535 || portInt2 <= portInt {
+				errData = portError(direction)
+				return
+			}
+
+			parsedPort += "-" + strconv.Itoa(portInt2)
+		}
+
+		r.Port = parsedPort
+
+		break
+	default:
+		errData = protocolError(direction)
+		return
+	}
+
+	ips := r.SourceIps
+	if direction == Egress {
+		ips = r.DestinationIps
+		r.SourceIps = []string{}
+		r.SourceRoles = []string{}
+	} else {
+		r.DestinationIps = nil
+
+		roles := []string{}
+		for _, role := range r.SourceRoles {
+			role = strings.TrimSpace(role)
+			if role != "" {
+				roles = append(roles, role)
+			}
+		}
+		r.SourceRoles = roles
+	}
+
+	for i, ip := range ips {
+		if ip == "" {
+			errData = ipError(direction, true)
+			return
+		}
+
+		if !strings.Contains(ip, "/") {
+			if strings.Contains(ip, ":") {
+				ip += "/128"
+			} else {
+				ip += "/32"
+			}
+		}
+
+		_, cidr, e := net.ParseCIDR(ip)
+		if e != nil {
+			errData = ipError(direction, false)
+			return
+		}
+
+		ips[i] = cidr.String()
+	}
+
+	return
 }
 
 type Firewall struct {
@@ -23,6 +180,10 @@ type Firewall struct {
 	Organization bson.ObjectId `bson:"organization,omitempty" json:"organization"`
 	NetworkRoles []string      `bson:"network_roles" json:"network_roles"`
 	Ingress      []*Rule       `bson:"ingress" json:"ingress"`
+	Egress       []*Rule       `bson:"egress" json:"egress"`
+	LogDrop      bool          `bson:"log_drop" json:"log_drop"`
+	IngressDrops int64         `bson:"-" json:"ingress_drops"`
+	EgressDrops  int64         `bson:"-" json:"egress_drops"`
 }
 
 func (f *Firewall) Validate(db *database.Database) (
@@ -36,95 +197,85 @@ func (f *Firewall) Validate(db *database.Database) (
 		f.Ingress = []*Rule{}
 	}
 
-	for _, rule := range f.Ingress {
-		switch rule.Protocol {
-		case All:
-			rule.Port = ""
-			break
-		case Icmp:
-			rule.Port = ""
-			break
-		case Tcp, Udp:
-			ports := strings.Split(rule.Port, "-")
-
-			portInt, e := strconv.Atoi(ports[0])
-			if e != nil {
-				errData = &errortypes.ErrorData{
-					Error:   "invalid_ingress_rule_port",
-					Message: "Invalid ingress rule port",
-				}
-				return
-			}
+	if f.Egress == nil {
+		f.Egress = []*Rule{}
+	}
 
-			if portInt < 1 || portInt > 65535 {
-				errData = &errortypes.ErrorData{
-					Error:   "invalid_ingress_rule_port",
-					Message: "Invalid ingress rule port",
-				}
-				return
-			}
+	ruleIds := set.NewSet()
 
-			parsedPort := strconv.Itoa(portInt)
-			if len(ports) > 1 {
-				portInt2, e := strconv.Atoi(ports[1])
-				if e != nil {
-					errData = &errortypes.ErrorData{
-						Error:   "invalid_ingress_rule_port",
-						Message: "Invalid ingress rule port",
-					}
-					return
-				}
-
-				if portInt < 1 || portInt > 65535 || portInt2 <= portInt {
-					errData = &errortypes.ErrorData{
-						Error:   "invalid_ingress_rule_port",
-						Message: "Invalid ingress rule port",
-					}
-					return
-				}
-
-				parsedPort += "-" + strconv.Itoa(portInt2)
-			}
+	for _, rule := range f.Ingress {
+		errData = rule.validate(Ingress)
+		if errData != nil {
+			return
+		}
 
-			rule.Port = parsedPort
+		if rule.Id == "" || ruleIds.Contains(rule.Id) {
+			rule.Id = bson.NewObjectId().Hex()
+		}
+		ruleIds.Add(rule.Id)
+	}
 
-			break
-		default:
-			errData = &errortypes.ErrorData{
-				Error:   "invalid_ingress_rule_protocol",
-				Message: "Invalid ingress rule protocol",
-			}
+	for _, rule := range f.Egress {
+		errData = rule.validate(Egress)
+		if errData != nil {
 			return
 		}
 
-		for i, sourceIp := range rule.SourceIps {
-			if sourceIp == "" {
-				errData = &errortypes.ErrorData{
-					Error:   "invalid_ingress_rule_source_ip",
-					Message: "Empty ingress rule source IP",
-				}
-				return
-			}
+		if rule.Id == "" || ruleIds.Contains(rule.Id) {
+			rule.Id = bson.NewObjectId().Hex()
+		}
+		ruleIds.Add(rule.Id)
+	}
 
-			if !strings.Contains(sourceIp, "/") {
-				if strings.Contains(sourceIp, ":") {
-					sourceIp += "/128"
-				} else {
-					sourceIp += "/32"
-				}
-			}
+	return
+}
 
-			_, sourceCidr, e := net.ParseCIDR(sourceIp)
-			if e != nil {
-				errData = &errortypes.ErrorData{
-					Error:   "invalid_ingress_rule_source_ip",
-					Message: "Invalid ingress rule source IP",
-				}
-				return
-			}
+func (f *Firewall) RuleKey(rule *Rule) string {
+	if rule.Id == "" {
+		return ""
+	}
+
+	hash := md5.New()
+	hash.Write([]byte(f.Id.Hex()))
+	hash.Write([]byte(rule.Id))
+	return fmt.Sprintf("%x", hash.Sum(nil))[:12]
+}
 
-			rule.SourceIps[i] = sourceCidr.String()
+func (f *Firewall) LoadCounters(db *database.Database) (err error) {
+	query := &bson.M{
+		"network_roles": &bson.M{
+			"$in": f.NetworkRoles,
+		},
+	}
+
+	if f.Organization != "" {
+		(*query)["organization"] = f.Organization
+	} else {
+		(*query)["organization"] = &bson.M{
+			"$exists": false,
+		}
+	}
+
+	counters, err := GetCounters(db, query)
+	if err != nil {
+		return
+	}
+
+	for _, cntr := range counters {
+		for _, rule := range f.Ingress {
+			key := f.RuleKey(rule)
+			if key != "" {
+				rule.Hits += cntr.Rules[key]
+			}
+		}
+		for _, rule := range f.Egress {
+			key := f.RuleKey(rule)
+			if key != "" {
+				rule.Hits += cntr.Rules[key]
+			}
 		}
+		f.IngressDrops += cntr.Rules[Ingress+"_"+Drop]
+		f.EgressDrops += cntr.Rules[Egress+"_"+Drop]
 	}
 
 	return
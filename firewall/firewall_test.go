@@ -0,0 +1,306 @@
+package firewall
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction string
+		rule      *Rule
+		port      string
+		ips       []string
+		err       string
+	}{
+		{
+			name:      "ingress_tcp",
+			direction: Ingress,
+			rule: &Rule{
+				Protocol:  Tcp,
+				Port:      "22",
+				SourceIps: []string{"10.0.0.1", "10.1.0.0/16"},
+			},
+			port: "22",
+			ips:  []string{"10.0.0.1/32", "10.1.0.0/16"},
+		},
+		{
+			name:      "ingress_port_range",
+			direction: Ingress,
+			rule: &Rule{
+				Protocol:  Udp,
+				Port:      "1000-2000",
+				SourceIps: []string{"fd00::1"},
+			},
+			port: "1000-2000",
+			ips:  []string{"fd00::1/128"},
+		},
+		{
+			name:      "ingress_icmp_clears_port",
+			direction: Ingress,
+			rule: &Rule{
+				Protocol:  Icmp,
+				Port:      "22",
+				SourceIps: []string{"0.0.0.0/0"},
+			},
+			port: "",
+			ips:  []string{"0.0.0.0/0"},
+		},
+		{
+			name:      "egress_tcp",
+			direction: Egress,
+			rule: &Rule{
+				Protocol:       Tcp,
+				Port:           "443",
+				SourceIps:      []string{"10.0.0.1"},
+				DestinationIps: []string{"192.168.1.10"},
+			},
+			port: "443",
+			ips:  []string{"192.168.1.10/32"},
+		},
+		{
+			name:      "ingress_invalid_port",
+			direction: Ingress,
+			rule: &Rule{
+				Protocol: Tcp,
+				Port:     "ssh",
+			},
+			err: "invalid_ingress_rule_port",
+		},
+		{
+			name:      "egress_invalid_port_range",
+			direction: Egress,
+			rule: &Rule{
+				Protocol: Tcp,
+				Port:     "2000-1000",
+			},
+			err: "invalid_egress_rule_port",
+		},
+		{
+			name:      "egress_invalid_protocol",
+			direction: Egress,
+			rule: &Rule{
+				Protocol: "gre",
+			},
+			err: "invalid_egress_rule_protocol",
+		},
+		{
+			name:      "ingress_empty_ip",
+			direction: Ingress,
+			rule: &Rule{
+				Protocol:  All,
+				SourceIps: []string{""},
+			},
+			err: "invalid_ingress_rule_source_ip",
+		},
+		{
+			name:      "egress_invalid_ip",
+			direction: Egress,
+			rule: &Rule{
+				Protocol:       All,
+				DestinationIps: []string{"10.0.0.300"},
+			},
+			err: "invalid_egress_rule_destination_ip",
+		},
+	}
+
+	for _, test := range tests {
+		errData := test.rule.validate(test.direction)
+		if test.err != "" {
+			if errData == nil || errData.Error != test.err {
+				t.Errorf("%s: error = %v, want %s",
+					test.name, errData, test.err)
+			}
+			continue
+		}
+
+		if errData != nil {
+			t.Errorf("%s: unexpected error %s", test.name, errData.Error)
+			continue
+		}
+
+		if test.rule.Port != test.port {
+			t.Errorf("%s: port = %q, want %q",
+				test.name, test.rule.Port, test.port)
+		}
+
+		ips := test.rule.SourceIps
+		if test.direction == Egress {
+			ips = test.rule.DestinationIps
+
+			if len(test.rule.SourceIps) != 0 {
+				t.Errorf("%s: egress source ips not cleared", test.name)
+			}
+		}
+
+		if !reflect.DeepEqual(ips, test.ips) {
+			t.Errorf("%s: ips = %v, want %v", test.name, ips, test.ips)
+		}
+	}
+}
+
+func TestMergeEgress(t *testing.T) {
+	fireA := &Firewall{
+		Id: "aaaaaaaaaaaa",
+		Egress: []*Rule{
+			&Rule{
+				Id:             "a1",
+				Protocol:       Tcp,
+				Port:           "443",
+				DestinationIps: []string{"10.0.0.0/8"},
+			},
+			&Rule{
+				Id:             "a2",
+				Protocol:       Udp,
+				Port:           "53",
+				DestinationIps: []string{"10.0.0.2/32"},
+			},
+			&Rule{
+				Protocol:       Udp,
+				Port:           "123",
+				DestinationIps: []string{"10.0.0.3/32"},
+			},
+		},
+	}
+	fireB := &Firewall{
+		Id: "bbbbbbbbbbbb",
+		Egress: []*Rule{
+			&Rule{
+				Id:             "b1",
+				Protocol:       Tcp,
+				Port:           "443",
+				DestinationIps: []string{"10.0.0.0/8", "172.16.0.0/12"},
+				Log:            true,
+			},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		fires []*Firewall
+		rules []*Rule
+	}{
+		{
+			name:  "empty",
+			fires: []*Firewall{},
+			rules: []*Rule{},
+		},
+		{
+			name:  "single",
+			fires: []*Firewall{fireB},
+			rules: []*Rule{
+				&Rule{
+					Protocol:       Tcp,
+					Port:           "443",
+					DestinationIps: []string{"10.0.0.0/8", "172.16.0.0/12"},
+					Log:            true,
+					Keys:           []string{fireB.RuleKey(fireB.Egress[0])},
+				},
+			},
+		},
+		{
+			name:  "merged",
+			fires: []*Firewall{fireA, fireB},
+			rules: []*Rule{
+				&Rule{
+					Protocol:       Tcp,
+					Port:           "443",
+					DestinationIps: []string{"10.0.0.0/8", "172.16.0.0/12"},
+					Log:            true,
+					Keys: []string{
+						fireA.RuleKey(fireA.Egress[0]),
+						fireB.RuleKey(fireB.Egress[0]),
+					},
+				},
+				&Rule{
+					Protocol:       Udp,
+					Port:           "123",
+					DestinationIps: []string{"10.0.0.3/32"},
+					Keys:           []string{},
+				},
+				&Rule{
+					Protocol:       Udp,
+					Port:           "53",
+					DestinationIps: []string{"10.0.0.2/32"},
+					Keys:           []string{fireA.RuleKey(fireA.Egress[1])},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		rules := MergeEgress(test.fires)
+		if !reflect.DeepEqual(rules, test.rules) {
+			t.Errorf("%s: rules = %+v, want %+v",
+				test.name, rules, test.rules)
+		}
+	}
+}
+
+func TestRuleKey(t *testing.T) {
+	fire := &Firewall{
+		Id: "aaaaaaaaaaaa",
+		Ingress: []*Rule{
+			&Rule{
+				Protocol:  Tcp,
+				Port:      "22",
+				SourceIps: []string{"10.0.0.0/8"},
+			},
+			&Rule{
+				Protocol:  Tcp,
+				Port:      "80",
+				SourceIps: []string{"10.0.0.0/8"},
+			},
+		},
+		Egress: []*Rule{
+			&Rule{
+				Protocol:       Udp,
+				Port:           "53",
+				DestinationIps: []string{"10.0.0.2/32"},
+			},
+		},
+	}
+
+	if key := fire.RuleKey(fire.Ingress[0]); key != "" {
+		t.Errorf("legacy key = %q, want empty", key)
+	}
+
+	errData, err := fire.Validate(nil)
+	if err != nil || errData != nil {
+		t.Fatalf("validate: %v %v", err, errData)
+	}
+
+	fire.Egress[0].Id = fire.Ingress[0].Id
+	errData, err = fire.Validate(nil)
+	if err != nil || errData != nil {
+		t.Fatalf("validate: %v %v", err, errData)
+	}
+
+	keys := map[string]*Rule{}
+	for _, rule := range append(fire.Ingress, fire.Egress...) {
+		key := fire.RuleKey(rule)
+		if key == "" {
+			t.Errorf("rule %s: empty key", rule.Port)
+		}
+		if keys[key] != nil {
+			t.Errorf("rule %s: duplicate key %s", rule.Port, key)
+		}
+		keys[key] = rule
+	}
+
+	fire.Ingress[0], fire.Ingress[1] = fire.Ingress[1], fire.Ingress[0]
+	for key, rule := range keys {
+		if fire.RuleKey(rule) != key {
+			t.Errorf("rule %s: key changed after reorder", rule.Port)
+		}
+	}
+
+	other := &Firewall{
+		Id:      "bbbbbbbbbbbb",
+		Ingress: fire.Ingress,
+	}
+	if other.RuleKey(fire.Ingress[0]) == fire.RuleKey(fire.Ingress[0]) {
+		t.Errorf("key not scoped to firewall")
+	}
+}
@@ -275,17 +275,44 @@ func MergeIngress(fires []*Firewall) (rules []*Rule) {
 
 	for _, fire := range fires {
 		for _, ingress := range fire.Ingress {
+			ruleKeys := []string{}
+			ruleKey := fire.RuleKey(ingress)
+			if ruleKey != "" {
+				ruleKeys = append(ruleKeys, ruleKey)
+			}
+
 			key := fmt.Sprintf("%s-%s", ingress.Protocol, ingress.Port)
 			rule := rulesMap[key]
 			if rule == nil {
 				rule = &Rule{
-					Protocol:  ingress.Protocol,
-					Port:      ingress.Port,
-					SourceIps: ingress.SourceIps,
+					Protocol:    ingress.Protocol,
+					Port:        ingress.Port,
+					SourceIps:   ingress.SourceIps,
+					SourceRoles: ingress.SourceRoles,
+					Log:         ingress.Log,
+					Keys:        ruleKeys,
 				}
 				rulesMap[key] = rule
 				rulesKey = append(rulesKey, key)
 			} else {
+				if ingress.Log {
+					rule.Log = true
+				}
+				rule.Keys = append(rule.Keys, ruleKeys...)
+
+				sourceRoles := set.NewSet()
+				for _, sourceRole := range rule.SourceRoles {
+					sourceRoles.Add(sourceRole)
+				}
+
+				for _, sourceRole := range ingress.SourceRoles {
+					if sourceRoles.Contains(sourceRole) {
+						continue
+					}
+					sourceRoles.Add(sourceRole)
+					rule.SourceRoles = append(rule.SourceRoles, sourceRole)
+				}
+
 				sourceIps := set.NewSet()
 				for _, sourceIp := range rule.SourceIps {
 					sourceIps.Add(sourceIp)
@@ -309,3 +336,69 @@ func MergeIngress(fires []*Firewall) (rules []*Rule) {
 
 	return
 }
+
+func MergeEgress(fires []*Firewall) (rules []*Rule) {
+	rules = []*Rule{}
+	rulesMap := map[string]*Rule{}
+	rulesKey := []string{}
+
+	for _, fire := range fires {
+		for _, egress := range fire.Egress {
+			ruleKeys := []string{}
+			ruleKey := fire.RuleKey(egress)
+			if ruleKey != "" {
+				ruleKeys = append(ruleKeys, ruleKey)
+			}
+
+			key := fmt.Sprintf("%s-%s", egress.Protocol, egress.Port)
+			rule := rulesMap[key]
+			if rule == nil {
+				rule = &Rule{
+					Protocol:       egress.Protocol,
+					Port:           egress.Port,
+					DestinationIps: egress.DestinationIps,
+					Log:            egress.Log,
+					Keys:           ruleKeys,
+				}
+				rulesMap[key] = rule
+				rulesKey = append(rulesKey, key)
+			} else {
+				if egress.Log {
+					rule.Log = true
+				}
+				rule.Keys = append(rule.Keys, ruleKeys...)
+
+				destIps := set.NewSet()
+				for _, destIp := range rule.DestinationIps {
+					destIps.Add(destIp)
+				}
+
+				for _, destIp := range egress.DestinationIps {
+					if destIps.Contains(destIp) {
+						continue
+					}
+					destIps.Add(destIp)
+					rule.DestinationIps = append(
+						rule.DestinationIps, destIp)
+				}
+			}
+		}
+	}
+
+	sort.Strings(rulesKey)
+	for _, key := range rulesKey {
+		rules = append(rules, rulesMap[key])
+	}
+
+	return
+}
+
+func MergeLogDrop(fires []*Firewall) bool {
+	for _, fire := range fires {
+		if fire.LogDrop {
+			return true
+		}
+	}
+
+	return false
+}
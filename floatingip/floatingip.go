@@ -0,0 +1,246 @@
+package floatingip
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"gopkg.in/mgo.v2/bson"
+	"net"
+	"strings"
+)
+
+type FloatingIp struct {
+	Id           bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Name         string        `bson:"name" json:"name"`
+	Organization bson.ObjectId `bson:"organization,omitempty" json:"organization"`
+	Zone         bson.ObjectId `bson:"zone" json:"zone"`
+	Node         bson.ObjectId `bson:"node,omitempty" json:"node"`
+	Instance     bson.ObjectId `bson:"instance,omitempty" json:"instance"`
+	Address      string        `bson:"address" json:"address"`
+	Gateway      string        `bson:"gateway" json:"gateway"`
+	Address6     string        `bson:"address6" json:"address6"`
+	Gateway6     string        `bson:"gateway6" json:"gateway6"`
+}
+
+func (f *FloatingIp) GetAddress() string {
+	return strings.Split(f.Address, "/")[0]
+}
+
+func (f *FloatingIp) GetAddress6() string {
+	return strings.Split(f.Address6, "/")[0]
+}
+
+func (f *FloatingIp) GetVirt() *vm.FloatingIp {
+	return &vm.FloatingIp{
+		Address:  f.Address,
+		Gateway:  f.Gateway,
+		Address6: f.Address6,
+		Gateway6: f.Gateway6,
+	}
+}
+
+func (f *FloatingIp) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if f.Zone == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "zone_required",
+			Message: "Missing required zone",
+		}
+		return
+	}
+
+	addr, addrNet, e := net.ParseCIDR(f.Address)
+	if e != nil || addr.To4() == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "address_invalid",
+			Message: "Address must be an IPv4 address with network prefix",
+		}
+		return
+	}
+	f.Address = (&net.IPNet{
+		IP:   addr.To4(),
+		Mask: addrNet.Mask,
+	}).String()
+
+	gateway := net.ParseIP(f.Gateway)
+	if gateway == nil || !addrNet.Contains(gateway) || gateway.Equal(addr) {
+		errData = &errortypes.ErrorData{
+			Error:   "gateway_invalid",
+			Message: "Gateway must be an address in the network",
+		}
+		return
+	}
+	f.Gateway = gateway.String()
+
+	if f.Address6 != "" {
+		addr6, addrNet6, e := net.ParseCIDR(f.Address6)
+		if e != nil || addr6.To4() != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "address6_invalid",
+				Message: "IPv6 address must include network prefix",
+			}
+			return
+		}
+		f.Address6 = (&net.IPNet{
+			IP:   addr6,
+			Mask: addrNet6.Mask,
+		}).String()
+
+		gateway6 := net.ParseIP(f.Gateway6)
+		if gateway6 == nil || gateway6.To4() != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "gateway6_invalid",
+				Message: "Missing required IPv6 gateway",
+			}
+			return
+		}
+		f.Gateway6 = gateway6.String()
+	} else {
+		f.Gateway6 = ""
+	}
+
+	if f.Node != "" {
+		nde, e := node.Get(db, f.Node)
+		if e != nil {
+			err = e
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = nil
+				errData = &errortypes.ErrorData{
+					Error:   "node_not_found",
+					Message: "Node does not exist",
+				}
+			}
+			return
+		}
+
+		if nde.Zone != f.Zone {
+			errData = &errortypes.ErrorData{
+				Error:   "node_zone_invalid",
+				Message: "Node is not in floating IP zone",
+			}
+			return
+		}
+	}
+
+	if f.Organization == "" {
+		f.Name = ""
+		f.Instance = ""
+	}
+
+	if f.Instance != "" {
+		inst, e := instance.Get(db, f.Instance)
+		if e != nil {
+			err = e
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = nil
+				errData = &errortypes.ErrorData{
+					Error:   "instance_not_found",
+					Message: "Instance does not exist",
+				}
+			}
+			return
+		}
+
+		if inst.Organization != f.Organization {
+			errData = &errortypes.ErrorData{
+				Error:   "instance_not_found",
+				Message: "Instance does not exist",
+			}
+			return
+		}
+
+		if inst.Zone != f.Zone {
+			errData = &errortypes.ErrorData{
+				Error:   "instance_zone_invalid",
+				Message: "Instance is not in floating IP zone",
+			}
+			return
+		}
+
+		if f.Node != "" && inst.Node != f.Node {
+			errData = &errortypes.ErrorData{
+				Error:   "instance_node_invalid",
+				Message: "Instance is not on floating IP node",
+			}
+			return
+		}
+
+		if inst.PrivateOnly {
+			errData = &errortypes.ErrorData{
+				Error:   "instance_private_only",
+				Message: "Private only instance cannot have a floating IP",
+			}
+			return
+		}
+
+		coll := db.FloatingIps()
+		n, e := coll.Find(&bson.M{
+			"_id": &bson.M{
+				"$ne": f.Id,
+			},
+			"instance": f.Instance,
+		}).Count()
+		if e != nil {
+			err = database.ParseError(e)
+			return
+		}
+
+		if n > 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "instance_floating_ip_exists",
+				Message: "Instance already has a floating IP attached",
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (f *FloatingIp) Commit(db *database.Database) (err error) {
+	coll := db.FloatingIps()
+
+	err = coll.Commit(f.Id, f)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (f *FloatingIp) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.FloatingIps()
+
+	err = coll.CommitFields(f.Id, f, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (f *FloatingIp) Insert(db *database.Database) (err error) {
+	coll := db.FloatingIps()
+
+	if f.Id != "" {
+		err = &errortypes.DatabaseError{
+			errors.New("floatingip: Floating IP already exists"),
+		}
+		return
+	}
+
+	err = coll.Insert(f)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,216 @@
+package floatingip
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func Get(db *database.Database, fipId bson.ObjectId) (
+	fip *FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fip = &FloatingIp{}
+
+	err = coll.FindOneId(fipId, fip)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, fipId bson.ObjectId) (
+	fip *FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fip = &FloatingIp{}
+
+	err = coll.FindOne(&bson.M{
+		"_id":          fipId,
+		"organization": orgId,
+	}, fip)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	fips []*FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fips = []*FloatingIp{}
+
+	cursor := coll.Find(query).Iter()
+
+	fip := &FloatingIp{}
+	for cursor.Next(fip) {
+		fips = append(fips, fip)
+		fip = &FloatingIp{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M, page, pageCount int) (
+	fips []*FloatingIp, count int, err error) {
+
+	coll := db.FloatingIps()
+	fips = []*FloatingIp{}
+
+	qury := coll.Find(query)
+
+	count, err = qury.Count()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	skip := utils.Min(page*pageCount, utils.Max(0, count-pageCount))
+
+	cursor := qury.Sort("address").Skip(skip).Limit(pageCount).Iter()
+
+	fip := &FloatingIp{}
+	for cursor.Next(fip) {
+		fips = append(fips, fip)
+		fip = &FloatingIp{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Allocate(db *database.Database, orgId, zoneId bson.ObjectId,
+	name string) (fip *FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fip = &FloatingIp{}
+
+	change := mgo.Change{
+		Update: &bson.M{
+			"$set": &bson.M{
+				"organization": orgId,
+				"name":         name,
+			},
+		},
+		ReturnNew: true,
+	}
+
+	_, err = coll.Find(&bson.M{
+		"zone":         zoneId,
+		"organization": nil,
+	}).Sort("address").Apply(change, fip)
+	if err != nil {
+		fip = nil
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = &errortypes.NotFoundError{
+				errors.New("floatingip: No floating IPs available in zone"),
+			}
+		}
+		return
+	}
+
+	return
+}
+
+func ReleaseOrg(db *database.Database, orgId, fipId bson.ObjectId) (
+	err error) {
+
+	coll := db.FloatingIps()
+
+	err = coll.Update(&bson.M{
+		"_id":          fipId,
+		"organization": orgId,
+	}, &bson.M{
+		"$set": &bson.M{
+			"name": "",
+		},
+		"$unset": &bson.M{
+			"organization": 1,
+			"instance":     1,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func DetachInstance(db *database.Database, instId bson.ObjectId) (
+	err error) {
+
+	coll := db.FloatingIps()
+
+	_, err = coll.UpdateAll(&bson.M{
+		"instance": instId,
+	}, &bson.M{
+		"$unset": &bson.M{
+			"instance": 1,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, fipId bson.ObjectId) (err error) {
+	coll := db.FloatingIps()
+
+	err = coll.Remove(&bson.M{
+		"_id": fipId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, fipIds []bson.ObjectId) (err error) {
+	coll := db.FloatingIps()
+
+	_, err = coll.RemoveAll(&bson.M{
+		"_id": &bson.M{
+			"$in": fipIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
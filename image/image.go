@@ -18,6 +18,11 @@ type Image struct {
 	Key          string        `bson:"key" json:"key"`
 	LastModified time.Time     `bson:"last_modified" json:"last_modified"`
 	Etag         string        `bson:"etag" json:"etag"`
+	Disk         bson.ObjectId `bson:"disk,omitempty" json:"disk"`
+	Scheduled    bool          `bson:"scheduled" json:"scheduled"`
+	Parent       bson.ObjectId `bson:"parent,omitempty" json:"parent"`
+	Depth        int           `bson:"depth" json:"depth"`
+	Deleted      bool          `bson:"deleted" json:"deleted"`
 }
 
 func (i *Image) Validate(db *database.Database) (
@@ -135,6 +135,97 @@ func GetAll(db *database.Database, query *bson.M, page, pageCount int) (
 	return
 }
 
+func GetScheduled(db *database.Database, diskId bson.ObjectId) (
+	imgs []*Image, err error) {
+
+	coll := db.Images()
+	imgs = []*Image{}
+
+	cursor := coll.Find(&bson.M{
+		"disk":      diskId,
+		"scheduled": true,
+		"deleted": &bson.M{
+			"$ne": true,
+		},
+	}).Sort("-_id").Iter()
+
+	img := &Image{}
+	for cursor.Next(img) {
+		imgs = append(imgs, img)
+		img = &Image{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetDiskLatest(db *database.Database, diskId bson.ObjectId) (
+	img *Image, err error) {
+
+	coll := db.Images()
+	img = &Image{}
+
+	err = coll.Find(&bson.M{
+		"disk": diskId,
+		"deleted": &bson.M{
+			"$ne": true,
+		},
+	}).Sort("-_id").One(img)
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			img = nil
+			err = nil
+		}
+		return
+	}
+
+	return
+}
+
+func GetDiskKeys(db *database.Database, diskId bson.ObjectId) (
+	keys []string, err error) {
+
+	coll := db.Images()
+	keys = []string{}
+
+	err = coll.Find(&bson.M{
+		"disk": diskId,
+	}).Distinct("key", &keys)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func HasChildren(db *database.Database, imgId bson.ObjectId) (
+	exists bool, err error) {
+
+	coll := db.Images()
+
+	n, err := coll.Find(&bson.M{
+		"parent": imgId,
+	}).Count()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if n > 0 {
+		exists = true
+	}
+
+	return
+}
+
 func GetAllNames(db *database.Database, query *bson.M) (
 	images []*Image, err error) {
 
@@ -6,4 +6,13 @@ const (
 	Stop      = "stop"
 	Restart   = "restart"
 	Destroy   = "destroy"
+	Migrate   = "migrate"
+
+	MigratePending  = "pending"
+	MigrateIncoming = "incoming"
+	MigrateReady    = "ready"
+	MigrateComplete = "complete"
+	MigrateFailed   = "failed"
+
+	MaxNetworkAdapters = 4
 )
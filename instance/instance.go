@@ -6,7 +6,9 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/placement"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/vpc"
 	"gopkg.in/mgo.v2/bson"
@@ -14,28 +16,59 @@ import (
 )
 
 type Instance struct {
-	Id           bson.ObjectId      `bson:"_id,omitempty" json:"id"`
-	Organization bson.ObjectId      `bson:"organization" json:"organization"`
-	Zone         bson.ObjectId      `bson:"zone" json:"zone"`
-	Vpc          bson.ObjectId      `bson:"vpc" json:"vpc"`
-	Image        bson.ObjectId      `bson:"image" json:"image"`
-	Status       string             `bson:"-" json:"status"`
-	State        string             `bson:"state" json:"state"`
-	VmState      string             `bson:"vm_state" json:"vm_state"`
-	Restart      bool               `bson:"restart" json:"restart"`
-	PublicIps    []string           `bson:"public_ips" json:"public_ips"`
-	PublicIps6   []string           `bson:"public_ips6" json:"public_ips6"`
-	PrivateIps   []string           `bson:"private_ips" json:"private_ips"`
-	PrivateIps6  []string           `bson:"private_ips6" json:"private_ips6"`
-	Node         bson.ObjectId      `bson:"node" json:"node"`
-	Domain       bson.ObjectId      `bson:"domain,omitempty" json:"domain"`
-	Name         string             `bson:"name" json:"name"`
-	InitDiskSize int                `bson:"init_disk_size" json:"init_disk_size"`
-	Memory       int                `bson:"memory" json:"memory"`
-	Processors   int                `bson:"processors" json:"processors"`
-	NetworkRoles []string           `bson:"network_roles" json:"network_roles"`
-	Virt         *vm.VirtualMachine `bson:"-" json:"-"`
-	curVpc       bson.ObjectId      `bson:"-" json:"-"`
+	Id                 bson.ObjectId      `bson:"_id,omitempty" json:"id"`
+	Organization       bson.ObjectId      `bson:"organization" json:"organization"`
+	Zone               bson.ObjectId      `bson:"zone" json:"zone"`
+	Vpc                bson.ObjectId      `bson:"vpc" json:"vpc"`
+	SecondaryVpcs      []bson.ObjectId    `bson:"secondary_vpcs" json:"secondary_vpcs"`
+	Image              bson.ObjectId      `bson:"image" json:"image"`
+	Status             string             `bson:"-" json:"status"`
+	State              string             `bson:"state" json:"state"`
+	VmState            string             `bson:"vm_state" json:"vm_state"`
+	Restart            bool               `bson:"restart" json:"restart"`
+	PublicIps          []string           `bson:"public_ips" json:"public_ips"`
+	PublicIps6         []string           `bson:"public_ips6" json:"public_ips6"`
+	PrivateIps         []string           `bson:"private_ips" json:"private_ips"`
+	PrivateIps6        []string           `bson:"private_ips6" json:"private_ips6"`
+	Node               bson.ObjectId      `bson:"node" json:"node"`
+	Domain             bson.ObjectId      `bson:"domain,omitempty" json:"domain"`
+	PlacementGroup     bson.ObjectId      `bson:"placement_group,omitempty" json:"placement_group"`
+	PlacementViolation bool               `bson:"-" json:"placement_violation"`
+	Name               string             `bson:"name" json:"name"`
+	InitDiskSize       int                `bson:"init_disk_size" json:"init_disk_size"`
+	Memory             int                `bson:"memory" json:"memory"`
+	Processors         int                `bson:"processors" json:"processors"`
+	NetworkRoles       []string           `bson:"network_roles" json:"network_roles"`
+	Vnc                bool               `bson:"vnc" json:"vnc"`
+	PrivateOnly        bool               `bson:"private_only" json:"private_only"`
+	UserData           string             `bson:"user_data" json:"user_data"`
+	DnsServers         []string           `bson:"dns_servers" json:"dns_servers"`
+	SearchDomains      []string           `bson:"search_domains" json:"search_domains"`
+	AutoRecover        bool               `bson:"auto_recover" json:"auto_recover"`
+	MigrateNode        bson.ObjectId      `bson:"migrate_node,omitempty" json:"migrate_node"`
+	MigrateState       string             `bson:"migrate_state" json:"migrate_state"`
+	MigrateAddr        string             `bson:"migrate_addr" json:"-"`
+	MigrateNbdAddr     string             `bson:"migrate_nbd_addr" json:"-"`
+	MigrateTls         *MigrateTls        `bson:"migrate_tls" json:"-"`
+	MigrateDisks       []*MigrateDisk     `bson:"migrate_disks" json:"-"`
+	Virt               *vm.VirtualMachine `bson:"-" json:"-"`
+	curVpcs            []bson.ObjectId    `bson:"-" json:"-"`
+	curPlacementGroup  bson.ObjectId      `bson:"-" json:"-"`
+	curState           string             `bson:"-" json:"-"`
+	curProcessors      int                `bson:"-" json:"-"`
+	curMemory          int                `bson:"-" json:"-"`
+}
+
+type MigrateDisk struct {
+	Id    bson.ObjectId `bson:"id" json:"id"`
+	Index int           `bson:"index" json:"index"`
+	Size  int64         `bson:"size" json:"size"`
+}
+
+type MigrateTls struct {
+	Ca   string `bson:"ca" json:"ca"`
+	Cert string `bson:"cert" json:"cert"`
+	Key  string `bson:"key" json:"key"`
 }
 
 func (i *Instance) Validate(db *database.Database) (
@@ -84,6 +117,38 @@ func (i *Instance) Validate(db *database.Database) (
 		}
 	}
 
+	secondaryVpcs := []bson.ObjectId{}
+	secondaryVpcsSet := set.NewSet(i.Vpc)
+	for _, vpcId := range i.SecondaryVpcs {
+		if vpcId == "" || secondaryVpcsSet.Contains(vpcId) {
+			continue
+		}
+		secondaryVpcsSet.Add(vpcId)
+		secondaryVpcs = append(secondaryVpcs, vpcId)
+	}
+	i.SecondaryVpcs = secondaryVpcs
+
+	if len(i.SecondaryVpcs)+1 > MaxNetworkAdapters {
+		errData = &errortypes.ErrorData{
+			Error:   "secondary_vpcs_invalid",
+			Message: "Too many VPC network adapters",
+		}
+	}
+
+	if i.PrivateOnly && i.Domain != "" {
+		errData = &errortypes.ErrorData{
+			Error:   "private_only_domain",
+			Message: "Private only instance cannot have a domain",
+		}
+	}
+
+	if i.State == Migrate && i.MigrateNode == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_required",
+			Message: "Missing required migration node",
+		}
+	}
+
 	if i.InitDiskSize != 0 && i.InitDiskSize < 10 {
 		errData = &errortypes.ErrorData{
 			Error:   "init_disk_size_invalid",
@@ -91,13 +156,24 @@ func (i *Instance) Validate(db *database.Database) (
 		}
 	}
 
-	if i.Memory < 256 {
-		i.Memory = 256
+	userDataErr := ValidateUserData(i.UserData)
+	if userDataErr != nil {
+		errData = userDataErr
 	}
 
-	if i.Processors < 1 {
-		i.Processors = 1
+	dnsServers, dnsErr := vpc.FormatDnsServers(i.DnsServers)
+	if dnsErr != nil {
+		errData = dnsErr
+	}
+	i.DnsServers = dnsServers
+
+	searchDomains, dnsErr := vpc.FormatSearchDomains(i.SearchDomains)
+	if dnsErr != nil {
+		errData = dnsErr
 	}
+	i.SearchDomains = searchDomains
+
+	i.DefaultResources()
 
 	if i.NetworkRoles == nil {
 		i.NetworkRoles = []string{}
@@ -119,9 +195,34 @@ func (i *Instance) Validate(db *database.Database) (
 		i.PrivateIps6 = []string{}
 	}
 
+	if errData != nil {
+		return
+	}
+
+	// Moves are checked when the migration is started
+	if i.PlacementGroup != "" &&
+		(i.Id == "" || i.PlacementGroup != i.curPlacementGroup) {
+
+		errData, err = placement.Check(db, i.Organization,
+			i.PlacementGroup, i.Id, i.Node, i.Zone)
+		if err != nil || errData != nil {
+			return
+		}
+	}
+
 	return
 }
 
+func (i *Instance) DefaultResources() {
+	if i.Memory < 256 {
+		i.Memory = 256
+	}
+
+	if i.Processors < 1 {
+		i.Processors = 1
+	}
+}
+
 func (i *Instance) Format() {
 	// TODO Sort VPC IDs
 }
@@ -188,21 +289,175 @@ func (i *Instance) Json() {
 	case Destroy:
 		i.Status = "Destroying"
 		break
+	case Migrate:
+		i.Status = "Migrating"
+		break
 	}
 }
 
 func (i *Instance) IsActive() bool {
-	return i.State == Start || i.VmState == vm.Running ||
+	return i.State == Start || i.State == Migrate ||
+		i.VmState == vm.Running ||
 		i.VmState == vm.Starting || i.VmState == vm.Provisioning
 }
 
+func (i *Instance) SetMigrate(db *database.Database, nodeId bson.ObjectId) (
+	errData *errortypes.ErrorData, err error) {
+
+	if i.State == Migrate {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_migrating",
+			Message: "Instance is already migrating",
+		}
+		return
+	}
+
+	if i.State != Start || i.VmState != vm.Running {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_not_running",
+			Message: "Instance must be running to migrate",
+		}
+		return
+	}
+
+	if nodeId == "" || nodeId == i.Node {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_invalid",
+			Message: "Invalid migration node",
+		}
+		return
+	}
+
+	nde, err := node.Get(db, nodeId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "migrate_node_invalid",
+				Message: "Invalid migration node",
+			}
+		}
+		return
+	}
+
+	if nde.Zone != i.Zone || !nde.IsHypervisor() {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_invalid",
+			Message: "Migration node must be a hypervisor in the same zone",
+		}
+		return
+	}
+
+	if i.PlacementGroup != "" {
+		errData, err = placement.Check(db, i.Organization,
+			i.PlacementGroup, i.Id, nde.Id, nde.Zone)
+		if err != nil || errData != nil {
+			return
+		}
+	}
+
+	i.State = Migrate
+	i.Restart = false
+	i.MigrateNode = nde.Id
+	i.MigrateState = MigratePending
+	i.MigrateAddr = ""
+	i.MigrateNbdAddr = ""
+	i.MigrateTls = nil
+	i.MigrateDisks = []*MigrateDisk{}
+
+	return
+}
+
+func (i *Instance) MigrateClear() {
+	i.MigrateNode = ""
+	i.MigrateState = ""
+	i.MigrateAddr = ""
+	i.MigrateNbdAddr = ""
+	i.MigrateTls = nil
+	i.MigrateDisks = nil
+}
+
+func (i *Instance) GetVpcs() (vpcIds []bson.ObjectId) {
+	vpcIds = []bson.ObjectId{}
+
+	if i.Vpc != "" {
+		vpcIds = append(vpcIds, i.Vpc)
+	}
+	vpcIds = append(vpcIds, i.SecondaryVpcs...)
+
+	return
+}
+
+func (i *Instance) GetPrivateIps(vpcId bson.ObjectId) (
+	addrs, addrs6 []string) {
+
+	addrs = []string{}
+	addrs6 = []string{}
+
+	for n, instVpcId := range i.GetVpcs() {
+		if instVpcId != vpcId {
+			continue
+		}
+
+		if n < len(i.PrivateIps) {
+			addrs = append(addrs, i.PrivateIps[n])
+		}
+		if n < len(i.PrivateIps6) {
+			addrs6 = append(addrs6, i.PrivateIps6[n])
+		}
+	}
+
+	return
+}
+
+func (i *Instance) LoadPlacementViolation(db *database.Database) (
+	err error) {
+
+	if i.PlacementGroup == "" {
+		i.PlacementViolation = false
+		return
+	}
+
+	violations, err := placement.GetViolations(
+		db, []bson.ObjectId{i.PlacementGroup})
+	if err != nil {
+		return
+	}
+
+	_, i.PlacementViolation = violations[i.Id]
+
+	return
+}
+
 func (i *Instance) PreCommit() {
-	i.curVpc = i.Vpc
+	i.curVpcs = i.GetVpcs()
+	i.curPlacementGroup = i.PlacementGroup
+	i.curState = i.State
+	i.curProcessors = i.Processors
+	i.curMemory = i.Memory
+}
+
+func (i *Instance) ReservationChanged() bool {
+	if i.State == Stop || i.State == Destroy || i.State == Migrate {
+		return false
+	}
+
+	return i.curState == "" || i.curState == Stop ||
+		i.Processors > i.curProcessors || i.Memory > i.curMemory
 }
 
 func (i *Instance) PostCommit(db *database.Database) (err error) {
-	if i.curVpc != "" && i.curVpc != i.Vpc {
-		err = vpc.RemoveInstanceIp(db, i.Id, i.curVpc)
+	vpcIds := set.NewSet()
+	for _, vpcId := range i.GetVpcs() {
+		vpcIds.Add(vpcId)
+	}
+
+	for _, vpcId := range i.curVpcs {
+		if vpcIds.Contains(vpcId) {
+			continue
+		}
+
+		err = vpc.RemoveInstanceIp(db, i.Id, vpcId)
 		if err != nil {
 			return
 		}
@@ -256,18 +511,23 @@ func (i *Instance) Insert(db *database.Database) (err error) {
 
 func (i *Instance) LoadVirt(disks []*disk.Disk) {
 	i.Virt = &vm.VirtualMachine{
-		Id:         i.Id,
-		Image:      i.Image,
-		Processors: i.Processors,
-		Memory:     i.Memory,
-		Disks:      []*vm.Disk{},
-		NetworkAdapters: []*vm.NetworkAdapter{
+		Id:              i.Id,
+		Image:           i.Image,
+		Processors:      i.Processors,
+		Memory:          i.Memory,
+		Vnc:             i.Vnc,
+		PrivateOnly:     i.PrivateOnly,
+		Disks:           []*vm.Disk{},
+		NetworkAdapters: []*vm.NetworkAdapter{},
+	}
+
+	for _, vpcId := range i.GetVpcs() {
+		i.Virt.NetworkAdapters = append(i.Virt.NetworkAdapters,
 			&vm.NetworkAdapter{
 				Type:       vm.Bridge,
-				MacAddress: vm.GetMacAddr(i.Id, i.Vpc),
-				VpcId:      i.Vpc,
-			},
-		},
+				MacAddress: vm.GetMacAddr(i.Id, vpcId),
+				VpcId:      vpcId,
+			})
 	}
 
 	if disks != nil {
@@ -289,8 +549,15 @@ func (i *Instance) LoadVirt(disks []*disk.Disk) {
 
 func (i *Instance) Changed(curVirt *vm.VirtualMachine) bool {
 	if i.Virt.Memory != curVirt.Memory ||
-		i.Virt.Processors != curVirt.Processors {
+		i.Virt.Processors != curVirt.Processors ||
+		i.Virt.Vnc != curVirt.Vnc ||
+		i.Virt.PrivateOnly != curVirt.PrivateOnly ||
+		!i.Virt.FloatingIp.Equal(curVirt.FloatingIp) {
+
+		return true
+	}
 
+	if len(i.Virt.NetworkAdapters) != len(curVirt.NetworkAdapters) {
 		return true
 	}
 
@@ -0,0 +1,51 @@
+package instance
+
+import (
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"gopkg.in/yaml.v2"
+	"strings"
+)
+
+const (
+	userDataMaxSize   = 65536
+	cloudConfigHeader = "#cloud-config"
+)
+
+func ValidateUserData(data string) (errData *errortypes.ErrorData) {
+	if data == "" {
+		return
+	}
+
+	if len(data) > userDataMaxSize {
+		errData = &errortypes.ErrorData{
+			Error:   "user_data_too_large",
+			Message: "User data exceeds maximum size of 64KB",
+		}
+		return
+	}
+
+	if strings.HasPrefix(data, "#!") {
+		return
+	}
+
+	if !strings.HasPrefix(data, cloudConfigHeader) {
+		errData = &errortypes.ErrorData{
+			Error: "user_data_invalid",
+			Message: "User data must begin with #cloud-config " +
+				"or a #! script interpreter",
+		}
+		return
+	}
+
+	cloudConfig := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(data), &cloudConfig)
+	if err != nil {
+		errData = &errortypes.ErrorData{
+			Error:   "user_data_invalid",
+			Message: "User data cloud-config is not valid YAML",
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,38 @@
+package instance
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateUserData(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"empty", "", ""},
+		{"script", "#!/bin/bash\necho test\n", ""},
+		{"cloud_config", "#cloud-config\npackages:\n  - git\n", ""},
+		{"cloud_config_empty", "#cloud-config\n", ""},
+		{"missing_header", "packages:\n  - git\n", "user_data_invalid"},
+		{"invalid_yaml", "#cloud-config\npackages: [git\n",
+			"user_data_invalid"},
+		{"too_large", "#!/bin/sh\n" + strings.Repeat("#", userDataMaxSize),
+			"user_data_too_large"},
+	}
+
+	for _, test := range tests {
+		errData := ValidateUserData(test.data)
+		if test.err == "" {
+			if errData != nil {
+				t.Errorf("%s: unexpected error %s", test.name, errData.Error)
+			}
+			continue
+		}
+
+		if errData == nil || errData.Error != test.err {
+			t.Errorf("%s: error = %v, want %s", test.name, errData, test.err)
+		}
+	}
+}
@@ -288,6 +288,9 @@ func UpdateMulti(db *database.Database, instIds []bson.ObjectId,
 		"_id": &bson.M{
 			"$in": instIds,
 		},
+		"state": &bson.M{
+			"$ne": Migrate,
+		},
 	}, &bson.M{
 		"$set": doc,
 	})
@@ -309,6 +312,9 @@ func UpdateMultiOrg(db *database.Database, orgId bson.ObjectId,
 			"$in": instIds,
 		},
 		"organization": orgId,
+		"state": &bson.M{
+			"$ne": Migrate,
+		},
 	}, &bson.M{
 		"$set": doc,
 	})
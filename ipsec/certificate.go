@@ -0,0 +1,163 @@
+package ipsec
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"path"
+)
+
+const (
+	certFile = "vpc.pem"
+)
+
+func getKeyType(key string) (keyType string, err error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		err = &errortypes.ParseError{
+			errors.New("ipsec: Failed to decode certificate key"),
+		}
+		return
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		keyType = "RSA"
+		break
+	case "EC PRIVATE KEY":
+		keyType = "ECDSA"
+		break
+	default:
+		privKey, e := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "ipsec: Failed to parse certificate key"),
+			}
+			return
+		}
+
+		if _, ok := privKey.(*ecdsa.PrivateKey); ok {
+			keyType = "ECDSA"
+		} else {
+			keyType = "RSA"
+		}
+	}
+
+	return
+}
+
+func clearCertificates(vpcId bson.ObjectId) (err error) {
+	namespace := vm.GetLinkNamespace(vpcId, 0)
+	baseDir := path.Join("/", "etc", "netns", namespace, "ipsec.d")
+
+	for _, dir := range []string{"certs", "cacerts", "private"} {
+		err = utils.RemoveAll(path.Join(baseDir, dir))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func writeCertificates(vc *vpc.Vpc, cert *certificate.Certificate) (
+	keyType string, err error) {
+
+	namespace := vm.GetLinkNamespace(vc.Id, 0)
+	baseDir := path.Join("/", "etc", "netns", namespace, "ipsec.d")
+	conf := vc.GetLinkConfig()
+
+	err = clearCertificates(vc.Id)
+	if err != nil {
+		return
+	}
+
+	keyType, err = getKeyType(cert.Key)
+	if err != nil {
+		return
+	}
+
+	leaf := []byte{}
+	rest := []byte(cert.Certificate)
+	for {
+		block, remaining := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		rest = remaining
+
+		if block.Type == "CERTIFICATE" {
+			leaf = pem.EncodeToMemory(block)
+			break
+		}
+	}
+
+	if len(leaf) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("ipsec: Failed to decode link certificate"),
+		}
+		return
+	}
+
+	for _, dir := range []string{"certs", "cacerts", "private"} {
+		err = utils.ExistsMkdir(path.Join(baseDir, dir), 0755)
+		if err != nil {
+			return
+		}
+	}
+
+	err = ioutil.WriteFile(
+		path.Join(baseDir, "certs", certFile), leaf, 0644)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "ipsec: Failed to write link certificate"),
+		}
+		return
+	}
+
+	err = ioutil.WriteFile(
+		path.Join(baseDir, "private", certFile), []byte(cert.Key), 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "ipsec: Failed to write link certificate key"),
+		}
+		return
+	}
+
+	err = ioutil.WriteFile(
+		path.Join(baseDir, "cacerts", "remote.pem"),
+		[]byte(conf.RemoteCa), 0644)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "ipsec: Failed to write link remote CA"),
+		}
+		return
+	}
+
+	return
+}
+
+func getCertificate(db *database.Database, vc *vpc.Vpc) (
+	cert *certificate.Certificate, err error) {
+
+	conf := vc.GetLinkConfig()
+	if conf.AuthType != vpc.CertificateAuth || conf.Certificate == "" {
+		return
+	}
+
+	cert, err = certificate.Get(db, conf.Certificate)
+	if err != nil {
+		return
+	}
+
+	return
+}
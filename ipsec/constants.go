@@ -6,26 +6,44 @@ import (
 
 const (
 	confTemplateStr = `conn {{.Id}}
-	ikelifetime=8h
-	keylife=1h
-	rekeymargin=9m
+	ikelifetime={{.IkeLifetime}}s
+	keylife={{.Lifetime}}s
+	rekeymargin={{.RekeyMargin}}s
 	keyingtries=%forever
-	authby=secret
-	keyexchange=ikev2
+	keyexchange={{.IkeVersion}}
+{{- if .IkeProposals}}
+	ike={{.IkeProposals}}
+{{- end}}
+{{- if .EspProposals}}
+	esp={{.EspProposals}}
+{{- end}}
 	mobike=no
-	dpddelay=5s
-	dpdtimeout=20s
-	dpdaction=restart
+	dpddelay={{.DpdDelay}}s
+	dpdtimeout={{.DpdTimeout}}s
+	dpdaction={{.DpdAction}}
 	left=%defaultroute
+{{- if .Certificate}}
+	leftauth=pubkey
+	leftcert={{.Certificate}}
+{{- else}}
+	authby=secret
 	leftid={{.Left}}
+{{- end}}
 	leftsubnet={{.LeftSubnets}}
 	leftfirewall=yes
 	right={{.Right}}
+{{- if .Certificate}}
+	rightauth=pubkey
+	rightid={{.RightId}}
+{{- else}}
 	rightid={{.Right}}
+{{- end}}
 	rightsubnet={{.RightSubnets}}
 	auto=start
 `
 	secretsTemplateStr = `{{.Left}} {{.Right}} : PSK "{{.PreSharedKey}}"
+`
+	certSecretsTemplateStr = `: {{.KeyType}} {{.Certificate}}
 `
 )
 
@@ -34,4 +52,6 @@ var (
 		template.New("conf").Parse(confTemplateStr))
 	secretsTemplate = template.Must(
 		template.New("secrets").Parse(secretsTemplateStr))
+	certSecretsTemplate = template.Must(
+		template.New("cert_secrets").Parse(certSecretsTemplateStr))
 )
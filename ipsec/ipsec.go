@@ -58,7 +58,12 @@ func deploy(vpcId bson.ObjectId, states []*link.State) (err error) {
 		return
 	}
 
-	err = writeTemplates(vpcId, states)
+	cert, err := getCertificate(db, vc)
+	if err != nil {
+		return
+	}
+
+	err = writeTemplates(vc, cert, states)
 	if err != nil {
 		return
 	}
@@ -9,8 +9,10 @@ import (
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/link"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/resolver"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
@@ -28,6 +30,50 @@ var (
 	syncLock = utils.NewMultiTimeoutLock(1 * time.Minute)
 )
 
+func recordTransitions(db *database.Database, vc *vpc.Vpc,
+	transitions []*link.Transition) (err error) {
+
+	link.LinkErrorsLock.Lock()
+	linkErrors := link.LinkErrors[vc.Id]
+	link.LinkErrorsLock.Unlock()
+
+	for _, transition := range transitions {
+		hist := &vpc.LinkHistory{
+			Vpc:            vc.Id,
+			Organization:   vc.Organization,
+			Node:           node.Self.Id,
+			Link:           transition.Link,
+			Status:         transition.Status,
+			PreviousStatus: transition.PreviousStatus,
+		}
+
+		if transition.Status != "connected" {
+			hist.Errors = linkErrors
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"vpc_id":          vc.Id.Hex(),
+			"link":            hist.Link,
+			"status":          hist.Status,
+			"previous_status": hist.PreviousStatus,
+		}).Info("ipsec: Link state changed")
+
+		err = hist.Insert(db)
+		if err != nil {
+			return
+		}
+
+		err = event.Publish(db, "vpc.link", hist)
+		if err != nil {
+			return
+		}
+	}
+
+	event.PublishDispatch(db, "vpc.change")
+
+	return
+}
+
 func syncStates(vc *vpc.Vpc) {
 	if syncLock.Locked(vc.Id.Hex()) {
 		return
@@ -61,6 +107,21 @@ func syncStates(vc *vpc.Vpc) {
 		return
 	}
 
+	if vc.DnsResolver {
+		err = resolver.Deploy(db, vc)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"vpc_id": vc.Id.Hex(),
+				"error":  err,
+			}).Error("ipsec: Failed to deploy VPC DNS resolver")
+			err = nil
+		}
+	}
+
+	if len(vc.LinkUris) == 0 {
+		return
+	}
+
 	pubAddr := ""
 	pubAddr6 := ""
 	for i := 0; i < 3; i++ {
@@ -98,6 +159,20 @@ func syncStates(vc *vpc.Vpc) {
 		io.WriteString(hsh, stat.Hash)
 	}
 
+	io.WriteString(hsh, vc.GetLinkConfig().Hash())
+
+	cert, err := getCertificate(db, vc)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"vpc_id": vc.Id.Hex(),
+			"error":  err,
+		}).Error("ipsec: Failed to get link certificate")
+		return
+	}
+	if cert != nil {
+		io.WriteString(hsh, cert.Certificate)
+	}
+
 	newHash := hex.EncodeToString(hsh.Sum(nil))
 
 	link.HashesLock.Lock()
@@ -111,7 +186,7 @@ func syncStates(vc *vpc.Vpc) {
 		link.HashesLock.Unlock()
 	}
 
-	resetLinks, err := link.Update(vc.Id, names)
+	resetLinks, transitions, err := link.Update(vc.Id, names)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"vpc_id":          vc.Id.Hex(),
@@ -121,6 +196,16 @@ func syncStates(vc *vpc.Vpc) {
 		}).Info("ipsec: Failed to get status")
 	}
 
+	if len(transitions) != 0 {
+		err = recordTransitions(db, vc, transitions)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"vpc_id": vc.Id.Hex(),
+				"error":  err,
+			}).Error("ipsec: Failed to record link history")
+		}
+	}
+
 	if resetLinks != nil && len(resetLinks) != 0 {
 		logrus.WithFields(logrus.Fields{
 			"vpc_id": vc.Id.Hex(),
@@ -141,10 +226,13 @@ func SyncStates(vpcs []*vpc.Vpc) {
 	curNamespaces := set.NewSet()
 	curVirtIfaces := set.NewSet()
 	curExternalIfaces := set.NewSet()
+	curResolvers := set.NewSet()
 	sync := []*vpc.Vpc{}
 
 	for _, vc := range vpcs {
-		if vc.LinkUris == nil || len(vc.LinkUris) == 0 {
+		if (vc.LinkUris == nil || len(vc.LinkUris) == 0) &&
+			!vc.DnsResolver {
+
 			continue
 		}
 
@@ -172,6 +260,9 @@ func SyncStates(vpcs []*vpc.Vpc) {
 		curVirtIfaces.Add(vm.GetLinkIfaceVirt(vc.Id, 0))
 		curVirtIfaces.Add(vm.GetLinkIfaceVirt(vc.Id, 1))
 		curExternalIfaces.Add(vm.GetLinkIfaceExternal(vc.Id, 0))
+		if vc.DnsResolver {
+			curResolvers.Add(vm.GetLinkNamespace(vc.Id, 0))
+		}
 
 		sync = append(sync, vc)
 	}
@@ -182,6 +273,11 @@ func SyncStates(vpcs []*vpc.Vpc) {
 		go syncStates(vc)
 	}
 
+	err := resolver.Clean(curResolvers)
+	if err != nil {
+		return
+	}
+
 	iterfaces, err := utils.GetInterfaces()
 	if err != nil {
 		return
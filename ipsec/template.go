@@ -4,10 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/certificate"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/link"
 	"github.com/pritunl/pritunl-cloud/vm"
-	"gopkg.in/mgo.v2/bson"
+	"github.com/pritunl/pritunl-cloud/vpc"
 	"io/ioutil"
 	"path"
 	"strings"
@@ -19,16 +20,46 @@ type templateData struct {
 	LeftSubnets  string
 	Right        string
 	RightSubnets string
+	RightId      string
 	PreSharedKey string
+	IkeVersion   string
+	IkeProposals string
+	EspProposals string
+	IkeLifetime  int
+	Lifetime     int
+	RekeyMargin  int
+	DpdDelay     int
+	DpdTimeout   int
+	DpdAction    string
+	Certificate  string
+	KeyType      string
 }
 
-func writeTemplates(vpcId bson.ObjectId, states []*link.State) (err error) {
-	namespace := vm.GetLinkNamespace(vpcId, 0)
+func writeTemplates(vc *vpc.Vpc, cert *certificate.Certificate,
+	states []*link.State) (err error) {
+
+	namespace := vm.GetLinkNamespace(vc.Id, 0)
 	baseDir := path.Join("/", "etc", "netns", namespace)
+	conf := vc.GetLinkConfig()
 
 	confBuf := &bytes.Buffer{}
 	secretsBuf := &bytes.Buffer{}
 
+	certName := ""
+	keyType := ""
+	if cert != nil {
+		keyType, err = writeCertificates(vc, cert)
+		if err != nil {
+			return
+		}
+		certName = certFile
+	} else {
+		err = clearCertificates(vc.Id)
+		if err != nil {
+			return
+		}
+	}
+
 	for _, stat := range states {
 		for i, lnk := range stat.Links {
 			leftSubnets := strings.Join(lnk.LeftSubnets, ",")
@@ -47,7 +78,19 @@ func writeTemplates(vpcId bson.ObjectId, states []*link.State) (err error) {
 				LeftSubnets:  leftSubnets,
 				Right:        lnk.Right,
 				RightSubnets: rightSubnets,
+				RightId:      conf.RemoteId,
 				PreSharedKey: lnk.PreSharedKey,
+				IkeVersion:   conf.IkeVersion,
+				IkeProposals: strings.Join(conf.IkeProposals, ","),
+				EspProposals: strings.Join(conf.EspProposals, ","),
+				IkeLifetime:  conf.IkeLifetime,
+				Lifetime:     conf.Lifetime,
+				RekeyMargin:  conf.RekeyMargin(),
+				DpdDelay:     conf.DpdDelay,
+				DpdTimeout:   conf.DpdTimeout,
+				DpdAction:    conf.DpdAction,
+				Certificate:  certName,
+				KeyType:      keyType,
 			}
 
 			err = confTemplate.Execute(confBuf, data)
@@ -59,6 +102,10 @@ func writeTemplates(vpcId bson.ObjectId, states []*link.State) (err error) {
 				return
 			}
 
+			if cert != nil {
+				continue
+			}
+
 			err = secretsTemplate.Execute(secretsBuf, data)
 			if err != nil {
 				err = &errortypes.ParseError{
@@ -70,6 +117,20 @@ func writeTemplates(vpcId bson.ObjectId, states []*link.State) (err error) {
 		}
 	}
 
+	if cert != nil {
+		err = certSecretsTemplate.Execute(secretsBuf, &templateData{
+			Certificate: certName,
+			KeyType:     keyType,
+		})
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err,
+					"ipsec: Failed to execute secrets template"),
+			}
+			return
+		}
+	}
+
 	pth := path.Join(baseDir, "ipsec.conf")
 	err = ioutil.WriteFile(pth, confBuf.Bytes(), 0644)
 	if err != nil {
@@ -20,6 +20,8 @@ type Rules struct {
 	Interface string
 	Ingress   [][]string
 	Ingress6  [][]string
+	Egress    [][]string
+	Egress6   [][]string
 	Holds     [][]string
 	Holds6    [][]string
 }
@@ -59,6 +61,48 @@ func (r *Rules) commentCommand(inCmd []string, hold bool) (cmd []string) {
 	return
 }
 
+func (r *Rules) ruleCommand(inCmd []string, keys ...string) (cmd []string) {
+	comment := "pritunl_cloud_rule"
+	for i, key := range keys {
+		if i == 0 {
+			key = "_" + key
+		} else {
+			key = "," + key
+		}
+
+		if len(comment)+len(key) > 255 {
+			break
+		}
+		comment += key
+	}
+
+	cmd = append(inCmd,
+		"-m", "comment",
+		"--comment", comment,
+	)
+
+	return
+}
+
+func (r *Rules) logCommand(inCmd []string, name string) (cmd []string) {
+	prefix := "pc_" + name + "_"
+	if len(prefix) > 29 {
+		prefix = prefix[:29]
+	}
+
+	cmd = append([]string{}, inCmd...)
+	cmd = append(cmd,
+		"-m", "comment",
+		"--comment", "pritunl_cloud_rule",
+		"-m", "limit",
+		"--limit", "10/min",
+		"-j", "LOG",
+		"--log-prefix", prefix,
+	)
+
+	return
+}
+
 func (r *Rules) run(cmds [][]string, ipCmd string, ipv6 bool) (err error) {
 	iptablesCmd := getIptablesCmd(ipv6)
 
@@ -131,6 +175,16 @@ func (r *Rules) Apply() (err error) {
 		return
 	}
 
+	err = r.run(r.Egress, "-A", false)
+	if err != nil {
+		return
+	}
+
+	err = r.run(r.Egress6, "-A", true)
+	if err != nil {
+		return
+	}
+
 	err = r.run(r.Holds, "-D", false)
 	if err != nil {
 		return
@@ -231,6 +285,18 @@ func (r *Rules) Remove() (err error) {
 	}
 	r.Ingress6 = [][]string{}
 
+	err = r.run(r.Egress, "-D", false)
+	if err != nil {
+		return
+	}
+	r.Egress = [][]string{}
+
+	err = r.run(r.Egress6, "-D", true)
+	if err != nil {
+		return
+	}
+	r.Egress6 = [][]string{}
+
 	err = r.run(r.Holds, "-D", false)
 	if err != nil {
 		return
@@ -246,14 +312,16 @@ func (r *Rules) Remove() (err error) {
 	return
 }
 
-func generateVirt(namespace, iface string, ingress []*firewall.Rule) (
-	rules *Rules) {
+func generateVirt(namespace, iface string,
+	ingress, egress []*firewall.Rule, logDrop bool) (rules *Rules) {
 
 	rules = &Rules{
 		Namespace: namespace,
 		Interface: iface,
 		Ingress:   [][]string{},
 		Ingress6:  [][]string{},
+		Egress:    [][]string{},
+		Egress6:   [][]string{},
 		Holds:     [][]string{},
 		Holds6:    [][]string{},
 	}
@@ -343,6 +411,7 @@ func generateVirt(namespace, iface string, ingress []*firewall.Rule) (
 	rules.Ingress6 = append(rules.Ingress6, cmd)
 
 	for _, rule := range ingress {
+		name := rule.Name(firewall.Ingress)
 		for _, sourceIp := range rule.SourceIps {
 			ipv6 := strings.Contains(sourceIp, ":")
 			cmd = rules.newCommand()
@@ -392,7 +461,16 @@ func generateVirt(namespace, iface string, ingress []*firewall.Rule) (
 				break
 			}
 
-			cmd = rules.commentCommand(cmd, false)
+			if rule.Log {
+				logCmd := rules.logCommand(cmd, name)
+				if ipv6 {
+					rules.Ingress6 = append(rules.Ingress6, logCmd)
+				} else {
+					rules.Ingress = append(rules.Ingress, logCmd)
+				}
+			}
+
+			cmd = rules.ruleCommand(cmd, rule.Keys...)
 			cmd = append(cmd,
 				"-j", "ACCEPT",
 			)
@@ -493,7 +571,11 @@ func generateVirt(namespace, iface string, ingress []*firewall.Rule) (
 			"--physdev-out", rules.Interface,
 		)
 	}
-	cmd = rules.commentCommand(cmd, false)
+	if logDrop {
+		rules.Ingress = append(rules.Ingress,
+			rules.logCommand(cmd, firewall.Ingress+"_"+firewall.Drop))
+	}
+	cmd = rules.ruleCommand(cmd, firewall.Ingress+"_"+firewall.Drop)
 	cmd = append(cmd,
 		"-j", "DROP",
 	)
@@ -506,23 +588,190 @@ func generateVirt(namespace, iface string, ingress []*firewall.Rule) (
 			"--physdev-out", rules.Interface,
 		)
 	}
-	cmd = rules.commentCommand(cmd, false)
+	if logDrop {
+		rules.Ingress6 = append(rules.Ingress6,
+			rules.logCommand(cmd, firewall.Ingress+"_"+firewall.Drop))
+	}
+	cmd = rules.ruleCommand(cmd, firewall.Ingress+"_"+firewall.Drop)
 	cmd = append(cmd,
 		"-j", "DROP",
 	)
 	rules.Ingress6 = append(rules.Ingress6, cmd)
 
+	if len(egress) == 0 {
+		return
+	}
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-p", "ipv6-icmp",
+	)
+	if rules.Interface != "host" {
+		cmd = append(cmd,
+			"-m", "physdev",
+			"--physdev-in", rules.Interface,
+		)
+	}
+	cmd = rules.commentCommand(cmd, false)
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Egress6 = append(rules.Egress6, cmd)
+
+	for _, rule := range egress {
+		name := rule.Name(firewall.Egress)
+		for _, destIp := range rule.DestinationIps {
+			ipv6 := strings.Contains(destIp, ":")
+			cmd = rules.newCommand()
+
+			if destIp != "0.0.0.0/0" && destIp != "::/0" {
+				cmd = append(cmd,
+					"-d", destIp,
+				)
+			}
+
+			switch rule.Protocol {
+			case firewall.All:
+				break
+			case firewall.Icmp:
+				if ipv6 {
+					cmd = append(cmd,
+						"-p", "ipv6-icmp",
+					)
+				} else {
+					cmd = append(cmd,
+						"-p", "icmp",
+					)
+				}
+				break
+			case firewall.Tcp, firewall.Udp:
+				cmd = append(cmd,
+					"-p", rule.Protocol,
+				)
+				break
+			default:
+				continue
+			}
+
+			if rules.Interface != "host" {
+				cmd = append(cmd,
+					"-m", "physdev",
+					"--physdev-in", rules.Interface,
+				)
+			}
+
+			switch rule.Protocol {
+			case firewall.Tcp, firewall.Udp:
+				cmd = append(cmd,
+					"-m", rule.Protocol,
+					"--dport", strings.Replace(rule.Port, "-", ":", 1),
+				)
+				break
+			}
+
+			if rule.Log {
+				logCmd := rules.logCommand(cmd, name)
+				if ipv6 {
+					rules.Egress6 = append(rules.Egress6, logCmd)
+				} else {
+					rules.Egress = append(rules.Egress, logCmd)
+				}
+			}
+
+			cmd = rules.ruleCommand(cmd, rule.Keys...)
+			cmd = append(cmd,
+				"-j", "ACCEPT",
+			)
+
+			if ipv6 {
+				rules.Egress6 = append(rules.Egress6, cmd)
+			} else {
+				rules.Egress = append(rules.Egress, cmd)
+			}
+		}
+	}
+
+	cmd = rules.newCommand()
+	if rules.Interface != "host" {
+		cmd = append(cmd,
+			"-m", "physdev",
+			"--physdev-in", rules.Interface,
+		)
+	}
+	cmd = append(cmd,
+		"-m", "conntrack",
+		"--ctstate", "RELATED,ESTABLISHED",
+	)
+	cmd = rules.commentCommand(cmd, false)
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Egress = append(rules.Egress, cmd)
+
+	cmd = rules.newCommand()
+	if rules.Interface != "host" {
+		cmd = append(cmd,
+			"-m", "physdev",
+			"--physdev-in", rules.Interface,
+		)
+	}
+	cmd = append(cmd,
+		"-m", "conntrack",
+		"--ctstate", "RELATED,ESTABLISHED",
+	)
+	cmd = rules.commentCommand(cmd, false)
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Egress6 = append(rules.Egress6, cmd)
+
+	cmd = rules.newCommand()
+	if rules.Interface != "host" {
+		cmd = append(cmd,
+			"-m", "physdev",
+			"--physdev-in", rules.Interface,
+		)
+	}
+	if logDrop {
+		rules.Egress = append(rules.Egress,
+			rules.logCommand(cmd, firewall.Egress+"_"+firewall.Drop))
+	}
+	cmd = rules.ruleCommand(cmd, firewall.Egress+"_"+firewall.Drop)
+	cmd = append(cmd,
+		"-j", "DROP",
+	)
+	rules.Egress = append(rules.Egress, cmd)
+
+	cmd = rules.newCommand()
+	if rules.Interface != "host" {
+		cmd = append(cmd,
+			"-m", "physdev",
+			"--physdev-in", rules.Interface,
+		)
+	}
+	if logDrop {
+		rules.Egress6 = append(rules.Egress6,
+			rules.logCommand(cmd, firewall.Egress+"_"+firewall.Drop))
+	}
+	cmd = rules.ruleCommand(cmd, firewall.Egress+"_"+firewall.Drop)
+	cmd = append(cmd,
+		"-j", "DROP",
+	)
+	rules.Egress6 = append(rules.Egress6, cmd)
+
 	return
 }
 
-func generateInternal(namespace, iface string, ingress []*firewall.Rule) (
-	rules *Rules) {
+func generateInternal(namespace, iface string, ingress []*firewall.Rule,
+	logDrop bool) (rules *Rules) {
 
 	rules = &Rules{
 		Namespace: namespace,
 		Interface: iface,
 		Ingress:   [][]string{},
 		Ingress6:  [][]string{},
+		Egress:    [][]string{},
+		Egress6:   [][]string{},
 		Holds:     [][]string{},
 		Holds6:    [][]string{},
 	}
@@ -750,7 +999,11 @@ func generateInternal(namespace, iface string, ingress []*firewall.Rule) (
 			"-i", rules.Interface,
 		)
 	}
-	cmd = rules.commentCommand(cmd, false)
+	if logDrop {
+		rules.Ingress = append(rules.Ingress,
+			rules.logCommand(cmd, firewall.Ingress+"_"+firewall.Drop))
+	}
+	cmd = rules.ruleCommand(cmd, firewall.Ingress+"_"+firewall.Drop)
 	cmd = append(cmd,
 		"-j", "DROP",
 	)
@@ -762,7 +1015,11 @@ func generateInternal(namespace, iface string, ingress []*firewall.Rule) (
 			"-i", rules.Interface,
 		)
 	}
-	cmd = rules.commentCommand(cmd, false)
+	if logDrop {
+		rules.Ingress6 = append(rules.Ingress6,
+			rules.logCommand(cmd, firewall.Ingress+"_"+firewall.Drop))
+	}
+	cmd = rules.ruleCommand(cmd, firewall.Ingress+"_"+firewall.Drop)
 	cmd = append(cmd,
 		"-j", "DROP",
 	)
@@ -771,14 +1028,16 @@ func generateInternal(namespace, iface string, ingress []*firewall.Rule) (
 	return
 }
 
-func generate(namespace, iface string, ingress []*firewall.Rule) (
-	rules *Rules) {
+func generate(namespace, iface string, ingress []*firewall.Rule,
+	logDrop bool) (rules *Rules) {
 
 	rules = &Rules{
 		Namespace: namespace,
 		Interface: iface,
 		Ingress:   [][]string{},
 		Ingress6:  [][]string{},
+		Egress:    [][]string{},
+		Egress6:   [][]string{},
 		Holds:     [][]string{},
 		Holds6:    [][]string{},
 	}
@@ -863,6 +1122,7 @@ func generate(namespace, iface string, ingress []*firewall.Rule) (
 	rules.Ingress6 = append(rules.Ingress6, cmd)
 
 	for _, rule := range ingress {
+		name := rule.Name(firewall.Ingress)
 		for _, sourceIp := range rule.SourceIps {
 			ipv6 := strings.Contains(sourceIp, ":")
 			cmd = rules.newCommand()
@@ -911,7 +1171,16 @@ func generate(namespace, iface string, ingress []*firewall.Rule) (
 				break
 			}
 
-			cmd = rules.commentCommand(cmd, false)
+			if rule.Log {
+				logCmd := rules.logCommand(cmd, name)
+				if ipv6 {
+					rules.Ingress6 = append(rules.Ingress6, logCmd)
+				} else {
+					rules.Ingress = append(rules.Ingress, logCmd)
+				}
+			}
+
+			cmd = rules.ruleCommand(cmd, rule.Keys...)
 			cmd = append(cmd,
 				"-j", "ACCEPT",
 			)
@@ -1006,7 +1275,11 @@ func generate(namespace, iface string, ingress []*firewall.Rule) (
 			"-o", rules.Interface,
 		)
 	}
-	cmd = rules.commentCommand(cmd, false)
+	if logDrop {
+		rules.Ingress = append(rules.Ingress,
+			rules.logCommand(cmd, firewall.Ingress+"_"+firewall.Drop))
+	}
+	cmd = rules.ruleCommand(cmd, firewall.Ingress+"_"+firewall.Drop)
 	cmd = append(cmd,
 		"-j", "DROP",
 	)
@@ -1018,7 +1291,11 @@ func generate(namespace, iface string, ingress []*firewall.Rule) (
 			"-o", rules.Interface,
 		)
 	}
-	cmd = rules.commentCommand(cmd, false)
+	if logDrop {
+		rules.Ingress6 = append(rules.Ingress6,
+			rules.logCommand(cmd, firewall.Ingress+"_"+firewall.Drop))
+	}
+	cmd = rules.ruleCommand(cmd, firewall.Ingress+"_"+firewall.Drop)
 	cmd = append(cmd,
 		"-j", "DROP",
 	)
@@ -14,6 +14,8 @@ import (
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"gopkg.in/mgo.v2/bson"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -35,6 +37,8 @@ func diffCmd(a, b []string) bool {
 func diffRules(a, b *Rules) bool {
 	if len(a.Ingress) != len(b.Ingress) ||
 		len(a.Ingress6) != len(b.Ingress6) ||
+		len(a.Egress) != len(b.Egress) ||
+		len(a.Egress6) != len(b.Egress6) ||
 		len(a.Holds) != len(b.Holds) ||
 		len(a.Holds6) != len(b.Holds6) {
 
@@ -51,6 +55,16 @@ func diffRules(a, b *Rules) bool {
 			return true
 		}
 	}
+	for i := range a.Egress {
+		if diffCmd(a.Egress[i], b.Egress[i]) {
+			return true
+		}
+	}
+	for i := range a.Egress6 {
+		if diffCmd(a.Egress6[i], b.Egress6[i]) {
+			return true
+		}
+	}
 	for i := range a.Holds {
 		if diffCmd(a.Holds[i], b.Holds[i]) {
 			return true
@@ -65,6 +79,88 @@ func diffRules(a, b *Rules) bool {
 	return false
 }
 
+func expandRoles(roleIps map[string][]string,
+	ingress []*firewall.Rule) (rules []*firewall.Rule) {
+
+	rules = []*firewall.Rule{}
+
+	for _, rule := range ingress {
+		if len(rule.SourceRoles) == 0 {
+			rules = append(rules, rule)
+			continue
+		}
+
+		sourceIps := set.NewSet()
+		for _, sourceIp := range rule.SourceIps {
+			sourceIps.Add(sourceIp)
+		}
+		for _, role := range rule.SourceRoles {
+			for _, sourceIp := range roleIps[role] {
+				sourceIps.Add(sourceIp)
+			}
+		}
+
+		sourceIpsList := []string{}
+		for sourceIp := range sourceIps.Iter() {
+			sourceIpsList = append(sourceIpsList, sourceIp.(string))
+		}
+		sort.Strings(sourceIpsList)
+
+		rules = append(rules, &firewall.Rule{
+			SourceIps: sourceIpsList,
+			Protocol:  rule.Protocol,
+			Port:      rule.Port,
+			Log:       rule.Log,
+			Keys:      rule.Keys,
+		})
+	}
+
+	return
+}
+
+func splitRule(line string) (cmd []string) {
+	cmd = []string{}
+	item := []rune{}
+	started := false
+	quoted := false
+	escaped := false
+
+	for _, c := range line {
+		if escaped {
+			item = append(item, c)
+			escaped = false
+			continue
+		}
+
+		switch {
+		case c == '\\' && quoted:
+			escaped = true
+			break
+		case c == '"':
+			quoted = !quoted
+			started = true
+			break
+		case (c == ' ' || c == '\t') && !quoted:
+			if started {
+				cmd = append(cmd, string(item))
+				item = []rune{}
+				started = false
+			}
+			break
+		default:
+			item = append(item, c)
+			started = true
+			break
+		}
+	}
+
+	if started {
+		cmd = append(cmd, string(item))
+	}
+
+	return
+}
+
 func getIptablesCmd(ipv6 bool) string {
 	if ipv6 {
 		return "ip6tables"
@@ -93,6 +189,17 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 		}
 	}
 
+	err = parseIptables(namespace, output, state, ipv6)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func parseIptables(namespace, output string, state *State,
+	ipv6 bool) (err error) {
+
 	for _, line := range strings.Split(output, "\n") {
 		if !strings.Contains(line, "pritunl_cloud_rule") &&
 			!strings.Contains(line, "pritunl_cloud_hold") {
@@ -100,7 +207,7 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 			continue
 		}
 
-		cmd := strings.Fields(line)
+		cmd := splitRule(line)
 		if len(cmd) < 3 {
 			logrus.WithFields(logrus.Fields{
 				"iptables_rule": line,
@@ -114,6 +221,7 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 		cmd = cmd[1:]
 
 		iface := ""
+		egress := false
 		if namespace != "0" {
 			if cmd[0] != "FORWARD" {
 				logrus.WithFields(logrus.Fields{
@@ -127,7 +235,11 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 			}
 
 			for i, item := range cmd {
-				if item == "--physdev-out" || item == "-o" || item == "-i" {
+				if item == "--physdev-out" || item == "--physdev-in" ||
+					item == "-o" || item == "-i" {
+
+					egress = item == "--physdev-in" || item == "-o"
+
 					if len(cmd) < i+2 {
 						logrus.WithFields(logrus.Fields{
 							"iptables_rule": line,
@@ -175,6 +287,8 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 				Interface: iface,
 				Ingress:   [][]string{},
 				Ingress6:  [][]string{},
+				Egress:    [][]string{},
+				Egress6:   [][]string{},
 				Holds:     [][]string{},
 				Holds6:    [][]string{},
 			}
@@ -187,6 +301,12 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 			} else {
 				rules.Holds = append(rules.Holds, cmd)
 			}
+		} else if egress {
+			if ipv6 {
+				rules.Egress6 = append(rules.Egress6, cmd)
+			} else {
+				rules.Egress = append(rules.Egress, cmd)
+			}
 		} else {
 			if ipv6 {
 				rules.Ingress6 = append(rules.Ingress6, cmd)
@@ -199,6 +319,80 @@ func loadIptables(namespace string, state *State, ipv6 bool) (err error) {
 	return
 }
 
+func loadCounters(namespace string, counters map[string]int64,
+	ipv6 bool) (err error) {
+
+	Lock()
+	defer Unlock()
+
+	iptablesCmd := getIptablesCmd(ipv6)
+
+	output := ""
+	if namespace == "0" {
+		output, err = utils.ExecOutput("", iptablesCmd, "-v", "-S")
+		if err != nil {
+			return
+		}
+	} else {
+		output, err = utils.ExecOutput("",
+			"ip", "netns", "exec", namespace, iptablesCmd, "-v", "-S")
+		if err != nil {
+			return
+		}
+	}
+
+	parseCounters(output, counters)
+
+	return
+}
+
+func parseCounters(output string, counters map[string]int64) {
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "pritunl_cloud_rule_") ||
+			strings.Contains(line, "-j LOG") {
+
+			continue
+		}
+
+		cmd := splitRule(line)
+		keys := []string{}
+		packets := int64(0)
+
+		for i, item := range cmd {
+			if i+1 >= len(cmd) {
+				break
+			}
+
+			if item == "--comment" {
+				keys = strings.Split(strings.TrimPrefix(
+					cmd[i+1], "pritunl_cloud_rule_"), ",")
+			} else if item == "-c" {
+				packets, _ = strconv.ParseInt(cmd[i+1], 10, 64)
+			}
+		}
+
+		for _, key := range keys {
+			counters[key] += packets
+		}
+	}
+}
+
+func GetCounters(namespace string, counters map[string]int64) (
+	err error) {
+
+	err = loadCounters(namespace, counters, false)
+	if err != nil {
+		return
+	}
+
+	err = loadCounters(namespace, counters, true)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func applyState(oldState, newState *State, namespaces []string) (err error) {
 	oldIfaces := set.NewSet()
 	newIfaces := set.NewSet()
@@ -264,6 +458,7 @@ func applyState(oldState, newState *State, namespaces []string) (err error) {
 }
 
 func UpdateState(db *database.Database, instances []*instance.Instance,
+	vpcRoleIps map[bson.ObjectId]map[string][]string,
 	namespaces []string) (err error) {
 
 	lockId := stateLock.Lock()
@@ -281,7 +476,8 @@ func UpdateState(db *database.Database, instances []*instance.Instance,
 		}
 
 		ingress := firewall.MergeIngress(fires)
-		newState.Interfaces["0-host"] = generate("0", "host", ingress)
+		newState.Interfaces["0-host"] = generate("0", "host", ingress,
+			firewall.MergeLogDrop(fires))
 	}
 
 	for _, inst := range instances {
@@ -289,7 +485,7 @@ func UpdateState(db *database.Database, instances []*instance.Instance,
 			continue
 		}
 
-		for i := range inst.Virt.NetworkAdapters {
+		for i, adapter := range inst.Virt.NetworkAdapters {
 			namespace := vm.GetNamespace(inst.Id, i)
 			iface := vm.GetIface(inst.Id, i)
 			ifaceExternal := vm.GetIfaceExternal(inst.Id, i)
@@ -314,12 +510,16 @@ func UpdateState(db *database.Database, instances []*instance.Instance,
 				return
 			}
 
-			ingress := firewall.MergeIngress(fires)
+			ingress := expandRoles(vpcRoleIps[adapter.VpcId],
+				firewall.MergeIngress(fires))
+			egress := firewall.MergeEgress(fires)
+			logDrop := firewall.MergeLogDrop(fires)
 
-			rules := generateInternal(namespace, ifaceExternal, ingress)
+			rules := generateInternal(namespace, ifaceExternal,
+				ingress, logDrop)
 			newState.Interfaces[namespace+"-"+ifaceExternal] = rules
 
-			rules = generateVirt(namespace, iface, ingress)
+			rules = generateVirt(namespace, iface, ingress, egress, logDrop)
 			newState.Interfaces[namespace+"-"+iface] = rules
 		}
 	}
@@ -0,0 +1,140 @@
+package iptables
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitRule(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		cmd  []string
+	}{
+		{
+			name: "plain",
+			line: "-A INPUT -m comment --comment pritunl_cloud_rule -j ACCEPT",
+			cmd: []string{"-A", "INPUT", "-m", "comment",
+				"--comment", "pritunl_cloud_rule", "-j", "ACCEPT"},
+		},
+		{
+			name: "quoted",
+			line: `-A INPUT -m comment --comment "pritunl_cloud_rule_a,b" ` +
+				`-j ACCEPT`,
+			cmd: []string{"-A", "INPUT", "-m", "comment",
+				"--comment", "pritunl_cloud_rule_a,b", "-j", "ACCEPT"},
+		},
+		{
+			name: "escaped",
+			line: `-A INPUT -j LOG --log-prefix "pc \"test\" "`,
+			cmd: []string{"-A", "INPUT", "-j", "LOG",
+				"--log-prefix", `pc "test" `},
+		},
+	}
+
+	for _, test := range tests {
+		cmd := splitRule(test.line)
+		if !reflect.DeepEqual(cmd, test.cmd) {
+			t.Errorf("%s: cmd = %q, want %q", test.name, cmd, test.cmd)
+		}
+	}
+}
+
+func TestParseIptablesQuoted(t *testing.T) {
+	output := `-P INPUT ACCEPT
+-P FORWARD ACCEPT
+-P OUTPUT ACCEPT
+-A FORWARD -s 10.0.0.0/8 -p tcp -m physdev --physdev-out veth0 -m tcp --dport 22 -m comment --comment "pritunl_cloud_rule_aaaaaaaaaaaa,bbbbbbbbbbbb" -j ACCEPT
+-A FORWARD -d 10.0.0.0/8 -p udp -m physdev --physdev-in veth0 -m udp --dport 53 -m comment --comment pritunl_cloud_rule_cccccccccccc -j ACCEPT
+-A FORWARD -m physdev --physdev-out veth0 -m comment --comment pritunl_cloud_hold -j DROP
+`
+
+	state := &State{
+		Interfaces: map[string]*Rules{},
+	}
+
+	err := parseIptables("ns1", output, state, false)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	rules := &Rules{
+		Namespace: "ns1",
+		Interface: "veth0",
+		Ingress:   [][]string{},
+		Ingress6:  [][]string{},
+		Egress:    [][]string{},
+		Egress6:   [][]string{},
+		Holds:     [][]string{},
+		Holds6:    [][]string{},
+	}
+
+	cmd := rules.newCommand()
+	cmd = append(cmd,
+		"-s", "10.0.0.0/8",
+		"-p", "tcp",
+		"-m", "physdev",
+		"--physdev-out", rules.Interface,
+		"-m", "tcp",
+		"--dport", "22",
+	)
+	cmd = rules.ruleCommand(cmd, "aaaaaaaaaaaa", "bbbbbbbbbbbb")
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Ingress = append(rules.Ingress, cmd)
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-d", "10.0.0.0/8",
+		"-p", "udp",
+		"-m", "physdev",
+		"--physdev-in", rules.Interface,
+		"-m", "udp",
+		"--dport", "53",
+	)
+	cmd = rules.ruleCommand(cmd, "cccccccccccc")
+	cmd = append(cmd,
+		"-j", "ACCEPT",
+	)
+	rules.Egress = append(rules.Egress, cmd)
+
+	cmd = rules.newCommand()
+	cmd = append(cmd,
+		"-m", "physdev",
+		"--physdev-out", rules.Interface,
+	)
+	cmd = rules.commentCommand(cmd, true)
+	cmd = append(cmd,
+		"-j", "DROP",
+	)
+	rules.Holds = append(rules.Holds, cmd)
+
+	parsed := state.Interfaces["ns1-veth0"]
+	if parsed == nil {
+		t.Fatalf("missing parsed interface rules")
+	}
+
+	if diffRules(parsed, rules) {
+		t.Errorf("rules = %q, want %q", parsed.Ingress, rules.Ingress)
+	}
+}
+
+func TestParseCounters(t *testing.T) {
+	output := `-P FORWARD ACCEPT -c 0 0
+-A FORWARD -s 10.0.0.0/8 -p tcp -m physdev --physdev-out veth0 -m tcp --dport 22 -m comment --comment "pritunl_cloud_rule_aaaaaaaaaaaa,bbbbbbbbbbbb" -c 12 720 -j ACCEPT
+-A FORWARD -p udp -m physdev --physdev-out veth0 -m udp --dport 53 -m comment --comment pritunl_cloud_rule_aaaaaaaaaaaa -c 3 180 -j ACCEPT
+-A FORWARD -m physdev --physdev-out veth0 -m comment --comment pritunl_cloud_rule -c 9 540 -j DROP
+`
+
+	counters := map[string]int64{}
+	parseCounters(output, counters)
+
+	want := map[string]int64{
+		"aaaaaaaaaaaa": 15,
+		"bbbbbbbbbbbb": 12,
+	}
+	if !reflect.DeepEqual(counters, want) {
+		t.Errorf("counters = %v, want %v", counters, want)
+	}
+}
@@ -88,12 +88,17 @@ func getState(vpcId bson.ObjectId, uri, localAddr, pubAddr, pubAddr6 string) (
 		linkStatus = status[uriData.User.Username()]
 	}
 
+	LinkErrorsLock.Lock()
+	linkErrors := LinkErrors[vpcId]
+	LinkErrorsLock.Unlock()
+
 	data := &stateData{
 		Version:       Version,
 		PublicAddress: pubAddr,
 		LocalAddress:  localAddr,
 		Address6:      pubAddr6,
 		Status:        linkStatus,
+		Errors:        linkErrors,
 	}
 	dataBuf := &bytes.Buffer{}
 
@@ -245,6 +250,7 @@ func GetStates(vpcId bson.ObjectId, uris []string,
 
 	states = []*State{}
 	urisSet := set.NewSet()
+	errs := []string{}
 
 	for _, uri := range uris {
 		urisSet.Add(uri)
@@ -255,12 +261,17 @@ func GetStates(vpcId bson.ObjectId, uris []string,
 				"uri":   uri,
 				"error": err,
 			}).Info("state: Failed to get state")
+			errs = append(errs, errors.GetMessage(err))
 			continue
 		}
 
 		states = append(states, state)
 	}
 
+	LinkErrorsLock.Lock()
+	LinkErrors[vpcId] = errs
+	LinkErrorsLock.Unlock()
+
 	vpcCache := caches[vpcId]
 	if vpcCache != nil {
 		for uri := range vpcCache {
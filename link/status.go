@@ -8,13 +8,23 @@ import (
 	"github.com/pritunl/pritunl-cloud/vm"
 	"gopkg.in/mgo.v2/bson"
 	"strings"
+	"sync"
 	"time"
 )
 
 var (
-	offlineTime time.Time
+	offlineTime    time.Time
+	linkStates     = map[bson.ObjectId]map[string]string{}
+	LinkErrors     = map[bson.ObjectId][]string{}
+	LinkErrorsLock = sync.Mutex{}
 )
 
+type Transition struct {
+	Link           string
+	Status         string
+	PreviousStatus string
+}
+
 func GetStatus(vpcId bson.ObjectId) (status Status, err error) {
 	status = Status{}
 	namespace := vm.GetLinkNamespace(vpcId, 0)
@@ -75,8 +85,48 @@ func GetStatus(vpcId bson.ObjectId) (status Status, err error) {
 	return
 }
 
+func getTransitions(vpcId bson.ObjectId, names set.Set, stats Status) (
+	transitions []*Transition) {
+
+	transitions = []*Transition{}
+	newStates := map[string]string{}
+
+	for nameInf := range names.Iter() {
+		newStates[nameInf.(string)] = "disconnected"
+	}
+
+	for stateId, conns := range stats {
+		for connId, connStatus := range conns {
+			id := fmt.Sprintf("%s-%s", stateId, connId)
+			if _, ok := newStates[id]; ok {
+				newStates[id] = connStatus
+			}
+		}
+	}
+
+	LinkStatusLock.Lock()
+	curStates := linkStates[vpcId]
+	linkStates[vpcId] = newStates
+	LinkStatusLock.Unlock()
+
+	for id, status := range newStates {
+		prevStatus, ok := curStates[id]
+		if !ok || prevStatus == status {
+			continue
+		}
+
+		transitions = append(transitions, &Transition{
+			Link:           id,
+			Status:         status,
+			PreviousStatus: prevStatus,
+		})
+	}
+
+	return
+}
+
 func Update(vpcId bson.ObjectId, names set.Set) (
-	resetLinks []string, err error) {
+	resetLinks []string, transitions []*Transition, err error) {
 
 	resetLinks = []string{}
 
@@ -89,6 +139,8 @@ func Update(vpcId bson.ObjectId, names set.Set) (
 	LinkStatus[vpcId] = stats
 	LinkStatusLock.Unlock()
 
+	transitions = getTransitions(vpcId, names, stats)
+
 	unknown := set.NewSet()
 	for stateId, conns := range stats {
 		for connId, connStatus := range conns {
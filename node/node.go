@@ -2,6 +2,7 @@ package node
 
 import (
 	"container/list"
+	"fmt"
 	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/pritunl/pritunl-cloud/certificate"
@@ -10,6 +11,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/systemd"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -52,8 +54,14 @@ type Node struct {
 	MemoryUnits        float64                    `bson:"memory_units" json:"memory_units"`
 	CpuUnitsRes        int                        `bson:"cpu_units_res" json:"cpu_units_res"`
 	MemoryUnitsRes     float64                    `bson:"memory_units_res" json:"memory_units_res"`
+	CpuOvercommit      float64                    `bson:"cpu_overcommit" json:"cpu_overcommit"`
+	MemoryOvercommit   float64                    `bson:"memory_overcommit" json:"memory_overcommit"`
+	Fenced             bool                       `bson:"fenced" json:"fenced"`
+	FencedTimestamp    time.Time                  `bson:"fenced_timestamp" json:"fenced_timestamp"`
 	PublicIps          []string                   `bson:"public_ips" json:"public_ips"`
 	PublicIps6         []string                   `bson:"public_ips6" json:"public_ips6"`
+	PrivateIps         []string                   `bson:"private_ips" json:"private_ips"`
+	SelfCertificate    string                     `bson:"self_certificate" json:"-"`
 	SoftwareVersion    string                     `bson:"software_version" json:"software_version"`
 	Version            int                        `bson:"version" json:"-"`
 	VirtPath           string                     `bson:"virt_path" json:"virt_path"`
@@ -118,6 +126,14 @@ func (n *Node) Validate(db *database.Database) (
 		n.Hypervisor = Kvm
 	}
 
+	if n.CpuOvercommit < 0 || n.MemoryOvercommit < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "node_overcommit_invalid",
+			Message: "Node overcommit ratio cannot be negative",
+		}
+		return
+	}
+
 	if n.Protocol != "http" && n.Protocol != "https" {
 		errData = &errortypes.ErrorData{
 			Error:   "node_protocol_invalid",
@@ -196,8 +212,12 @@ func (n *Node) Format() {
 	utils.SortObjectIds(n.Certificates)
 }
 
+func (n *Node) IsStale() bool {
+	return time.Since(n.Timestamp) > 30*time.Second
+}
+
 func (n *Node) SetActive() {
-	if time.Since(n.Timestamp) > 30*time.Second {
+	if n.IsStale() {
 		n.RequestsMin = 0
 		n.Memory = 0
 		n.Load1 = 0
@@ -265,6 +285,8 @@ func (n *Node) update(db *database.Database) (err error) {
 				"memory_units_res": n.MemoryUnitsRes,
 				"public_ips":       n.PublicIps,
 				"public_ips6":      n.PublicIps6,
+				"private_ips":      n.PrivateIps,
+				"self_certificate": n.SelfCertificate,
 			},
 		},
 		Upsert:    false,
@@ -275,11 +297,19 @@ func (n *Node) update(db *database.Database) (err error) {
 
 	_, err = coll.Find(&bson.M{
 		"_id": n.Id,
+		"fenced": &bson.M{
+			"$ne": true,
+		},
 	}).Apply(change, nde)
 	if err != nil {
+		if err == mgo.ErrNotFound {
+			err = n.checkFenced(db)
+		}
 		return
 	}
 
+	n.Fenced = false
+
 	n.Id = nde.Id
 	n.Name = nde.Name
 	n.Types = nde.Types
@@ -294,12 +324,62 @@ func (n *Node) update(db *database.Database) (err error) {
 	n.InternalInterface = nde.InternalInterface
 	n.Firewall = nde.Firewall
 	n.NetworkRoles = nde.NetworkRoles
+	n.CpuOvercommit = nde.CpuOvercommit
+	n.MemoryOvercommit = nde.MemoryOvercommit
 	n.VirtPath = nde.VirtPath
 	n.CachePath = nde.CachePath
 
 	return
 }
 
+func (n *Node) checkFenced(db *database.Database) (err error) {
+	coll := db.Nodes()
+	nde := &Node{}
+
+	err = coll.FindOneId(n.Id, nde)
+	if err != nil {
+		return
+	}
+
+	if nde.Fenced && !n.Fenced {
+		logrus.WithFields(logrus.Fields{
+			"node_id": n.Id.Hex(),
+		}).Error("node: Node has been fenced, refusing node updates")
+	}
+	n.Fenced = nde.Fenced
+
+	return
+}
+
+func (n *Node) Fence(db *database.Database) (fenced bool, err error) {
+	coll := db.Nodes()
+
+	err = coll.Update(&bson.M{
+		"_id":       n.Id,
+		"timestamp": n.Timestamp,
+		"fenced": &bson.M{
+			"$ne": true,
+		},
+	}, &bson.M{
+		"$set": &bson.M{
+			"fenced":           true,
+			"fenced_timestamp": time.Now(),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	n.Fenced = true
+	fenced = true
+
+	return
+}
+
 func (n *Node) loadCerts(db *database.Database) (err error) {
 	certObjs := []*certificate.Certificate{}
 
@@ -334,6 +414,7 @@ func (n *Node) sync() {
 	defer db.Close()
 
 	n.Timestamp = time.Now()
+	n.SelfCertificate = certificate.GetSelfCert()
 
 	mem, total, err := utils.MemoryUsed()
 	if err != nil {
@@ -413,6 +494,29 @@ func (n *Node) sync() {
 		break
 	}
 
+	n.PrivateIps = []string{}
+	if n.InternalInterface != "" {
+		ipData, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"No such file or directory",
+				"does not exist",
+			},
+			"ip", "-f", "inet", "-o", "addr",
+			"show", "dev", n.InternalInterface,
+		)
+		if err != nil {
+			return
+		}
+
+		fields = strings.Fields(ipData)
+		if len(fields) > 3 {
+			ipAddr := net.ParseIP(strings.Split(fields[3], "/")[0])
+			n.PrivateIps = []string{
+				ipAddr.String(),
+			}
+		}
+	}
+
 	err = n.update(db)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
@@ -435,6 +539,71 @@ func (n *Node) keepalive() {
 	}
 }
 
+func (n *Node) IsFenced() bool {
+	return n.Fenced
+}
+
+func getRecoverUnits(instIds []bson.ObjectId) (units []string) {
+	units = []string{}
+
+	for _, instId := range instIds {
+		units = append(units,
+			fmt.Sprintf("pritunl_cloud_%s.service", instId.Hex()))
+	}
+
+	return
+}
+
+func (n *Node) stopInstances(db *database.Database) {
+	coll := db.Instances()
+	instIds := []bson.ObjectId{}
+
+	err := coll.Find(&bson.M{
+		"node":         n.Id,
+		"auto_recover": true,
+	}).Distinct("_id", &instIds)
+	if err != nil {
+		err = database.ParseError(err)
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("node: Failed to get fenced node instances")
+		return
+	}
+
+	for _, unit := range getRecoverUnits(instIds) {
+		state, _ := systemd.GetState(unit)
+		if state != "active" && state != "activating" {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"unit": unit,
+		}).Error("node: Node fenced, stopping instance")
+
+		err = systemd.Stop(unit)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"unit":  unit,
+				"error": err,
+			}).Error("node: Failed to stop instance")
+		}
+	}
+}
+
+func (n *Node) watchdog() {
+	for {
+		time.Sleep(1 * time.Second)
+
+		if !n.IsHypervisor() || !n.IsFenced() {
+			continue
+		}
+
+		db := database.GetDatabase()
+		n.stopInstances(db)
+		db.Close()
+	}
+}
+
 func (n *Node) reqInit() {
 	n.reqLock.Lock()
 	n.reqCount = list.New()
@@ -531,6 +700,7 @@ func (n *Node) Init() (err error) {
 
 	go n.keepalive()
 	go n.reqSync()
+	go n.watchdog()
 
 	return
 }
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNodeIsFenced(t *testing.T) {
+	tests := []struct {
+		name   string
+		nde    *Node
+		fenced bool
+	}{
+		{
+			name: "updating",
+			nde: &Node{
+				Types:     []string{Hypervisor},
+				Timestamp: time.Now(),
+			},
+			fenced: false,
+		},
+		{
+			name: "database_unreachable",
+			nde: &Node{
+				Types:     []string{Hypervisor},
+				Timestamp: time.Now().Add(-24 * time.Hour),
+			},
+			fenced: false,
+		},
+		{
+			name: "fenced",
+			nde: &Node{
+				Types:     []string{Hypervisor},
+				Timestamp: time.Now().Add(-24 * time.Hour),
+				Fenced:    true,
+			},
+			fenced: true,
+		},
+	}
+
+	for _, test := range tests {
+		fenced := test.nde.IsFenced()
+		if fenced != test.fenced {
+			t.Errorf("%s: fenced = %t, want %t",
+				test.name, fenced, test.fenced)
+		}
+	}
+}
+
+func TestNodeIsStale(t *testing.T) {
+	tests := []struct {
+		name  string
+		nde   *Node
+		stale bool
+	}{
+		{
+			name: "active",
+			nde: &Node{
+				Timestamp: time.Now().Add(-5 * time.Second),
+			},
+			stale: false,
+		},
+		{
+			name: "stale",
+			nde: &Node{
+				Timestamp: time.Now().Add(-2 * time.Minute),
+			},
+			stale: true,
+		},
+		{
+			name:  "never_updated",
+			nde:   &Node{},
+			stale: true,
+		},
+	}
+
+	for _, test := range tests {
+		stale := test.nde.IsStale()
+		if stale != test.stale {
+			t.Errorf("%s: stale = %t, want %t",
+				test.name, stale, test.stale)
+		}
+	}
+}
+
+func TestGetRecoverUnits(t *testing.T) {
+	instId := bson.ObjectIdHex("5a1b2c3d4e5f60718293a4b5")
+
+	tests := []struct {
+		name    string
+		instIds []bson.ObjectId
+		units   []string
+	}{
+		{
+			name:    "none",
+			instIds: []bson.ObjectId{},
+			units:   []string{},
+		},
+		{
+			name:    "auto_recover",
+			instIds: []bson.ObjectId{instId},
+			units: []string{
+				"pritunl_cloud_5a1b2c3d4e5f60718293a4b5.service",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		units := getRecoverUnits(test.instIds)
+		if !reflect.DeepEqual(units, test.units) {
+			t.Errorf("%s: units = %v, want %v",
+				test.name, units, test.units)
+		}
+	}
+}
@@ -42,6 +42,32 @@ func GetAll(db *database.Database) (nodes []*Node, err error) {
 	return
 }
 
+func GetZone(db *database.Database, zoneId bson.ObjectId) (
+	nodes []*Node, err error) {
+
+	coll := db.Nodes()
+	nodes = []*Node{}
+
+	cursor := coll.Find(&bson.M{
+		"zone": zoneId,
+	}).Sort("name").Iter()
+
+	nde := &Node{}
+	for cursor.Next(nde) {
+		nde.SetActive()
+		nodes = append(nodes, nde)
+		nde = &Node{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func GetAllHypervisors(db *database.Database, query *bson.M) (
 	nodes []*Node, err error) {
 
@@ -4,14 +4,21 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"gopkg.in/mgo.v2/bson"
+	"time"
 )
 
 type Organization struct {
-	Id    bson.ObjectId `bson:"_id,omitempty" json:"id"`
-	Roles []string      `bson:"roles" json:"roles"`
-	Name  string        `bson:"name" json:"name"`
+	Id                 bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Roles              []string      `bson:"roles" json:"roles"`
+	Name               string        `bson:"name" json:"name"`
+	SnapshotSchedule   string        `bson:"snapshot_schedule" json:"snapshot_schedule"`
+	SnapshotRetention  int           `bson:"snapshot_retention" json:"snapshot_retention"`
+	Quota              *Quota        `bson:"quota,omitempty" json:"quota"`
+	QuotaLock          bson.ObjectId `bson:"quota_lock,omitempty" json:"-"`
+	QuotaLockTimestamp time.Time     `bson:"quota_lock_timestamp,omitempty" json:"-"`
 }
 
 func (d *Organization) Validate(db *database.Database) (
@@ -21,6 +28,28 @@ func (d *Organization) Validate(db *database.Database) (
 		d.Roles = []string{}
 	}
 
+	switch d.SnapshotSchedule {
+	case "", disk.Disabled, disk.Hourly, disk.Daily, disk.Weekly:
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "snapshot_schedule_invalid",
+			Message: "Organization snapshot schedule invalid",
+		}
+		return
+	}
+
+	if d.SnapshotRetention < 0 {
+		d.SnapshotRetention = 0
+	}
+
+	if d.Quota != nil {
+		errData = d.Quota.Validate()
+		if errData != nil {
+			return
+		}
+	}
+
 	return
 }
 
@@ -0,0 +1,335 @@
+package organization
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+const quotaLockTimeout = 15 * time.Second
+
+// Limits of zero are unlimited
+type Quota struct {
+	Instances  int `bson:"instances" json:"instances"`
+	Processors int `bson:"processors" json:"processors"`
+	Memory     int `bson:"memory" json:"memory"`
+	Disk       int `bson:"disk" json:"disk"`
+	Vpcs       int `bson:"vpcs" json:"vpcs"`
+	Snapshots  int `bson:"snapshots" json:"snapshots"`
+}
+
+type Usage struct {
+	Instances  int `bson:"instances" json:"instances"`
+	Processors int `bson:"processors" json:"processors"`
+	Memory     int `bson:"memory" json:"memory"`
+	Disk       int `bson:"disk" json:"disk"`
+	Vpcs       int `bson:"vpcs" json:"vpcs"`
+	Snapshots  int `bson:"snapshots" json:"snapshots"`
+}
+
+func (q *Quota) Validate() (errData *errortypes.ErrorData) {
+	if q.Instances < 0 || q.Processors < 0 || q.Memory < 0 ||
+		q.Disk < 0 || q.Vpcs < 0 || q.Snapshots < 0 {
+
+		errData = &errortypes.ErrorData{
+			Error:   "quota_invalid",
+			Message: "Organization quota cannot be negative",
+		}
+		return
+	}
+
+	return
+}
+
+func exceeds(limit, used, requested int) bool {
+	return limit != 0 && requested > 0 && used+requested > limit
+}
+
+func (q *Quota) Check(usage, req *Usage) (errData *errortypes.ErrorData) {
+	if exceeds(q.Instances, usage.Instances, req.Instances) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_instances_exceeded",
+			Message: "Organization instance quota exceeded",
+		}
+		return
+	}
+
+	if exceeds(q.Processors, usage.Processors, req.Processors) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_processors_exceeded",
+			Message: "Organization processor quota exceeded",
+		}
+		return
+	}
+
+	if exceeds(q.Memory, usage.Memory, req.Memory) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_memory_exceeded",
+			Message: "Organization memory quota exceeded",
+		}
+		return
+	}
+
+	if exceeds(q.Disk, usage.Disk, req.Disk) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_disk_exceeded",
+			Message: "Organization disk quota exceeded",
+		}
+		return
+	}
+
+	if exceeds(q.Vpcs, usage.Vpcs, req.Vpcs) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_vpcs_exceeded",
+			Message: "Organization VPC quota exceeded",
+		}
+		return
+	}
+
+	if exceeds(q.Snapshots, usage.Snapshots, req.Snapshots) {
+		errData = &errortypes.ErrorData{
+			Error:   "quota_snapshots_exceeded",
+			Message: "Organization snapshot quota exceeded",
+		}
+		return
+	}
+
+	return
+}
+
+type usageSum struct {
+	Count      int `bson:"count"`
+	Processors int `bson:"processors"`
+	Memory     int `bson:"memory"`
+	Size       int `bson:"size"`
+	Snapshots  int `bson:"snapshots"`
+}
+
+func GetUsage(db *database.Database, orgId bson.ObjectId) (
+	usage *Usage, err error) {
+
+	usage = &Usage{}
+
+	instSum := &usageSum{}
+	err = db.Instances().Pipe([]*bson.M{
+		&bson.M{
+			"$match": &bson.M{
+				"organization": orgId,
+			},
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": nil,
+				"count": &bson.M{
+					"$sum": 1,
+				},
+				"processors": &bson.M{
+					"$sum": "$processors",
+				},
+				"memory": &bson.M{
+					"$sum": "$memory",
+				},
+			},
+		},
+	}).One(instSum)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			err = database.ParseError(err)
+			return
+		}
+		err = nil
+	}
+
+	diskSum := &usageSum{}
+	err = db.Disks().Pipe([]*bson.M{
+		&bson.M{
+			"$match": &bson.M{
+				"organization": orgId,
+			},
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": nil,
+				"size": &bson.M{
+					"$sum": "$size",
+				},
+				"snapshots": &bson.M{
+					"$sum": &bson.M{
+						"$cond": []interface{}{
+							&bson.M{
+								"$eq": []string{"$state", disk.Snapshot},
+							},
+							1,
+							0,
+						},
+					},
+				},
+			},
+		},
+	}).One(diskSum)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			err = database.ParseError(err)
+			return
+		}
+		err = nil
+	}
+
+	// Initial disks are created by the node after the instance is inserted
+	initDisks := []bson.ObjectId{}
+	err = db.Disks().Find(&bson.M{
+		"organization": orgId,
+		"index":        "0",
+	}).Distinct("instance", &initDisks)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	pendingSum := &usageSum{}
+	err = db.Instances().Pipe([]*bson.M{
+		&bson.M{
+			"$match": &bson.M{
+				"organization": orgId,
+				"init_disk_size": &bson.M{
+					"$gt": 0,
+				},
+				"_id": &bson.M{
+					"$nin": initDisks,
+				},
+			},
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": nil,
+				"size": &bson.M{
+					"$sum": "$init_disk_size",
+				},
+			},
+		},
+	}).One(pendingSum)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			err = database.ParseError(err)
+			return
+		}
+		err = nil
+	}
+
+	vpcs, err := db.Vpcs().Find(&bson.M{
+		"organization": orgId,
+	}).Count()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	snapshots, err := db.Images().Find(&bson.M{
+		"organization": orgId,
+		"disk": &bson.M{
+			"$exists": true,
+		},
+		"deleted": &bson.M{
+			"$ne": true,
+		},
+	}).Count()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	usage.Instances = instSum.Count
+	usage.Processors = instSum.Processors
+	usage.Memory = instSum.Memory
+	usage.Disk = diskSum.Size + pendingSum.Size
+	usage.Vpcs = vpcs
+	usage.Snapshots = snapshots + diskSum.Snapshots
+
+	return
+}
+
+func QuotaLock(db *database.Database, orgId bson.ObjectId) (
+	lockId bson.ObjectId, err error) {
+
+	coll := db.Organizations()
+	lockId = bson.NewObjectId()
+	start := time.Now()
+
+	for {
+		err = coll.Update(&bson.M{
+			"_id": orgId,
+			"$or": []*bson.M{
+				&bson.M{
+					"quota_lock": nil,
+				},
+				&bson.M{
+					"quota_lock_timestamp": &bson.M{
+						"$lt": time.Now().Add(-quotaLockTimeout),
+					},
+				},
+			},
+		}, &bson.M{
+			"$set": &bson.M{
+				"quota_lock":           lockId,
+				"quota_lock_timestamp": time.Now(),
+			},
+		})
+		if err == nil {
+			return
+		}
+
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); !ok {
+			return
+		}
+
+		if time.Since(start) > quotaLockTimeout {
+			err = &errortypes.DatabaseError{
+				errors.New("organization: Quota lock timeout"),
+			}
+			return
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func QuotaUnlock(db *database.Database, orgId, lockId bson.ObjectId) {
+	coll := db.Organizations()
+
+	coll.Update(&bson.M{
+		"_id":        orgId,
+		"quota_lock": lockId,
+	}, &bson.M{
+		"$unset": &bson.M{
+			"quota_lock":           "",
+			"quota_lock_timestamp": "",
+		},
+	})
+}
+
+func CheckQuota(db *database.Database, orgId bson.ObjectId, req *Usage) (
+	errData *errortypes.ErrorData, err error) {
+
+	org, err := Get(db, orgId)
+	if err != nil {
+		return
+	}
+
+	if org.Quota == nil {
+		return
+	}
+
+	usage, err := GetUsage(db, orgId)
+	if err != nil {
+		return
+	}
+
+	errData = org.Quota.Check(usage, req)
+
+	return
+}
@@ -0,0 +1,130 @@
+package organization
+
+import (
+	"testing"
+)
+
+func TestQuotaCheck(t *testing.T) {
+	quota := &Quota{
+		Instances:  10,
+		Processors: 16,
+		Memory:     32768,
+		Disk:       500,
+		Snapshots:  5,
+	}
+
+	tests := []struct {
+		name  string
+		quota *Quota
+		usage *Usage
+		req   *Usage
+		err   string
+	}{
+		{
+			name:  "unlimited",
+			quota: &Quota{},
+			usage: &Usage{Instances: 100, Processors: 400},
+			req:   &Usage{Instances: 1, Processors: 4},
+		},
+		{
+			name:  "within",
+			quota: quota,
+			usage: &Usage{Instances: 5, Processors: 8, Memory: 16384},
+			req:   &Usage{Instances: 1, Processors: 4, Memory: 8192},
+		},
+		{
+			name:  "exact",
+			quota: quota,
+			usage: &Usage{Instances: 9, Disk: 400},
+			req:   &Usage{Instances: 1, Disk: 100},
+		},
+		{
+			name:  "already_over_no_request",
+			quota: quota,
+			usage: &Usage{Instances: 12},
+			req:   &Usage{Processors: 1},
+		},
+		{
+			name:  "instances",
+			quota: quota,
+			usage: &Usage{Instances: 10},
+			req:   &Usage{Instances: 1},
+			err:   "quota_instances_exceeded",
+		},
+		{
+			name:  "processors",
+			quota: quota,
+			usage: &Usage{Processors: 14},
+			req:   &Usage{Instances: 1, Processors: 4},
+			err:   "quota_processors_exceeded",
+		},
+		{
+			name:  "memory",
+			quota: quota,
+			usage: &Usage{Memory: 30000},
+			req:   &Usage{Memory: 4096},
+			err:   "quota_memory_exceeded",
+		},
+		{
+			name:  "disk",
+			quota: quota,
+			usage: &Usage{Disk: 450},
+			req:   &Usage{Disk: 100},
+			err:   "quota_disk_exceeded",
+		},
+		{
+			name:  "vpcs",
+			quota: &Quota{Vpcs: 2},
+			usage: &Usage{Vpcs: 2},
+			req:   &Usage{Vpcs: 1},
+			err:   "quota_vpcs_exceeded",
+		},
+		{
+			name:  "snapshots",
+			quota: quota,
+			usage: &Usage{Snapshots: 5},
+			req:   &Usage{Snapshots: 1},
+			err:   "quota_snapshots_exceeded",
+		},
+	}
+
+	for _, test := range tests {
+		errData := test.quota.Check(test.usage, test.req)
+		if test.err == "" {
+			if errData != nil {
+				t.Errorf("%s: unexpected error %s", test.name, errData.Error)
+			}
+			continue
+		}
+
+		if errData == nil || errData.Error != test.err {
+			t.Errorf("%s: error = %v, want %s", test.name, errData, test.err)
+		}
+	}
+}
+
+func TestQuotaValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		quota *Quota
+		err   string
+	}{
+		{"empty", &Quota{}, ""},
+		{"positive", &Quota{Instances: 5, Disk: 100}, ""},
+		{"negative", &Quota{Memory: -1}, "quota_invalid"},
+	}
+
+	for _, test := range tests {
+		errData := test.quota.Validate()
+		if test.err == "" {
+			if errData != nil {
+				t.Errorf("%s: unexpected error %s", test.name, errData.Error)
+			}
+			continue
+		}
+
+		if errData == nil || errData.Error != test.err {
+			t.Errorf("%s: error = %v, want %s", test.name, errData, test.err)
+		}
+	}
+}
@@ -75,3 +75,23 @@ func GetGuestPath(virtId bson.ObjectId) string {
 	return path.Join(settings.Hypervisor.LibPath,
 		fmt.Sprintf("%s.guest", virtId.Hex()))
 }
+
+func GetSerialPath(virtId bson.ObjectId) string {
+	return path.Join(settings.Hypervisor.LibPath,
+		fmt.Sprintf("%s.serial", virtId.Hex()))
+}
+
+func GetVncPath(virtId bson.ObjectId) string {
+	return path.Join(settings.Hypervisor.LibPath,
+		fmt.Sprintf("%s.vnc", virtId.Hex()))
+}
+
+func GetQmpSockPath(virtId bson.ObjectId) string {
+	return path.Join(settings.Hypervisor.LibPath,
+		fmt.Sprintf("%s.qmp.sock", virtId.Hex()))
+}
+
+func GetTlsPath(virtId bson.ObjectId) string {
+	return path.Join(settings.Hypervisor.LibPath,
+		fmt.Sprintf("%s.tls", virtId.Hex()))
+}
@@ -0,0 +1,9 @@
+package placement
+
+const (
+	Affinity     = "affinity"
+	AntiAffinity = "anti_affinity"
+
+	Node = "node"
+	Zone = "zone"
+)
@@ -0,0 +1,260 @@
+package placement
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type Group struct {
+	Id           bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Name         string        `bson:"name" json:"name"`
+	Organization bson.ObjectId `bson:"organization" json:"organization"`
+	Type         string        `bson:"type" json:"type"`
+	Scope        string        `bson:"scope" json:"scope"`
+}
+
+type member struct {
+	Id   bson.ObjectId `bson:"_id"`
+	Node bson.ObjectId `bson:"node"`
+	Zone bson.ObjectId `bson:"zone"`
+}
+
+type Members struct {
+	group *Group
+	Nodes set.Set
+	Zones set.Set
+	Count int
+}
+
+func (m *Members) Allowed(nodeId, zoneId bson.ObjectId) bool {
+	switch m.group.Type {
+	case AntiAffinity:
+		if m.group.Scope == Zone {
+			return !m.Zones.Contains(zoneId)
+		}
+		return !m.Nodes.Contains(nodeId)
+	case Affinity:
+		if m.Count == 0 {
+			return true
+		}
+		if m.group.Scope == Zone {
+			return m.Zones.Contains(zoneId)
+		}
+		return m.Nodes.Contains(nodeId)
+	}
+
+	return true
+}
+
+func (g *Group) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if g.Organization == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	switch g.Type {
+	case Affinity, AntiAffinity:
+		break
+	case "":
+		g.Type = AntiAffinity
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "placement_type_invalid",
+			Message: "Placement group type invalid",
+		}
+		return
+	}
+
+	switch g.Scope {
+	case Node, Zone:
+		break
+	case "":
+		g.Scope = Node
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "placement_scope_invalid",
+			Message: "Placement group scope invalid",
+		}
+		return
+	}
+
+	return
+}
+
+func (g *Group) GetMembers(db *database.Database,
+	excludeId bson.ObjectId) (members *Members, err error) {
+
+	coll := db.Instances()
+	members = &Members{
+		group: g,
+		Nodes: set.NewSet(),
+		Zones: set.NewSet(),
+	}
+
+	query := bson.M{
+		"placement_group": g.Id,
+	}
+	if excludeId != "" {
+		query["_id"] = &bson.M{
+			"$ne": excludeId,
+		}
+	}
+
+	cursor := coll.Find(query).Select(&bson.M{
+		"node": 1,
+		"zone": 1,
+	}).Iter()
+
+	mem := &member{}
+	for cursor.Next(mem) {
+		members.Nodes.Add(mem.Node)
+		members.Zones.Add(mem.Zone)
+		members.Count += 1
+		mem = &member{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func (g *Group) GetViolations(db *database.Database) (
+	violations set.Set, err error) {
+
+	coll := db.Instances()
+	members := []*member{}
+
+	cursor := coll.Find(&bson.M{
+		"placement_group": g.Id,
+	}).Select(&bson.M{
+		"node": 1,
+		"zone": 1,
+	}).Iter()
+
+	mem := &member{}
+	for cursor.Next(mem) {
+		members = append(members, mem)
+		mem = &member{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	violations = g.violations(members)
+
+	return
+}
+
+func (g *Group) violations(members []*member) (violations set.Set) {
+	violations = set.NewSet()
+	locations := map[bson.ObjectId][]bson.ObjectId{}
+	order := []bson.ObjectId{}
+
+	for _, mem := range members {
+		location := mem.Node
+		if g.Scope == Zone {
+			location = mem.Zone
+		}
+
+		if _, ok := locations[location]; !ok {
+			order = append(order, location)
+		}
+		locations[location] = append(locations[location], mem.Id)
+	}
+
+	switch g.Type {
+	case AntiAffinity:
+		for _, instIds := range locations {
+			if len(instIds) < 2 {
+				continue
+			}
+
+			for _, instId := range instIds {
+				violations.Add(instId)
+			}
+		}
+		break
+	case Affinity:
+		var primary bson.ObjectId
+		for _, location := range order {
+			if primary == "" ||
+				len(locations[location]) > len(locations[primary]) {
+
+				primary = location
+			}
+		}
+
+		for location, instIds := range locations {
+			if location == primary {
+				continue
+			}
+
+			for _, instId := range instIds {
+				violations.Add(instId)
+			}
+		}
+		break
+	}
+
+	return
+}
+
+func (g *Group) Commit(db *database.Database) (err error) {
+	coll := db.PlacementGroups()
+
+	err = coll.Commit(g.Id, g)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Group) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.PlacementGroups()
+
+	err = coll.CommitFields(g.Id, g, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Group) Insert(db *database.Database) (err error) {
+	coll := db.PlacementGroups()
+
+	if g.Id != "" {
+		err = &errortypes.DatabaseError{
+			errors.New("placement: Group already exists"),
+		}
+		return
+	}
+
+	err = coll.Insert(g)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,118 @@
+package placement
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+)
+
+func TestMembersAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		scope   string
+		nodes   []bson.ObjectId
+		zones   []bson.ObjectId
+		node    bson.ObjectId
+		zone    bson.ObjectId
+		allowed bool
+	}{
+		{"anti_node_empty", AntiAffinity, Node,
+			nil, nil, "n1", "z1", true},
+		{"anti_node_free", AntiAffinity, Node,
+			[]bson.ObjectId{"n1"}, []bson.ObjectId{"z1"}, "n2", "z1", true},
+		{"anti_node_used", AntiAffinity, Node,
+			[]bson.ObjectId{"n1"}, []bson.ObjectId{"z1"}, "n1", "z1", false},
+		{"anti_zone_used", AntiAffinity, Zone,
+			[]bson.ObjectId{"n1"}, []bson.ObjectId{"z1"}, "n2", "z1", false},
+		{"anti_zone_free", AntiAffinity, Zone,
+			[]bson.ObjectId{"n1"}, []bson.ObjectId{"z1"}, "n2", "z2", true},
+		{"affinity_empty", Affinity, Node,
+			nil, nil, "n1", "z1", true},
+		{"affinity_node_same", Affinity, Node,
+			[]bson.ObjectId{"n1"}, []bson.ObjectId{"z1"}, "n1", "z1", true},
+		{"affinity_node_other", Affinity, Node,
+			[]bson.ObjectId{"n1"}, []bson.ObjectId{"z1"}, "n2", "z1", false},
+		{"affinity_zone_same", Affinity, Zone,
+			[]bson.ObjectId{"n1"}, []bson.ObjectId{"z1"}, "n2", "z1", true},
+		{"affinity_zone_other", Affinity, Zone,
+			[]bson.ObjectId{"n1"}, []bson.ObjectId{"z1"}, "n2", "z2", false},
+		{"unknown_type", "", Node,
+			[]bson.ObjectId{"n1"}, []bson.ObjectId{"z1"}, "n1", "z1", true},
+	}
+
+	for _, test := range tests {
+		members := &Members{
+			group: &Group{
+				Type:  test.typ,
+				Scope: test.scope,
+			},
+			Nodes: set.NewSet(),
+			Zones: set.NewSet(),
+			Count: len(test.nodes),
+		}
+		for _, nodeId := range test.nodes {
+			members.Nodes.Add(nodeId)
+		}
+		for _, zoneId := range test.zones {
+			members.Zones.Add(zoneId)
+		}
+
+		allowed := members.Allowed(test.node, test.zone)
+		if allowed != test.allowed {
+			t.Errorf("%s: allowed = %t, want %t",
+				test.name, allowed, test.allowed)
+		}
+	}
+}
+
+func TestGroupViolations(t *testing.T) {
+	members := []*member{
+		&member{Id: "i1", Node: "n1", Zone: "z1"},
+		&member{Id: "i2", Node: "n1", Zone: "z1"},
+		&member{Id: "i3", Node: "n2", Zone: "z1"},
+		&member{Id: "i4", Node: "n3", Zone: "z2"},
+	}
+
+	tests := []struct {
+		name       string
+		typ        string
+		scope      string
+		members    []*member
+		violations []bson.ObjectId
+	}{
+		{"anti_node_empty", AntiAffinity, Node,
+			[]*member{}, []bson.ObjectId{}},
+		{"anti_node", AntiAffinity, Node,
+			members, []bson.ObjectId{"i1", "i2"}},
+		{"anti_zone", AntiAffinity, Zone,
+			members, []bson.ObjectId{"i1", "i2", "i3"}},
+		{"affinity_node", Affinity, Node,
+			members, []bson.ObjectId{"i3", "i4"}},
+		{"affinity_zone", Affinity, Zone,
+			members, []bson.ObjectId{"i4"}},
+		{"affinity_tie", Affinity, Node,
+			members[2:], []bson.ObjectId{"i4"}},
+	}
+
+	for _, test := range tests {
+		grp := &Group{
+			Type:  test.typ,
+			Scope: test.scope,
+		}
+
+		violations := grp.violations(test.members)
+		if violations.Len() != len(test.violations) {
+			t.Errorf("%s: violations = %d, want %d",
+				test.name, violations.Len(), len(test.violations))
+			continue
+		}
+
+		for _, instId := range test.violations {
+			if !violations.Contains(instId) {
+				t.Errorf("%s: missing violation %s",
+					test.name, string(instId))
+			}
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package placement
+
+import (
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func Get(db *database.Database, groupId bson.ObjectId) (
+	group *Group, err error) {
+
+	coll := db.PlacementGroups()
+	group = &Group{}
+
+	err = coll.FindOneId(groupId, group)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, groupId bson.ObjectId) (
+	group *Group, err error) {
+
+	coll := db.PlacementGroups()
+	group = &Group{}
+
+	err = coll.FindOne(&bson.M{
+		"_id":          groupId,
+		"organization": orgId,
+	}, group)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func ExistsOrg(db *database.Database, orgId, groupId bson.ObjectId) (
+	exists bool, err error) {
+
+	coll := db.PlacementGroups()
+
+	n, err := coll.Find(&bson.M{
+		"_id":          groupId,
+		"organization": orgId,
+	}).Count()
+	if err != nil {
+		return
+	}
+
+	if n > 0 {
+		exists = true
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	groups []*Group, err error) {
+
+	coll := db.PlacementGroups()
+	groups = []*Group{}
+
+	cursor := coll.Find(query).Sort("name").Iter()
+
+	group := &Group{}
+	for cursor.Next(group) {
+		groups = append(groups, group)
+		group = &Group{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M, page, pageCount int) (
+	groups []*Group, count int, err error) {
+
+	coll := db.PlacementGroups()
+	groups = []*Group{}
+
+	qury := coll.Find(query)
+
+	count, err = qury.Count()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	skip := utils.Min(page*pageCount, utils.Max(0, count-pageCount))
+
+	cursor := qury.Sort("name").Skip(skip).Limit(pageCount).Iter()
+
+	group := &Group{}
+	for cursor.Next(group) {
+		groups = append(groups, group)
+		group = &Group{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Check(db *database.Database, orgId, groupId, instId,
+	nodeId, zoneId bson.ObjectId) (errData *errortypes.ErrorData, err error) {
+
+	group, err := GetOrg(db, orgId, groupId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "placement_group_not_found",
+				Message: "Placement group does not exist",
+			}
+		}
+		return
+	}
+
+	members, err := group.GetMembers(db, instId)
+	if err != nil {
+		return
+	}
+
+	if !members.Allowed(nodeId, zoneId) {
+		errData = &errortypes.ErrorData{
+			Error:   "placement_group_violation",
+			Message: "Instance placement violates placement group",
+		}
+		return
+	}
+
+	return
+}
+
+func GetFilter(db *database.Database, orgId, groupId bson.ObjectId) (
+	filter func(nodeId, zoneId bson.ObjectId) bool,
+	errData *errortypes.ErrorData, err error) {
+
+	group, err := GetOrg(db, orgId, groupId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "placement_group_not_found",
+				Message: "Placement group does not exist",
+			}
+		}
+		return
+	}
+
+	members, err := group.GetMembers(db, "")
+	if err != nil {
+		return
+	}
+
+	filter = members.Allowed
+
+	return
+}
+
+func GetViolations(db *database.Database, groupIds []bson.ObjectId) (
+	violations map[bson.ObjectId]bson.ObjectId, err error) {
+
+	violations = map[bson.ObjectId]bson.ObjectId{}
+
+	if len(groupIds) == 0 {
+		return
+	}
+
+	groups, err := GetAll(db, &bson.M{
+		"_id": &bson.M{
+			"$in": groupIds,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	for _, group := range groups {
+		instIds, e := group.GetViolations(db)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for instIdInf := range instIds.Iter() {
+			violations[instIdInf.(bson.ObjectId)] = group.Id
+		}
+	}
+
+	return
+}
+
+func clearInstances(db *database.Database, query *bson.M) (err error) {
+	coll := db.Instances()
+
+	_, err = coll.UpdateAll(query, &bson.M{
+		"$unset": &bson.M{
+			"placement_group": "",
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, groupId bson.ObjectId) (err error) {
+	coll := db.PlacementGroups()
+
+	err = clearInstances(db, &bson.M{
+		"placement_group": groupId,
+	})
+	if err != nil {
+		return
+	}
+
+	err = coll.Remove(&bson.M{
+		"_id": groupId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, groupId bson.ObjectId) (
+	err error) {
+
+	coll := db.PlacementGroups()
+
+	exists, err := ExistsOrg(db, orgId, groupId)
+	if err != nil || !exists {
+		return
+	}
+
+	err = clearInstances(db, &bson.M{
+		"organization":    orgId,
+		"placement_group": groupId,
+	})
+	if err != nil {
+		return
+	}
+
+	err = coll.Remove(&bson.M{
+		"_id":          groupId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, groupIds []bson.ObjectId) (
+	err error) {
+
+	coll := db.PlacementGroups()
+
+	err = clearInstances(db, &bson.M{
+		"placement_group": &bson.M{
+			"$in": groupIds,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	_, err = coll.RemoveAll(&bson.M{
+		"_id": &bson.M{
+			"$in": groupIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -1,5 +1,7 @@
 package qemu
 
+const migrateTlsId = "migrate_tls"
+
 const systemdTemplate = `[Unit]
 Description=Pritunl Cloud Virtual Machine
 After=network.target
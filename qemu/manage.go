@@ -6,6 +6,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/bridge"
 	"github.com/pritunl/pritunl-cloud/cloudinit"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
@@ -187,13 +188,13 @@ func GetVmInfo(vmId bson.ObjectId, getDisks bool) (
 			break
 		}
 
-		if len(virt.NetworkAdapters) > 0 {
+		if len(virt.NetworkAdapters) > 0 && !virt.PrivateOnly {
 			virt.NetworkAdapters[0].IpAddress = addr
 			virt.NetworkAdapters[0].IpAddress6 = addr6
 		}
 		store.SetAddress(virt.Id, addr, addr6)
 	} else {
-		if len(virt.NetworkAdapters) > 0 {
+		if len(virt.NetworkAdapters) > 0 && !virt.PrivateOnly {
 			virt.NetworkAdapters[0].IpAddress = addrStore.Addr
 			virt.NetworkAdapters[0].IpAddress6 = addrStore.Addr6
 		}
@@ -394,6 +395,14 @@ func NetworkConf(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		internalIface = externalIface
 	}
 
+	if virt.PrivateOnly {
+		externalIface, err = bridge.ConfigureNat()
+		if err != nil {
+			PowerOff(db, virt)
+			return
+		}
+	}
+
 	vc, err := vpc.Get(db, adapter.VpcId)
 	if err != nil {
 		return
@@ -739,15 +748,23 @@ func NetworkConf(db *database.Database, virt *vm.VirtualMachine) (err error) {
 
 	networkStopDhClient(db, virt)
 
-	_, err = utils.ExecCombinedOutputLogged(
-		nil,
-		"ip", "netns", "exec", namespace,
-		"dhclient", "-pf", pidPath,
-		ifaceExternal,
-	)
-	if err != nil {
-		PowerOff(db, virt)
-		return
+	if virt.FloatingIp != nil && !virt.PrivateOnly {
+		err = networkConfFloating(virt, namespace, ifaceExternal)
+		if err != nil {
+			PowerOff(db, virt)
+			return
+		}
+	} else {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"dhclient", "-pf", pidPath,
+			ifaceExternal,
+		)
+		if err != nil {
+			PowerOff(db, virt)
+			return
+		}
 	}
 
 	time.Sleep(2 * time.Second)
@@ -812,7 +829,7 @@ func NetworkConf(db *database.Database, virt *vm.VirtualMachine) (err error) {
 			break
 		}
 
-		if pubAddr != "" && (pubAddr6 != "" ||
+		if pubAddr != "" && (pubAddr6 != "" || virt.PrivateOnly ||
 			time.Since(start) > 8*time.Second) {
 
 			break
@@ -844,23 +861,31 @@ func NetworkConf(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		return
 	}
 
-	iptables.Lock()
-	_, err = utils.ExecCombinedOutputLogged(
-		nil,
-		"ip", "netns", "exec", namespace,
-		"iptables", "-t", "nat",
-		"-A", "PREROUTING",
-		"-d", pubAddr,
-		"-j", "DNAT",
-		"--to-destination", addr.String(),
-	)
-	iptables.Unlock()
-	if err != nil {
-		PowerOff(db, virt)
-		return
+	if virt.PrivateOnly {
+		logrus.WithFields(logrus.Fields{
+			"instance_id":   virt.Id.Hex(),
+			"net_namespace": namespace,
+			"nat_address":   pubAddr,
+		}).Info("qemu: Instance private only using node NAT")
+	} else {
+		iptables.Lock()
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"iptables", "-t", "nat",
+			"-A", "PREROUTING",
+			"-d", pubAddr,
+			"-j", "DNAT",
+			"--to-destination", addr.String(),
+		)
+		iptables.Unlock()
+		if err != nil {
+			PowerOff(db, virt)
+			return
+		}
 	}
 
-	if pubAddr6 != "" {
+	if pubAddr6 != "" && !virt.PrivateOnly {
 		iptables.Lock()
 		_, err = utils.ExecCombinedOutputLogged(
 			nil,
@@ -891,21 +916,37 @@ func NetworkConf(db *database.Database, virt *vm.VirtualMachine) (err error) {
 			PowerOff(db, virt)
 			return
 		}
-	} else {
+	} else if !virt.PrivateOnly {
 		logrus.WithFields(logrus.Fields{
 			"instance_id":   virt.Id.Hex(),
 			"net_namespace": namespace,
 		}).Warning("qemu: Instance missing IPv6 address")
 	}
 
+	privateIps := []string{addr.String()}
+	privateIps6 := []string{addr6.String()}
+
+	for n := 1; n < len(virt.NetworkAdapters); n++ {
+		secAddr, secAddr6, e := networkConfSecondary(db, virt, n)
+		if e != nil {
+			err = e
+			PowerOff(db, virt)
+			return
+		}
+
+		privateIps = append(privateIps, secAddr.String())
+		privateIps6 = append(privateIps6, secAddr6.String())
+	}
+
 	store.RemAddress(virt.Id)
 	store.RemRoutes(virt.Id)
+	store.RemPeers(virt.Id)
 
 	coll := db.Instances()
 	err = coll.UpdateId(virt.Id, &bson.M{
 		"$set": &bson.M{
-			"private_ips":  []string{addr.String()},
-			"private_ips6": []string{addr6.String()},
+			"private_ips":  privateIps,
+			"private_ips6": privateIps6,
 		},
 	})
 	if err != nil {
@@ -975,19 +1016,25 @@ func NetworkConfClear(db *database.Database,
 		"set", ifaceInternalVirt, "down")
 	utils.ExecCombinedOutput("", "ip", "link", "del", ifaceInternalVirt)
 
+	for n := 1; n < len(virt.NetworkAdapters); n++ {
+		networkConfSecondaryClear(virt, n)
+	}
+
 	store.RemAddress(virt.Id)
 	store.RemRoutes(virt.Id)
+	store.RemPeers(virt.Id)
 
 	return
 }
 
-func writeService(virt *vm.VirtualMachine) (err error) {
+func writeService(virt *vm.VirtualMachine, incoming bool) (err error) {
 	unitPath := paths.GetUnitPath(virt.Id)
 
 	qm, err := NewQemu(virt)
 	if err != nil {
 		return
 	}
+	qm.Incoming = incoming
 
 	output, err := qm.Marshal()
 	if err != nil {
@@ -1078,7 +1125,7 @@ func Create(db *database.Database, inst *instance.Instance,
 		return
 	}
 
-	err = writeService(virt)
+	err = writeService(virt, false)
 	if err != nil {
 		return
 	}
@@ -1115,7 +1162,10 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 	unitName := paths.GetUnitName(virt.Id)
 	unitPath := paths.GetUnitPath(virt.Id)
 	sockPath := paths.GetSockPath(virt.Id)
+	qmpSockPath := paths.GetQmpSockPath(virt.Id)
 	guestPath := paths.GetGuestPath(virt.Id)
+	serialPath := paths.GetSerialPath(virt.Id)
+	vncPath := paths.GetVncPath(virt.Id)
 	pidPath := paths.GetPidPath(virt.Id)
 
 	logrus.WithFields(logrus.Fields{
@@ -1176,11 +1226,26 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		return
 	}
 
+	err = utils.RemoveAll(qmpSockPath)
+	if err != nil {
+		return
+	}
+
 	err = utils.RemoveAll(guestPath)
 	if err != nil {
 		return
 	}
 
+	err = utils.RemoveAll(serialPath)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(vncPath)
+	if err != nil {
+		return
+	}
+
 	err = utils.RemoveAll(pidPath)
 	if err != nil {
 		return
@@ -1191,6 +1256,11 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		return
 	}
 
+	err = utils.RemoveAll(paths.GetTlsPath(virt.Id))
+	if err != nil {
+		return
+	}
+
 	err = utils.RemoveAll(unitPath)
 	if err != nil {
 		return
@@ -1200,6 +1270,7 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 	store.RemDisks(virt.Id)
 	store.RemAddress(virt.Id)
 	store.RemRoutes(virt.Id)
+	store.RemPeers(virt.Id)
 
 	return
 }
@@ -1217,7 +1288,7 @@ func PowerOn(db *database.Database, inst *instance.Instance,
 		return
 	}
 
-	err = writeService(virt)
+	err = writeService(virt, false)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,563 @@
+package qemu
+
+import (
+	"fmt"
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/cloudinit"
+	"github.com/pritunl/pritunl-cloud/data"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/store"
+	"github.com/pritunl/pritunl-cloud/systemd"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"net"
+	"strconv"
+	"time"
+)
+
+func getFreePort(host string) (port int, err error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		err = &errortypes.NetworkError{
+			errors.Wrap(err, "qemu: Failed to allocate migration port"),
+		}
+		return
+	}
+
+	port = listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	return
+}
+
+func startNbdServer(virt *vm.VirtualMachine, host string) (
+	port int, err error) {
+
+	for i := 0; i < 10; i++ {
+		port, err = getFreePort(host)
+		if err != nil {
+			return
+		}
+
+		err = qms.NbdServerStart(virt.Id, host, port, migrateTlsId)
+		if err == nil {
+			return
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return
+}
+
+func startMigrateIncoming(virt *vm.VirtualMachine, host string) (
+	port int, err error) {
+
+	for i := 0; i < 10; i++ {
+		port, err = getFreePort(host)
+		if err != nil {
+			return
+		}
+
+		err = qms.MigrateIncoming(virt.Id,
+			fmt.Sprintf("tcp:%s:%d", host, port), migrateTlsId)
+		if err == nil {
+			return
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return
+}
+
+func getMirrorDevices(virt *vm.VirtualMachine) (devices []string) {
+	devices = []string{}
+
+	for _, dsk := range virt.Disks {
+		devices = append(devices, fmt.Sprintf("virtio%d", dsk.Index))
+	}
+
+	return
+}
+
+func waitMirror(virt *vm.VirtualMachine, devices []string,
+	start time.Time, timeout time.Duration) (err error) {
+
+	for {
+		jobs, e := qms.GetBlockJobs(virt.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		ready := 0
+		for _, device := range devices {
+			found := false
+
+			for _, job := range jobs {
+				if job.Device != device {
+					continue
+				}
+
+				found = true
+				if job.Ready {
+					ready += 1
+				}
+				break
+			}
+
+			if !found {
+				err = &errortypes.ExecError{
+					errors.Newf("qemu: Disk mirror %s failed", device),
+				}
+				return
+			}
+		}
+
+		if ready == len(devices) {
+			return
+		}
+
+		if time.Since(start) > timeout {
+			err = &errortypes.TimeoutError{
+				errors.New("qemu: Disk mirror timeout"),
+			}
+			return
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func cancelMirror(virt *vm.VirtualMachine, devices []string,
+	force bool) (err error) {
+
+	for _, device := range devices {
+		err = qms.BlockJobCancel(virt.Id, device, force)
+		if err != nil {
+			return
+		}
+	}
+
+	for i := 0; i < 60; i++ {
+		jobs, e := qms.GetBlockJobs(virt.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if len(jobs) == 0 {
+			return
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+
+	err = &errortypes.TimeoutError{
+		errors.New("qemu: Disk mirror cancel timeout"),
+	}
+	return
+}
+
+func removeFiles(virt *vm.VirtualMachine) (err error) {
+	pths := []string{
+		paths.GetVmPath(virt.Id),
+		paths.GetUnitPath(virt.Id),
+		paths.GetSockPath(virt.Id),
+		paths.GetQmpSockPath(virt.Id),
+		paths.GetGuestPath(virt.Id),
+		paths.GetSerialPath(virt.Id),
+		paths.GetVncPath(virt.Id),
+		paths.GetPidPath(virt.Id),
+		paths.GetInitPath(virt.Id),
+		paths.GetTlsPath(virt.Id),
+	}
+
+	for _, pth := range pths {
+		err = utils.RemoveAll(pth)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func MigratePrepare(virt *vm.VirtualMachine) (
+	disks []*instance.MigrateDisk, err error) {
+
+	disks = []*instance.MigrateDisk{}
+
+	for _, dsk := range virt.Disks {
+		size, e := data.GetDiskSize(dsk.Path)
+		if e != nil {
+			err = e
+			return
+		}
+
+		disks = append(disks, &instance.MigrateDisk{
+			Id:    dsk.GetId(),
+			Index: dsk.Index,
+			Size:  size,
+		})
+	}
+
+	return
+}
+
+func MigrateIncoming(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine) (addr, nbdAddr string,
+	clientTls *instance.MigrateTls, err error) {
+
+	vmPath := paths.GetVmPath(virt.Id)
+	unitName := paths.GetUnitName(virt.Id)
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Info("qemu: Preparing virtual machine migration target")
+
+	if len(node.Self.PrivateIps) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("qemu: Node missing internal address for migration"),
+		}
+		return
+	}
+
+	host := node.Self.PrivateIps[0]
+
+	err = utils.ExistsMkdir(settings.Hypervisor.LibPath, 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(vmPath, 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(paths.GetDisksPath(), 0755)
+	if err != nil {
+		return
+	}
+
+	virt.Disks = []*vm.Disk{}
+	for _, dsk := range inst.MigrateDisks {
+		diskPath := paths.GetDiskPath(dsk.Id)
+
+		exists, e := utils.Exists(diskPath)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if exists {
+			err = &errortypes.WriteError{
+				errors.New("qemu: Migration disk already exists"),
+			}
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(nil, "qemu-img", "create",
+			"-f", "qcow2", diskPath, strconv.FormatInt(dsk.Size, 10))
+		if err != nil {
+			return
+		}
+
+		virt.Disks = append(virt.Disks, &vm.Disk{
+			Index: dsk.Index,
+			Path:  diskPath,
+		})
+	}
+
+	serverTls, clientTls, err := generateMigrateTls(host)
+	if err != nil {
+		return
+	}
+
+	err = writeMigrateTls(paths.GetTlsPath(virt.Id), "server", serverTls)
+	if err != nil {
+		return
+	}
+
+	err = cloudinit.Write(db, inst, virt)
+	if err != nil {
+		return
+	}
+
+	err = writeService(virt, true)
+	if err != nil {
+		return
+	}
+
+	err = systemd.Start(unitName)
+	if err != nil {
+		return
+	}
+
+	err = Wait(db, virt)
+	if err != nil {
+		return
+	}
+
+	nbdPort, err := startNbdServer(virt, host)
+	if err != nil {
+		return
+	}
+
+	for _, device := range getMirrorDevices(virt) {
+		err = qms.NbdServerAdd(virt.Id, device)
+		if err != nil {
+			return
+		}
+	}
+
+	port, err := startMigrateIncoming(virt, host)
+	if err != nil {
+		return
+	}
+
+	addr = fmt.Sprintf("tcp:%s:%d", host, port)
+	nbdAddr = net.JoinHostPort(host, strconv.Itoa(nbdPort))
+
+	store.RemVirt(virt.Id)
+	store.RemDisks(virt.Id)
+
+	return
+}
+
+func Migrate(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine) (err error) {
+
+	tlsPath := paths.GetTlsPath(virt.Id)
+
+	logrus.WithFields(logrus.Fields{
+		"id":      virt.Id.Hex(),
+		"address": inst.MigrateAddr,
+	}).Info("qemu: Migrating virtual machine")
+
+	nbdHost, nbdPortStr, err := net.SplitHostPort(inst.MigrateNbdAddr)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qemu: Failed to parse migration nbd address"),
+		}
+		return
+	}
+
+	nbdPort, err := strconv.Atoi(nbdPortStr)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qemu: Failed to parse migration nbd port"),
+		}
+		return
+	}
+
+	err = writeMigrateTls(tlsPath, "client", inst.MigrateTls)
+	if err != nil {
+		return
+	}
+	defer utils.RemoveAll(tlsPath)
+
+	err = qms.AddTlsCreds(virt.Id, migrateTlsId, tlsPath, "client")
+	if err != nil {
+		return
+	}
+	defer qms.RemoveTlsCreds(virt.Id, migrateTlsId)
+
+	timeout := time.Duration(settings.Hypervisor.MigrateTimeout) * time.Second
+	start := time.Now()
+
+	devices := []string{}
+	for _, device := range getMirrorDevices(virt) {
+		err = qms.DriveMirror(virt.Id, device, nbdHost, nbdPort,
+			migrateTlsId)
+		if err != nil {
+			cancelMirror(virt, devices, true)
+			return
+		}
+		devices = append(devices, device)
+	}
+
+	err = waitMirror(virt, devices, start, timeout)
+	if err != nil {
+		cancelMirror(virt, devices, true)
+		return
+	}
+
+	err = qms.Migrate(virt.Id, inst.MigrateAddr, migrateTlsId)
+	if err != nil {
+		cancelMirror(virt, devices, true)
+		return
+	}
+
+	for {
+		time.Sleep(2 * time.Second)
+
+		status, e := qms.GetMigrateStatus(virt.Id)
+		if e != nil {
+			err = e
+			qms.MigrateCancel(virt.Id)
+			cancelMirror(virt, devices, true)
+			return
+		}
+
+		switch status {
+		case "completed":
+			err = cancelMirror(virt, devices, false)
+			if err != nil {
+				cancelMirror(virt, devices, true)
+				qms.Cont(virt.Id)
+			}
+			return
+		case "failed", "cancelled":
+			err = &errortypes.ExecError{
+				errors.Newf("qemu: Migration %s", status),
+			}
+			cancelMirror(virt, devices, true)
+			return
+		}
+
+		if time.Since(start) > timeout {
+			qms.MigrateCancel(virt.Id)
+			cancelMirror(virt, devices, true)
+
+			err = &errortypes.TimeoutError{
+				errors.New("qemu: Migration timeout"),
+			}
+			return
+		}
+	}
+}
+
+func MigrateCleanup(db *database.Database, virt *vm.VirtualMachine) (
+	err error) {
+
+	unitName := paths.GetUnitName(virt.Id)
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Info("qemu: Removing migrated virtual machine")
+
+	err = systemd.Stop(unitName)
+	if err != nil {
+		return
+	}
+
+	err = NetworkConfClear(db, virt)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range virt.Disks {
+		err = utils.RemoveAll(dsk.Path)
+		if err != nil {
+			return
+		}
+	}
+
+	err = removeFiles(virt)
+	if err != nil {
+		return
+	}
+
+	store.RemVirt(virt.Id)
+	store.RemDisks(virt.Id)
+	store.RemAddress(virt.Id)
+	store.RemRoutes(virt.Id)
+	store.RemPeers(virt.Id)
+
+	return
+}
+
+func MigrateFinish(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Info("qemu: Completing virtual machine migration")
+
+	virt.Disks = []*vm.Disk{}
+	for _, dsk := range inst.MigrateDisks {
+		virt.Disks = append(virt.Disks, &vm.Disk{
+			Index: dsk.Index,
+			Path:  paths.GetDiskPath(dsk.Id),
+		})
+	}
+
+	err = qms.NbdServerStop(virt.Id)
+	if err != nil {
+		return
+	}
+
+	err = writeService(virt, false)
+	if err != nil {
+		return
+	}
+
+	err = NetworkConf(db, virt)
+	if err != nil {
+		return
+	}
+
+	err = qms.Cont(virt.Id)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetTlsPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	store.RemVirt(virt.Id)
+	store.RemDisks(virt.Id)
+
+	return
+}
+
+func MigrateAbort(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine) (err error) {
+
+	unitName := paths.GetUnitName(virt.Id)
+	unitPath := paths.GetUnitPath(virt.Id)
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Warning("qemu: Aborting virtual machine migration")
+
+	exists, err := utils.Exists(unitPath)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		err = systemd.Stop(unitName)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, dsk := range inst.MigrateDisks {
+		err = utils.RemoveAll(paths.GetDiskPath(dsk.Id))
+		if err != nil {
+			return
+		}
+	}
+
+	err = removeFiles(virt)
+	if err != nil {
+		return
+	}
+
+	store.RemVirt(virt.Id)
+	store.RemDisks(virt.Id)
+
+	return
+}
@@ -0,0 +1,263 @@
+package qemu
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"net"
+	"strconv"
+)
+
+func networkConfSecondary(db *database.Database, virt *vm.VirtualMachine,
+	n int) (addr, addr6 net.IP, err error) {
+
+	adapter := virt.NetworkAdapters[n]
+	iface := vm.GetIface(virt.Id, n)
+	ifaceInternalVirt := vm.GetIfaceVirt(virt.Id, n*2+1)
+	ifaceInternal := vm.GetIfaceInternal(virt.Id, n)
+	ifaceVlan := vm.GetIfaceVlan(virt.Id, n)
+	namespace := vm.GetNamespace(virt.Id, n)
+
+	internalIface := node.Self.InternalInterface
+	if internalIface == "" {
+		internalIface = node.Self.ExternalInterface
+	}
+	if internalIface == "" {
+		internalIface = settings.Local.BridgeName
+	}
+
+	vc, err := vpc.Get(db, adapter.VpcId)
+	if err != nil {
+		return
+	}
+
+	addr, err = vc.GetIp(db, vpc.Instance, virt.Id)
+	if err != nil {
+		return
+	}
+
+	addr6 = vc.GetIp6(addr)
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns",
+		"add", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	utils.ExecCombinedOutput("", "ip", "link",
+		"set", ifaceInternalVirt, "down")
+	utils.ExecCombinedOutput("", "ip", "link", "del", ifaceInternalVirt)
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"add", ifaceInternalVirt,
+		"type", "veth",
+		"peer", "name", ifaceInternal,
+		"addr", vm.GetMacAddrInternal(virt.Id, vc.Id),
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"set", "dev", ifaceInternalVirt, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"already a member of a bridge"},
+		"brctl", "addif", internalIface, ifaceInternalVirt)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "link",
+		"set", "dev", ifaceInternal,
+		"netns", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "link",
+		"set", "dev", iface,
+		"netns", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"sysctl", "-w", "net.ipv6.conf.all.accept_ra=0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"sysctl", "-w", "net.ipv6.conf.default.accept_ra=0",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, name := range []string{"lo", ifaceInternal, iface} {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", name, "up",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"add", "link", ifaceInternal,
+		"name", ifaceVlan,
+		"type", "vlan",
+		"id", strconv.Itoa(vc.VpcId),
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", ifaceVlan, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"already exists"},
+		"ip", "netns", "exec", namespace,
+		"brctl", "addbr", "br0",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, name := range []string{ifaceVlan, iface} {
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"already a member of a bridge"},
+			"ip", "netns", "exec", namespace,
+			"brctl", "addif", "br0", name,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", "br0", "up",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func networkConfSecondaryClear(virt *vm.VirtualMachine, n int) {
+	ifaceInternalVirt := vm.GetIfaceVirt(virt.Id, n*2+1)
+	namespace := vm.GetNamespace(virt.Id, n)
+
+	utils.ExecCombinedOutput("", "ip", "netns", "del", namespace)
+	utils.ExecCombinedOutput("", "ip", "link",
+		"set", ifaceInternalVirt, "down")
+	utils.ExecCombinedOutput("", "ip", "link", "del", ifaceInternalVirt)
+}
+
+func networkConfFloating(virt *vm.VirtualMachine, namespace,
+	ifaceExternal string) (err error) {
+
+	fip := virt.FloatingIp
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "addr",
+		"add", fip.Address,
+		"dev", ifaceExternal,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "route",
+		"add", "default",
+		"via", fip.Gateway,
+		"dev", ifaceExternal,
+	)
+	if err != nil {
+		return
+	}
+
+	if fip.Address6 != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"File exists"},
+			"ip", "netns", "exec", namespace,
+			"ip", "-6", "addr",
+			"add", fip.Address6,
+			"dev", ifaceExternal,
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"File exists"},
+			"ip", "netns", "exec", namespace,
+			"ip", "-6", "route",
+			"add", "default",
+			"via", fip.Gateway6,
+			"dev", ifaceExternal,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id":   virt.Id.Hex(),
+		"net_namespace": namespace,
+		"address":       fip.Address,
+		"address6":      fip.Address6,
+	}).Info("qemu: Configured instance floating IP")
+
+	return
+}
@@ -0,0 +1,127 @@
+package qemu
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"gopkg.in/mgo.v2/bson"
+	"strconv"
+	"strings"
+)
+
+func getPeerIfaces(namespace string) (ifaces set.Set, err error) {
+	ifaces = set.NewSet()
+
+	output, err := utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "-o", "link", "show",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		name := strings.Split(strings.TrimSuffix(fields[1], ":"), "@")[0]
+		if strings.HasPrefix(name, "peer") {
+			ifaces.Add(name)
+		}
+	}
+
+	return
+}
+
+func NetworkConfPeers(instId bson.ObjectId, peers []*vpc.Vpc) (err error) {
+	namespace := vm.GetNamespace(instId, 0)
+	ifaceInternal := vm.GetIfaceInternal(instId, 0)
+
+	curIfaces, err := getPeerIfaces(namespace)
+	if err != nil {
+		return
+	}
+
+	newIfaces := set.NewSet()
+	for _, peerVc := range peers {
+		newIfaces.Add(vm.GetIfacePeer(peerVc.VpcId))
+	}
+
+	remIfaces := curIfaces.Copy()
+	remIfaces.Subtract(newIfaces)
+
+	for ifaceInf := range remIfaces.Iter() {
+		utils.ExecCombinedOutputLogged(
+			[]string{"Cannot find device"},
+			"ip", "netns", "exec", namespace,
+			"ip", "link", "del", ifaceInf.(string),
+		)
+	}
+
+	for _, peerVc := range peers {
+		ifacePeer := vm.GetIfacePeer(peerVc.VpcId)
+
+		network, e := peerVc.GetNetwork()
+		if e != nil {
+			err = e
+			return
+		}
+
+		network6, e := peerVc.GetNetwork6()
+		if e != nil {
+			err = e
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"File exists"},
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"add", "link", ifaceInternal,
+			"name", ifacePeer,
+			"type", "vlan",
+			"id", strconv.Itoa(peerVc.VpcId),
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", ifacePeer, "up",
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "route",
+			"replace", network.String(),
+			"dev", ifacePeer,
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "-6", "route",
+			"replace", network6.String(),
+			"dev", ifacePeer,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
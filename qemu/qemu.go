@@ -32,14 +32,25 @@ type Qemu struct {
 	Threads  int
 	Boot     string
 	Memory   int
+	Vnc      bool
 	Disks    []*Disk
 	Networks []*Network
+	Incoming bool
 }
 
 func (q *Qemu) Marshal() (output string, err error) {
 	cmd := []string{
 		"/usr/bin/qemu-system-x86_64",
-		"-nographic",
+	}
+
+	if q.Vnc {
+		cmd = append(cmd, "-vnc")
+		cmd = append(cmd, fmt.Sprintf(
+			"unix:%s",
+			paths.GetVncPath(q.Id),
+		))
+	} else {
+		cmd = append(cmd, "-nographic")
 	}
 
 	if q.Kvm {
@@ -121,6 +132,18 @@ func (q *Qemu) Marshal() (output string, err error) {
 		paths.GetSockPath(q.Id),
 	))
 
+	cmd = append(cmd, "-qmp")
+	cmd = append(cmd, fmt.Sprintf(
+		"unix:%s,server,nowait",
+		paths.GetQmpSockPath(q.Id),
+	))
+
+	cmd = append(cmd, "-serial")
+	cmd = append(cmd, fmt.Sprintf(
+		"unix:%s,server,nowait",
+		paths.GetSerialPath(q.Id),
+	))
+
 	cmd = append(cmd, "-pidfile")
 	cmd = append(cmd, paths.GetPidPath(q.Id))
 
@@ -134,8 +157,17 @@ func (q *Qemu) Marshal() (output string, err error) {
 	cmd = append(cmd,
 		"virtserialport,chardev=guest,name=org.qemu.guest_agent.0")
 
-	//cmd = append(cmd, "-vnc")
-	//cmd = append(cmd, ":1")
+	if q.Incoming {
+		cmd = append(cmd, "-object")
+		cmd = append(cmd, fmt.Sprintf(
+			"tls-creds-x509,id=%s,dir=%s,endpoint=server",
+			migrateTlsId,
+			paths.GetTlsPath(q.Id),
+		))
+		cmd = append(cmd, "-S")
+		cmd = append(cmd, "-incoming")
+		cmd = append(cmd, "defer")
+	}
 
 	output = fmt.Sprintf(
 		systemdTemplate,
@@ -0,0 +1,189 @@
+package qemu
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"math/big"
+	"net"
+	"path"
+	"time"
+)
+
+type tlsCert struct {
+	Cert    *x509.Certificate
+	Key     *ecdsa.PrivateKey
+	CertPem string
+	KeyPem  string
+}
+
+func newTlsCert(templ *x509.Certificate, parent *tlsCert) (
+	crt *tlsCert, err error) {
+
+	key, err := ecdsa.GenerateKey(
+		elliptic.P384(),
+		rand.Reader,
+	)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to generate private key"),
+		}
+		return
+	}
+
+	serialLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serial, err := rand.Int(rand.Reader, serialLimit)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to generate certificate serial"),
+		}
+		return
+	}
+
+	templ.SerialNumber = serial
+	templ.Subject = pkix.Name{
+		Organization: []string{"Pritunl Cloud"},
+	}
+	templ.NotBefore = time.Now().Add(-1 * time.Hour)
+	templ.NotAfter = time.Now().Add(48 * time.Hour)
+	templ.BasicConstraintsValid = true
+	templ.SignatureAlgorithm = x509.ECDSAWithSHA256
+
+	parentCert := templ
+	parentKey := key
+	if parent != nil {
+		parentCert = parent.Cert
+		parentKey = parent.Key
+	}
+
+	certByt, err := x509.CreateCertificate(rand.Reader, templ, parentCert,
+		key.Public(), parentKey)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to create certificate"),
+		}
+		return
+	}
+
+	cert, err := x509.ParseCertificate(certByt)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qemu: Failed to parse certificate"),
+		}
+		return
+	}
+
+	keyByt, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qemu: Failed to parse private key"),
+		}
+		return
+	}
+
+	crt = &tlsCert{
+		Cert: cert,
+		Key:  key,
+		CertPem: string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: certByt,
+		})),
+		KeyPem: string(pem.EncodeToMemory(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: keyByt,
+		})),
+	}
+
+	return
+}
+
+func generateMigrateTls(addr string) (server, client *instance.MigrateTls,
+	err error) {
+
+	ca, err := newTlsCert(&x509.Certificate{
+		IsCA:     true,
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	serverCert, err := newTlsCert(&x509.Certificate{
+		KeyUsage: x509.KeyUsageKeyEncipherment |
+			x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses: []net.IP{net.ParseIP(addr)},
+	}, ca)
+	if err != nil {
+		return
+	}
+
+	clientCert, err := newTlsCert(&x509.Certificate{
+		KeyUsage: x509.KeyUsageKeyEncipherment |
+			x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	if err != nil {
+		return
+	}
+
+	server = &instance.MigrateTls{
+		Ca:   ca.CertPem,
+		Cert: serverCert.CertPem,
+		Key:  serverCert.KeyPem,
+	}
+	client = &instance.MigrateTls{
+		Ca:   ca.CertPem,
+		Cert: clientCert.CertPem,
+		Key:  clientCert.KeyPem,
+	}
+
+	return
+}
+
+func writeMigrateTls(dir, endpoint string, tls *instance.MigrateTls) (
+	err error) {
+
+	if tls == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("qemu: Missing migration tls credentials"),
+		}
+		return
+	}
+
+	err = utils.RemoveAll(dir)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(dir, 0700)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(path.Join(dir, "ca-cert.pem"), tls.Ca, 0600)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(path.Join(dir, endpoint+"-cert.pem"),
+		tls.Cert, 0600)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(path.Join(dir, endpoint+"-key.pem"),
+		tls.Key, 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -28,6 +28,7 @@ func NewQemu(virt *vm.VirtualMachine) (qm *Qemu, err error) {
 		Threads:  1,
 		Boot:     "c",
 		Memory:   virt.Memory,
+		Vnc:      virt.Vnc,
 		Disks:    []*Disk{},
 		Networks: []*Network{},
 	}
@@ -0,0 +1,364 @@
+package qms
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"gopkg.in/mgo.v2/bson"
+	"net"
+	"strconv"
+	"time"
+)
+
+type qmpCommand struct {
+	Execute   string      `json:"execute"`
+	Arguments interface{} `json:"arguments,omitempty"`
+}
+
+type qmpError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+type qmpResponse struct {
+	Event  string          `json:"event"`
+	Return json.RawMessage `json:"return"`
+	Error  *qmpError       `json:"error"`
+}
+
+type BlockJob struct {
+	Device string `json:"device"`
+	Type   string `json:"type"`
+	Len    int64  `json:"len"`
+	Offset int64  `json:"offset"`
+	Ready  bool   `json:"ready"`
+}
+
+func qmpRead(reader *bufio.Reader) (resp *qmpResponse, err error) {
+	for {
+		line, e := reader.ReadBytes('\n')
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "qemu: Failed to read qmp socket"),
+			}
+			return
+		}
+
+		resp = &qmpResponse{}
+		err = json.Unmarshal(line, resp)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "qemu: Failed to parse qmp response"),
+			}
+			return
+		}
+
+		if resp.Event != "" {
+			continue
+		}
+
+		if resp.Error != nil {
+			err = &errortypes.ExecError{
+				errors.Newf("qemu: Qmp command failed: %s",
+					resp.Error.Desc),
+			}
+			return
+		}
+
+		return
+	}
+}
+
+func qmpRun(vmId bson.ObjectId, cmd *qmpCommand, ret interface{}) (
+	err error) {
+
+	sockPath := GetQmpSockPath(vmId)
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := net.DialTimeout(
+		"unix",
+		sockPath,
+		1*time.Second,
+	)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to open qmp socket"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed set deadline"),
+		}
+		return
+	}
+
+	reader := bufio.NewReader(conn)
+
+	_, err = reader.ReadBytes('\n')
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to read qmp socket"),
+		}
+		return
+	}
+
+	for _, c := range []*qmpCommand{
+		&qmpCommand{
+			Execute: "qmp_capabilities",
+		},
+		cmd,
+	} {
+		data, e := json.Marshal(c)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "qemu: Failed to marshal qmp command"),
+			}
+			return
+		}
+
+		_, err = conn.Write(append(data, '\n'))
+		if err != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "qemu: Failed to write qmp socket"),
+			}
+			return
+		}
+
+		resp, e := qmpRead(reader)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if c == cmd && ret != nil {
+			err = json.Unmarshal(resp.Return, ret)
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(err, "qemu: Failed to parse qmp response"),
+				}
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func AddTlsCreds(vmId bson.ObjectId, id, dir, endpoint string) (err error) {
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "object-add",
+		Arguments: map[string]interface{}{
+			"qom-type": "tls-creds-x509",
+			"id":       id,
+			"dir":      dir,
+			"endpoint": endpoint,
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveTlsCreds(vmId bson.ObjectId, id string) (err error) {
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "object-del",
+		Arguments: map[string]interface{}{
+			"id": id,
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func NbdServerStart(vmId bson.ObjectId, host string, port int,
+	tlsCreds string) (err error) {
+
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "nbd-server-start",
+		Arguments: map[string]interface{}{
+			"addr": map[string]interface{}{
+				"type": "inet",
+				"data": map[string]interface{}{
+					"host": host,
+					"port": strconv.Itoa(port),
+				},
+			},
+			"tls-creds": tlsCreds,
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func NbdServerAdd(vmId bson.ObjectId, device string) (err error) {
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "nbd-server-add",
+		Arguments: map[string]interface{}{
+			"device":   device,
+			"writable": true,
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func NbdServerStop(vmId bson.ObjectId) (err error) {
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "nbd-server-stop",
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func DriveMirror(vmId bson.ObjectId, device, host string, port int,
+	tlsCreds string) (err error) {
+
+	target, err := json.Marshal(map[string]interface{}{
+		"driver": "nbd",
+		"server": map[string]interface{}{
+			"type": "inet",
+			"host": host,
+			"port": strconv.Itoa(port),
+		},
+		"export":    device,
+		"tls-creds": tlsCreds,
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qemu: Failed to marshal mirror target"),
+		}
+		return
+	}
+
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "drive-mirror",
+		Arguments: map[string]interface{}{
+			"device": device,
+			"target": "json:" + string(target),
+			"format": "raw",
+			"mode":   "existing",
+			"sync":   "full",
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetBlockJobs(vmId bson.ObjectId) (jobs []*BlockJob, err error) {
+	jobs = []*BlockJob{}
+
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "query-block-jobs",
+	}, &jobs)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func BlockJobCancel(vmId bson.ObjectId, device string, force bool) (
+	err error) {
+
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "block-job-cancel",
+		Arguments: map[string]interface{}{
+			"device": device,
+			"force":  force,
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func MigrateIncoming(vmId bson.ObjectId, uri, tlsCreds string) (err error) {
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "migrate-set-parameters",
+		Arguments: map[string]interface{}{
+			"tls-creds": tlsCreds,
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "migrate-incoming",
+		Arguments: map[string]interface{}{
+			"uri": uri,
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Migrate(vmId bson.ObjectId, uri, tlsCreds string) (err error) {
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"uri":         uri,
+	}).Info("qemu: Starting virtual machine migration")
+
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "migrate-set-parameters",
+		Arguments: map[string]interface{}{
+			"tls-creds": tlsCreds,
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "migrate",
+		Arguments: map[string]interface{}{
+			"uri": uri,
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Cont(vmId bson.ObjectId) (err error) {
+	err = qmpRun(vmId, &qmpCommand{
+		Execute: "cont",
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
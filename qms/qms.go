@@ -221,6 +221,100 @@ func RemoveDisk(vmId bson.ObjectId, dsk *vm.Disk) (err error) {
 	return
 }
 
+func ResizeDisk(vmId bson.ObjectId, dsk *vm.Disk, size int) (err error) {
+	sockPath := GetSockPath(vmId)
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"disk_path":   dsk.Path,
+		"size":        size,
+	}).Info("qemu: Resizing virtual machine disk")
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := net.DialTimeout(
+		"unix",
+		sockPath,
+		1*time.Second,
+	)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to open socket"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed set deadline"),
+		}
+		return
+	}
+
+	buffer := []byte{}
+	for {
+		buf := make([]byte, 10000)
+		n, e := conn.Read(buf)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "qemu: Failed to read socket"),
+			}
+			return
+		}
+		buffer = append(buffer, buf[:n]...)
+
+		if bytes.Contains(bytes.TrimSpace(buffer), []byte("(qemu)")) {
+			break
+		}
+	}
+
+	_, err = conn.Write([]byte(
+		fmt.Sprintf("block_resize virtio%d %dG\n", dsk.Index, size)))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to write socket"),
+		}
+		return
+	}
+
+	buffer = []byte{}
+	for {
+		buf := make([]byte, 10000)
+		n, e := conn.Read(buf)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "qemu: Failed to read socket"),
+			}
+			return
+		}
+		buffer = append(buffer, buf[:n]...)
+
+		if bytes.Contains(bytes.TrimSpace(buffer), []byte("(qemu)")) {
+			break
+		}
+	}
+
+	// Monitor only outputs the command echo and prompt on success
+	for _, line := range strings.Split(string(buffer), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.Contains(line, "block_resize") ||
+			strings.HasPrefix(line, "(qemu)") {
+
+			continue
+		}
+
+		err = &errortypes.ExecError{
+			errors.Newf("qemu: Failed to resize disk: %s", line),
+		}
+		return
+	}
+
+	return
+}
+
 func Shutdown(vmId bson.ObjectId) (err error) {
 	sockPath := GetSockPath(vmId)
 
@@ -258,3 +352,124 @@ func Shutdown(vmId bson.ObjectId) (err error) {
 
 	return
 }
+
+func MigrateCancel(vmId bson.ObjectId) (err error) {
+	sockPath := GetSockPath(vmId)
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := net.DialTimeout(
+		"unix",
+		sockPath,
+		1*time.Second,
+	)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to open socket"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(1 * time.Second))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed set deadline"),
+		}
+		return
+	}
+
+	_, err = conn.Write([]byte("migrate_cancel\n"))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to write socket"),
+		}
+		return
+	}
+
+	return
+}
+
+func GetMigrateStatus(vmId bson.ObjectId) (status string, err error) {
+	sockPath := GetSockPath(vmId)
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := net.DialTimeout(
+		"unix",
+		sockPath,
+		1*time.Second,
+	)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to open socket"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed set deadline"),
+		}
+		return
+	}
+
+	buffer := make([]byte, 100000)
+	for {
+		buf := make([]byte, 10000)
+		n, e := conn.Read(buf)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "qemu: Failed to read socket"),
+			}
+			return
+		}
+		buffer = append(buffer, buf[:n]...)
+
+		if bytes.Contains(bytes.TrimSpace(buffer), []byte("(qemu)")) {
+			break
+		}
+	}
+
+	_, err = conn.Write([]byte("info migrate\n"))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to write socket"),
+		}
+		return
+	}
+
+	buffer = make([]byte, 100000)
+	for {
+		buf := make([]byte, 10000)
+		n, e := conn.Read(buf)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "qemu: Failed to read socket"),
+			}
+			return
+		}
+		buffer = append(buffer, buf[:n]...)
+
+		if bytes.Contains(bytes.TrimSpace(buffer), []byte("(qemu)")) {
+			break
+		}
+	}
+
+	for _, line := range strings.Split(string(buffer), "\n") {
+		index := strings.Index(line, "Migration status:")
+		if index == -1 {
+			continue
+		}
+
+		status = strings.TrimSpace(line[index+17:])
+
+		break
+	}
+
+	return
+}
@@ -0,0 +1,5 @@
+package resolver
+
+const (
+	Suffix = "internal"
+)
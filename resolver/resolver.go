@@ -0,0 +1,215 @@
+package resolver
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func writeChanged(pth string, data []byte) (changed bool, err error) {
+	curData, _ := ioutil.ReadFile(pth)
+	if curData != nil && bytes.Equal(curData, data) {
+		return
+	}
+
+	err = ioutil.WriteFile(pth, data, 0644)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "resolver: Failed to write resolver conf"),
+		}
+		return
+	}
+
+	changed = true
+
+	return
+}
+
+func getHosts(db *database.Database, vc *vpc.Vpc) (
+	hosts []byte, err error) {
+
+	buf := &bytes.Buffer{}
+
+	domain := GetDomain(vc.Name)
+	if domain == "" {
+		hosts = buf.Bytes()
+		return
+	}
+
+	insts, err := instance.GetAll(db, &bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"vpc": vc.Id,
+			},
+			&bson.M{
+				"secondary_vpcs": vc.Id,
+			},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	for _, inst := range insts {
+		label := FormatLabel(inst.Name)
+		if label == "" {
+			continue
+		}
+
+		name := fmt.Sprintf("%s.%s", label, domain)
+		privateIps, privateIps6 := inst.GetPrivateIps(vc.Id)
+
+		for _, addr := range privateIps {
+			fmt.Fprintf(buf, "%s %s\n", addr, name)
+		}
+		for _, addr := range privateIps6 {
+			fmt.Fprintf(buf, "%s %s\n", addr, name)
+		}
+	}
+
+	hosts = buf.Bytes()
+
+	return
+}
+
+func getConf(vc *vpc.Vpc) []byte {
+	buf := &bytes.Buffer{}
+
+	buf.WriteString("no-resolv\n")
+	buf.WriteString("no-hosts\n")
+	buf.WriteString("domain-needed\n")
+	buf.WriteString("bind-interfaces\n")
+	buf.WriteString("interface=br0\n")
+	buf.WriteString("except-interface=lo\n")
+	fmt.Fprintf(buf, "addn-hosts=%s\n", getHostsPath(vc.Id))
+
+	domain := GetDomain(vc.Name)
+	if domain != "" {
+		fmt.Fprintf(buf, "local=/%s/\n", domain)
+	}
+
+	servers := vc.DnsServers
+	if len(servers) == 0 {
+		servers = vpc.DefaultDnsServers
+	}
+	for _, server := range servers {
+		fmt.Fprintf(buf, "server=%s\n", server)
+	}
+
+	return buf.Bytes()
+}
+
+func Deploy(db *database.Database, vc *vpc.Vpc) (err error) {
+	namespace := vm.GetLinkNamespace(vc.Id, 0)
+
+	err = utils.ExistsMkdir(filepath.Join("/etc/netns", namespace), 0755)
+	if err != nil {
+		return
+	}
+
+	hosts, err := getHosts(db, vc)
+	if err != nil {
+		return
+	}
+
+	confChanged, err := writeChanged(getConfPath(vc.Id), getConf(vc))
+	if err != nil {
+		return
+	}
+
+	hostsChanged, err := writeChanged(getHostsPath(vc.Id), hosts)
+	if err != nil {
+		return
+	}
+
+	pid := getPid(namespace)
+	if pid != 0 && confChanged {
+		err = stopPid(pid)
+		if err != nil {
+			return
+		}
+		pid = 0
+	}
+
+	if pid == 0 {
+		logrus.WithFields(logrus.Fields{
+			"vpc_id": vc.Id.Hex(),
+			"domain": GetDomain(vc.Name),
+		}).Info("resolver: Starting VPC DNS resolver")
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"dnsmasq",
+			"--conf-file="+getConfPath(vc.Id),
+			"--pid-file="+getPidPath(namespace),
+		)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	if hostsChanged {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"kill", "-HUP", strconv.Itoa(pid),
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func Clean(curNamespaces set.Set) (err error) {
+	items, err := ioutil.ReadDir("/var/run")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "resolver: Failed to read run directory"),
+		}
+		return
+	}
+
+	for _, item := range items {
+		name := item.Name()
+
+		if item.IsDir() || !strings.HasPrefix(name, "dnsmasq-x") ||
+			!strings.HasSuffix(name, ".pid") {
+
+			continue
+		}
+
+		namespace := strings.TrimSuffix(
+			strings.TrimPrefix(name, "dnsmasq-"), ".pid")
+
+		if curNamespaces.Contains(namespace) {
+			continue
+		}
+
+		pid := getPid(namespace)
+		if pid != 0 {
+			utils.ExecCombinedOutput("", "kill", "-9", strconv.Itoa(pid))
+		}
+
+		os.Remove(filepath.Join("/var/run", name))
+	}
+
+	return
+}
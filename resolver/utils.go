@@ -0,0 +1,91 @@
+package resolver
+
+import (
+	"fmt"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"gopkg.in/mgo.v2/bson"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	labelReg = regexp.MustCompile("[^a-z0-9-]+")
+)
+
+func FormatLabel(name string) string {
+	label := labelReg.ReplaceAllString(strings.ToLower(name), "-")
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	return strings.Trim(label, "-")
+}
+
+func GetDomain(vpcName string) string {
+	label := FormatLabel(vpcName)
+	if label == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s", label, Suffix)
+}
+
+func getConfPath(vpcId bson.ObjectId) string {
+	namespace := vm.GetLinkNamespace(vpcId, 0)
+	return path.Join("/", "etc", "netns", namespace, "pritunl-dns.conf")
+}
+
+func getHostsPath(vpcId bson.ObjectId) string {
+	namespace := vm.GetLinkNamespace(vpcId, 0)
+	return path.Join("/", "etc", "netns", namespace, "pritunl-dns.hosts")
+}
+
+func getPidPath(namespace string) string {
+	return fmt.Sprintf("/var/run/dnsmasq-%s.pid", namespace)
+}
+
+func getPid(namespace string) (pid int) {
+	pidData, _ := ioutil.ReadFile(getPidPath(namespace))
+	if pidData == nil {
+		return
+	}
+
+	pid, _ = strconv.Atoi(strings.TrimSpace(string(pidData)))
+	if pid == 0 {
+		return
+	}
+
+	exists, _ := utils.Exists(fmt.Sprintf("/proc/%d/status", pid))
+	if !exists {
+		pid = 0
+	}
+
+	return
+}
+
+func stopPid(pid int) (err error) {
+	utils.ExecCombinedOutput("", "kill", strconv.Itoa(pid))
+
+	for i := 0; i < 50; i++ {
+		exists, _ := utils.Exists(fmt.Sprintf("/proc/%d/status", pid))
+		if !exists {
+			return
+		}
+
+		if i == 30 {
+			utils.ExecCombinedOutput("", "kill", "-9", strconv.Itoa(pid))
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	err = &errortypes.ExecError{
+		errors.New("resolver: Timeout waiting for resolver to stop"),
+	}
+	return
+}
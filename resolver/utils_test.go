@@ -0,0 +1,50 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatLabel(t *testing.T) {
+	tests := []struct {
+		name  string
+		label string
+	}{
+		{"", ""},
+		{"web", "web"},
+		{"Web Server", "web-server"},
+		{"db_01.prod", "db-01-prod"},
+		{"--edge--", "edge"},
+		{"a  &  b", "a-b"},
+		{"!!!", ""},
+		{strings.Repeat("a", 70), strings.Repeat("a", 63)},
+		{strings.Repeat("a", 62) + " b", strings.Repeat("a", 62)},
+	}
+
+	for _, test := range tests {
+		label := FormatLabel(test.name)
+		if label != test.label {
+			t.Errorf("FormatLabel(%q) = %q, want %q",
+				test.name, label, test.label)
+		}
+	}
+}
+
+func TestGetDomain(t *testing.T) {
+	tests := []struct {
+		vpcName string
+		domain  string
+	}{
+		{"", ""},
+		{"***", ""},
+		{"Production VPC", "production-vpc.internal"},
+	}
+
+	for _, test := range tests {
+		domain := GetDomain(test.vpcName)
+		if domain != test.domain {
+			t.Errorf("GetDomain(%q) = %q, want %q",
+				test.vpcName, domain, test.domain)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/acme"
 	"github.com/pritunl/pritunl-cloud/ahandlers"
 	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/console"
 	"github.com/pritunl/pritunl-cloud/constants"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
@@ -47,6 +48,11 @@ type Router struct {
 }
 
 func (r *Router) ServeHTTP(w http.ResponseWriter, re *http.Request) {
+	if strings.HasPrefix(re.URL.Path, "/console/") {
+		console.ServeHTTP(w, re)
+		return
+	}
+
 	hst := utils.StripPort(re.Host)
 	if r.adminType && !r.userType {
 		r.aRouter.ServeHTTP(w, re)
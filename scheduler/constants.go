@@ -0,0 +1,6 @@
+package scheduler
+
+const (
+	Spread = "spread"
+	Pack   = "pack"
+)
@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type reservation struct {
+	Id         bson.ObjectId `bson:"_id"`
+	Processors int           `bson:"processors"`
+	Memory     int           `bson:"memory"`
+}
+
+func GetCapacity(zne *zone.Zone, nde *node.Node) (
+	cpuUnits int, memoryUnits float64) {
+
+	cpuRatio := 1.0
+	memoryRatio := 1.0
+
+	if zne != nil {
+		if zne.CpuOvercommit != 0 {
+			cpuRatio = zne.CpuOvercommit
+		}
+		if zne.MemoryOvercommit != 0 {
+			memoryRatio = zne.MemoryOvercommit
+		}
+	}
+
+	if nde.CpuOvercommit != 0 {
+		cpuRatio = nde.CpuOvercommit
+	}
+	if nde.MemoryOvercommit != 0 {
+		memoryRatio = nde.MemoryOvercommit
+	}
+
+	cpuUnits = int(float64(nde.CpuUnits) * cpuRatio)
+	memoryUnits = nde.MemoryUnits * memoryRatio
+
+	return
+}
+
+func getReserved(db *database.Database, nodeIds []bson.ObjectId,
+	excludeId bson.ObjectId) (cpuUnits map[bson.ObjectId]int,
+	memoryUnits map[bson.ObjectId]float64, err error) {
+
+	coll := db.Instances()
+	cpuUnits = map[bson.ObjectId]int{}
+	memoryUnits = map[bson.ObjectId]float64{}
+
+	query := bson.M{
+		"node": &bson.M{
+			"$in": nodeIds,
+		},
+		"state": &bson.M{
+			"$ne": instance.Stop,
+		},
+	}
+	if excludeId != "" {
+		query["_id"] = &bson.M{
+			"$ne": excludeId,
+		}
+	}
+
+	cursor := coll.Pipe([]*bson.M{
+		&bson.M{
+			"$match": query,
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": "$node",
+				"processors": &bson.M{
+					"$sum": "$processors",
+				},
+				"memory": &bson.M{
+					"$sum": "$memory",
+				},
+			},
+		},
+	}).Iter()
+
+	res := &reservation{}
+	for cursor.Next(res) {
+		cpuUnits[res.Id] = res.Processors
+		memoryUnits[res.Id] = float64(res.Memory) / float64(1024)
+		res = &reservation{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func CheckNode(db *database.Database, nde *node.Node,
+	instId bson.ObjectId, processors, memory int) (
+	errData *errortypes.ErrorData, err error) {
+
+	nde.SetActive()
+	if nde.CpuUnits == 0 || nde.MemoryUnits == 0 {
+		return
+	}
+
+	var zne *zone.Zone
+	if nde.Zone != "" {
+		zne, err = zone.Get(db, nde.Zone)
+		if err != nil {
+			return
+		}
+	}
+
+	cpuUnitsRes, memoryUnitsRes, err := getReserved(
+		db, []bson.ObjectId{nde.Id}, instId)
+	if err != nil {
+		return
+	}
+
+	cpuUnits, memoryUnits := GetCapacity(zne, nde)
+
+	if cpuUnits != 0 && cpuUnitsRes[nde.Id]+processors > cpuUnits {
+		errData = &errortypes.ErrorData{
+			Error:   "node_cpu_unavailable",
+			Message: "Node processor capacity exceeded",
+		}
+		return
+	}
+
+	if memoryUnits != 0 &&
+		memoryUnitsRes[nde.Id]+float64(memory)/float64(1024) > memoryUnits {
+
+		errData = &errortypes.ErrorData{
+			Error:   "node_memory_unavailable",
+			Message: "Node memory capacity exceeded",
+		}
+		return
+	}
+
+	return
+}
+
+func CheckInstance(db *database.Database, inst *instance.Instance) (
+	errData *errortypes.ErrorData, err error) {
+
+	nodeId := inst.Node
+	if inst.State == instance.Migrate {
+		nodeId = inst.MigrateNode
+	} else if !inst.ReservationChanged() {
+		return
+	}
+
+	nde, err := node.Get(db, nodeId)
+	if err != nil {
+		return
+	}
+
+	errData, err = CheckNode(db, nde, inst.Id, inst.Processors, inst.Memory)
+	if err != nil {
+		return
+	}
+
+	return
+}
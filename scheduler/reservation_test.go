@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"testing"
+)
+
+func TestGetCapacity(t *testing.T) {
+	tests := []struct {
+		name        string
+		zone        *zone.Zone
+		node        *node.Node
+		cpuUnits    int
+		memoryUnits float64
+	}{
+		{
+			name: "no_zone",
+			node: &node.Node{
+				CpuUnits:    8,
+				MemoryUnits: 32,
+			},
+			cpuUnits:    8,
+			memoryUnits: 32,
+		},
+		{
+			name: "no_ratio",
+			zone: &zone.Zone{},
+			node: &node.Node{
+				CpuUnits:    8,
+				MemoryUnits: 32,
+			},
+			cpuUnits:    8,
+			memoryUnits: 32,
+		},
+		{
+			name: "zone_ratio",
+			zone: &zone.Zone{
+				CpuOvercommit:    2,
+				MemoryOvercommit: 1.5,
+			},
+			node: &node.Node{
+				CpuUnits:    8,
+				MemoryUnits: 32,
+			},
+			cpuUnits:    16,
+			memoryUnits: 48,
+		},
+		{
+			name: "node_ratio",
+			zone: &zone.Zone{
+				CpuOvercommit:    2,
+				MemoryOvercommit: 1.5,
+			},
+			node: &node.Node{
+				CpuUnits:         8,
+				MemoryUnits:      32,
+				CpuOvercommit:    4,
+				MemoryOvercommit: 0.5,
+			},
+			cpuUnits:    32,
+			memoryUnits: 16,
+		},
+		{
+			name: "partial_ratio",
+			zone: &zone.Zone{
+				CpuOvercommit: 3,
+			},
+			node: &node.Node{
+				CpuUnits:    8,
+				MemoryUnits: 32,
+			},
+			cpuUnits:    24,
+			memoryUnits: 32,
+		},
+	}
+
+	for _, test := range tests {
+		cpuUnits, memoryUnits := GetCapacity(test.zone, test.node)
+		if cpuUnits != test.cpuUnits || memoryUnits != test.memoryUnits {
+			t.Errorf("%s: capacity = %d/%v, want %d/%v", test.name,
+				cpuUnits, memoryUnits, test.cpuUnits, test.memoryUnits)
+		}
+	}
+}
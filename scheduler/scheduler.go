@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"gopkg.in/mgo.v2/bson"
+	"math"
+)
+
+type Candidate struct {
+	Node           *node.Node
+	CpuUnits       int
+	MemoryUnits    float64
+	CpuUnitsRes    int
+	MemoryUnitsRes float64
+	processors     int
+	memory         float64
+}
+
+func (c *Candidate) fits(processors int, memory float64) bool {
+	return (c.CpuUnits == 0 || c.CpuUnitsRes+processors <= c.CpuUnits) &&
+		(c.MemoryUnits == 0 || c.MemoryUnitsRes+memory <= c.MemoryUnits)
+}
+
+func (c *Candidate) Usage() float64 {
+	cpuUnits := c.CpuUnits
+	if cpuUnits == 0 {
+		cpuUnits = c.Node.CpuUnits
+	}
+	memoryUnits := c.MemoryUnits
+	if memoryUnits == 0 {
+		memoryUnits = c.Node.MemoryUnits
+	}
+
+	cpuUsage := float64(c.CpuUnitsRes+c.processors) / float64(cpuUnits)
+	memUsage := (c.MemoryUnitsRes + c.memory) / memoryUnits
+
+	return math.Max(cpuUsage, memUsage)
+}
+
+type Scheduler struct {
+	Zone       bson.ObjectId
+	Strategy   string
+	Filter     func(nodeId, zoneId bson.ObjectId) bool
+	candidates []*Candidate
+}
+
+func (s *Scheduler) Schedule(processors, memory int) (
+	nde *node.Node, errData *errortypes.ErrorData) {
+
+	memoryUnits := float64(memory) / float64(1024)
+
+	candidates := []*Candidate{}
+	for _, cand := range s.candidates {
+		if !cand.fits(processors, memoryUnits) {
+			continue
+		}
+
+		if s.Filter != nil && !s.Filter(cand.Node.Id, cand.Node.Zone) {
+			continue
+		}
+
+		cand.processors = processors
+		cand.memory = memoryUnits
+		candidates = append(candidates, cand)
+	}
+
+	selected := strategies[s.Strategy](candidates)
+
+	for _, cand := range candidates {
+		cand.processors = 0
+		cand.memory = 0
+	}
+
+	if selected == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "node_capacity_unavailable",
+			Message: "No node in zone has capacity for instance",
+		}
+		return
+	}
+
+	selected.CpuUnitsRes += processors
+	selected.MemoryUnitsRes += memoryUnits
+	nde = selected.Node
+
+	return
+}
+
+func New(db *database.Database, zoneId bson.ObjectId, strategy string) (
+	sch *Scheduler, errData *errortypes.ErrorData, err error) {
+
+	if strategy == "" {
+		strategy = settings.Hypervisor.Scheduler
+	}
+
+	if !Exists(strategy) {
+		errData = &errortypes.ErrorData{
+			Error:   "scheduler_invalid",
+			Message: "Invalid scheduler strategy",
+		}
+		return
+	}
+
+	if zoneId == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "zone_required",
+			Message: "Missing required zone",
+		}
+		return
+	}
+
+	zne, err := zone.Get(db, zoneId)
+	if err != nil {
+		return
+	}
+
+	nodes, err := node.GetZone(db, zoneId)
+	if err != nil {
+		return
+	}
+
+	nodeIds := []bson.ObjectId{}
+	for _, nde := range nodes {
+		nodeIds = append(nodeIds, nde.Id)
+	}
+
+	cpuUnitsRes, memoryUnitsRes, err := getReserved(db, nodeIds, "")
+	if err != nil {
+		return
+	}
+
+	candidates := []*Candidate{}
+	for _, nde := range nodes {
+		if !nde.IsHypervisor() || nde.Fenced || nde.IsStale() ||
+			nde.CpuUnits == 0 || nde.MemoryUnits == 0 {
+
+			continue
+		}
+
+		cpuUnits, memoryUnits := GetCapacity(zne, nde)
+
+		candidates = append(candidates, &Candidate{
+			Node:           nde,
+			CpuUnits:       cpuUnits,
+			MemoryUnits:    memoryUnits,
+			CpuUnitsRes:    cpuUnitsRes[nde.Id],
+			MemoryUnitsRes: memoryUnitsRes[nde.Id],
+		})
+	}
+
+	sch = &Scheduler{
+		Zone:       zoneId,
+		Strategy:   strategy,
+		candidates: candidates,
+	}
+
+	return
+}
@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"github.com/pritunl/pritunl-cloud/node"
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+)
+
+func newCandidate(id string, cpuUnits int, memoryUnits float64,
+	cpuUnitsRes int, memoryUnitsRes float64) *Candidate {
+
+	return &Candidate{
+		Node: &node.Node{
+			Id:          bson.ObjectId(id),
+			CpuUnits:    8,
+			MemoryUnits: 32,
+		},
+		CpuUnits:       cpuUnits,
+		MemoryUnits:    memoryUnits,
+		CpuUnitsRes:    cpuUnitsRes,
+		MemoryUnitsRes: memoryUnitsRes,
+	}
+}
+
+func TestCandidateFits(t *testing.T) {
+	tests := []struct {
+		name       string
+		cand       *Candidate
+		processors int
+		memory     float64
+		fits       bool
+	}{
+		{"empty", newCandidate("a", 8, 32, 0, 0), 4, 16, true},
+		{"exact", newCandidate("a", 8, 32, 4, 16), 4, 16, true},
+		{"cpu_full", newCandidate("a", 8, 32, 6, 0), 4, 1, false},
+		{"memory_full", newCandidate("a", 8, 32, 0, 30), 1, 4, false},
+		{"cpu_unlimited", newCandidate("a", 0, 32, 100, 0), 4, 1, true},
+		{"memory_unlimited", newCandidate("a", 8, 0, 0, 100), 1, 4, true},
+	}
+
+	for _, test := range tests {
+		fits := test.cand.fits(test.processors, test.memory)
+		if fits != test.fits {
+			t.Errorf("%s: fits = %t, want %t", test.name, fits, test.fits)
+		}
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   Strategy
+		candidates []*Candidate
+		selected   string
+	}{
+		{
+			name:       "spread_empty",
+			strategy:   spread,
+			candidates: []*Candidate{},
+			selected:   "",
+		},
+		{
+			name:     "spread",
+			strategy: spread,
+			candidates: []*Candidate{
+				newCandidate("a", 8, 32, 6, 8),
+				newCandidate("b", 8, 32, 2, 8),
+				newCandidate("c", 8, 32, 2, 24),
+			},
+			selected: "b",
+		},
+		{
+			name:     "spread_unlimited",
+			strategy: spread,
+			candidates: []*Candidate{
+				newCandidate("a", 16, 64, 6, 16),
+				newCandidate("b", 0, 0, 6, 16),
+			},
+			selected: "a",
+		},
+		{
+			name:       "pack_empty",
+			strategy:   pack,
+			candidates: []*Candidate{},
+			selected:   "",
+		},
+		{
+			name:     "pack",
+			strategy: pack,
+			candidates: []*Candidate{
+				newCandidate("a", 8, 32, 6, 8),
+				newCandidate("b", 8, 32, 2, 8),
+				newCandidate("c", 8, 32, 2, 28),
+			},
+			selected: "c",
+		},
+	}
+
+	for _, test := range tests {
+		selected := test.strategy(test.candidates)
+
+		selectedId := ""
+		if selected != nil {
+			selectedId = string(selected.Node.Id)
+		}
+
+		if selectedId != test.selected {
+			t.Errorf("%s: selected = %q, want %q",
+				test.name, selectedId, test.selected)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  string
+		instances int
+		nodes     []string
+		err       string
+	}{
+		{"spread", Spread, 4, []string{"a", "b", "a", "b"}, ""},
+		{"pack", Pack, 4, []string{"a", "a", "b", "b"}, ""},
+		{"full", Spread, 5, []string{"a", "b", "a", "b"},
+			"node_capacity_unavailable"},
+	}
+
+	for _, test := range tests {
+		sch := &Scheduler{
+			Strategy: test.strategy,
+			candidates: []*Candidate{
+				newCandidate("a", 4, 16, 0, 0),
+				newCandidate("b", 4, 16, 0, 0),
+			},
+		}
+
+		for i := 0; i < test.instances; i++ {
+			nde, errData := sch.Schedule(2, 4096)
+			if i < len(test.nodes) {
+				if errData != nil {
+					t.Errorf("%s: instance %d unexpected error %s",
+						test.name, i, errData.Error)
+					break
+				}
+
+				if string(nde.Id) != test.nodes[i] {
+					t.Errorf("%s: instance %d node = %q, want %q",
+						test.name, i, string(nde.Id), test.nodes[i])
+				}
+				continue
+			}
+
+			if errData == nil || errData.Error != test.err {
+				t.Errorf("%s: instance %d error = %v, want %s",
+					test.name, i, errData, test.err)
+			}
+		}
+	}
+}
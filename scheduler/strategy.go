@@ -0,0 +1,37 @@
+package scheduler
+
+type Strategy func(candidates []*Candidate) *Candidate
+
+var strategies = map[string]Strategy{
+	Spread: spread,
+	Pack:   pack,
+}
+
+func Register(name string, strategy Strategy) {
+	strategies[name] = strategy
+}
+
+func Exists(name string) bool {
+	_, ok := strategies[name]
+	return ok
+}
+
+func spread(candidates []*Candidate) (selected *Candidate) {
+	for _, cand := range candidates {
+		if selected == nil || cand.Usage() < selected.Usage() {
+			selected = cand
+		}
+	}
+
+	return
+}
+
+func pack(candidates []*Candidate) (selected *Candidate) {
+	for _, cand := range candidates {
+		if selected == nil || cand.Usage() > selected.Usage() {
+			selected = cand
+		}
+	}
+
+	return
+}
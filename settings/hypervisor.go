@@ -3,12 +3,18 @@ package settings
 var Hypervisor *hypervisor
 
 type hypervisor struct {
-	Id           string `bson:"_id"`
-	SystemdPath  string `bson:"systemd_path" default:"/etc/systemd/system"`
-	LibPath      string `bson:"systemd_path" default:"/var/lib/pritunl-cloud"`
-	BridgeName   string `bson:"bridge_name" default:"pritunlbr0"`
-	StartTimeout int    `bson:"start_timeout" default:"30"`
-	StopTimeout  int    `bson:"stop_timeout" default:"60"`
+	Id             string `bson:"_id"`
+	SystemdPath    string `bson:"systemd_path" default:"/etc/systemd/system"`
+	LibPath        string `bson:"systemd_path" default:"/var/lib/pritunl-cloud"`
+	BridgeName     string `bson:"bridge_name" default:"pritunlbr0"`
+	NatBridgeName  string `bson:"nat_bridge_name" default:"pritunlnat0"`
+	NatNetwork     string `bson:"nat_network" default:"198.18.0.1/16"`
+	StartTimeout   int    `bson:"start_timeout" default:"30"`
+	StopTimeout    int    `bson:"stop_timeout" default:"60"`
+	MigrateTimeout int    `bson:"migrate_timeout" default:"3600"`
+	SnapshotChain  int    `bson:"snapshot_chain" default:"7"`
+	Scheduler      string `bson:"scheduler" default:"spread"`
+	FenceTimeout   int    `bson:"fence_timeout" default:"120"`
 }
 
 func newHypervisor() interface{} {
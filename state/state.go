@@ -6,6 +6,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/domain"
+	"github.com/pritunl/pritunl-cloud/floatingip"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/qemu"
@@ -13,6 +14,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/vpc"
 	"gopkg.in/mgo.v2/bson"
+	"net"
 )
 
 type State struct {
@@ -22,7 +24,10 @@ type State struct {
 	virtsMap         map[bson.ObjectId]*vm.VirtualMachine
 	instances        []*instance.Instance
 	domainRecordsMap map[bson.ObjectId][]*domain.Record
+	floatingIpsMap   map[bson.ObjectId]*floatingip.FloatingIp
 	vpcsMap          map[bson.ObjectId]*vpc.Vpc
+	vpcPeersMap      map[bson.ObjectId][]bson.ObjectId
+	vpcRoleIpsMap    map[bson.ObjectId]map[string][]string
 	instancesMap     map[bson.ObjectId]*instance.Instance
 	addInstances     set.Set
 	remInstances     set.Set
@@ -44,6 +49,10 @@ func (s *State) DomainRecords(instId bson.ObjectId) []*domain.Record {
 	return s.domainRecordsMap[instId]
 }
 
+func (s *State) FloatingIp(instId bson.ObjectId) *floatingip.FloatingIp {
+	return s.floatingIpsMap[instId]
+}
+
 func (s *State) Disks() []*disk.Disk {
 	return s.disks
 }
@@ -52,6 +61,23 @@ func (s *State) Vpc(vpcId bson.ObjectId) *vpc.Vpc {
 	return s.vpcsMap[vpcId]
 }
 
+func (s *State) VpcPeers(vpcId bson.ObjectId) []*vpc.Vpc {
+	peers := []*vpc.Vpc{}
+
+	for _, peerId := range s.vpcPeersMap[vpcId] {
+		peerVc := s.vpcsMap[peerId]
+		if peerVc != nil {
+			peers = append(peers, peerVc)
+		}
+	}
+
+	return peers
+}
+
+func (s *State) VpcRoleIps() map[bson.ObjectId]map[string][]string {
+	return s.vpcRoleIpsMap
+}
+
 func (s *State) DiskInUse(instId, dskId bson.ObjectId) bool {
 	curVirt := s.virtsMap[instId]
 
@@ -108,14 +134,25 @@ func (s *State) init() (err error) {
 	s.virtsMap = virtsMap
 
 	instances, err := instance.GetAllVirt(db, &bson.M{
-		"node": node.Self.Id,
+		"$or": []*bson.M{
+			&bson.M{
+				"node": node.Self.Id,
+			},
+			&bson.M{
+				"migrate_node": node.Self.Id,
+			},
+		},
 	}, disks)
 	s.instances = instances
 
+	instIds := []bson.ObjectId{}
 	vpcIdsSet := set.NewSet()
 	for _, inst := range instances {
 		virtsId.Remove(inst.Id)
-		vpcIdsSet.Add(inst.Vpc)
+		instIds = append(instIds, inst.Id)
+		for _, vpcId := range inst.GetVpcs() {
+			vpcIdsSet.Add(vpcId)
+		}
 	}
 
 	vpcIds := []bson.ObjectId{}
@@ -123,6 +160,77 @@ func (s *State) init() (err error) {
 		vpcIds = append(vpcIds, vpcIdInf.(bson.ObjectId))
 	}
 
+	vpcInsts, err := instance.GetAll(db, &bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"vpc": &bson.M{
+					"$in": vpcIds,
+				},
+			},
+			&bson.M{
+				"secondary_vpcs": &bson.M{
+					"$in": vpcIds,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	vpcRoleIpsMap := map[bson.ObjectId]map[string][]string{}
+	for _, inst := range vpcInsts {
+		if len(inst.NetworkRoles) == 0 {
+			continue
+		}
+
+		for _, vpcId := range inst.GetVpcs() {
+			if !vpcIdsSet.Contains(vpcId) {
+				continue
+			}
+
+			privateIps, privateIps6 := inst.GetPrivateIps(vpcId)
+
+			ips := []string{}
+			for _, ip := range privateIps {
+				if net.ParseIP(ip) != nil {
+					ips = append(ips, ip+"/32")
+				}
+			}
+			for _, ip := range privateIps6 {
+				if net.ParseIP(ip) != nil {
+					ips = append(ips, ip+"/128")
+				}
+			}
+
+			roleIps := vpcRoleIpsMap[vpcId]
+			if roleIps == nil {
+				roleIps = map[string][]string{}
+				vpcRoleIpsMap[vpcId] = roleIps
+			}
+
+			for _, role := range inst.NetworkRoles {
+				roleIps[role] = append(roleIps[role], ips...)
+			}
+		}
+	}
+	s.vpcRoleIpsMap = vpcRoleIpsMap
+
+	vpcPeersMap, err := vpc.GetPeersActive(db, vpcIds)
+	if err != nil {
+		return
+	}
+	s.vpcPeersMap = vpcPeersMap
+
+	for _, peerIds := range vpcPeersMap {
+		for _, peerId := range peerIds {
+			if !vpcIdsSet.Contains(peerId) {
+				vpcIdsSet.Add(peerId)
+				vpcIds = append(vpcIds, peerId)
+			}
+		}
+	}
+
 	for virtId := range virtsId.Iter() {
 		logrus.WithFields(logrus.Fields{
 			"id": virtId.(bson.ObjectId).Hex(),
@@ -141,6 +249,28 @@ func (s *State) init() (err error) {
 	}
 	s.vpcsMap = vpcsMap
 
+	fips, err := floatingip.GetAll(db, &bson.M{
+		"instance": &bson.M{
+			"$in": instIds,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	floatingIpsMap := map[bson.ObjectId]*floatingip.FloatingIp{}
+	for _, fip := range fips {
+		floatingIpsMap[fip.Instance] = fip
+	}
+	s.floatingIpsMap = floatingIpsMap
+
+	for _, inst := range instances {
+		fip := floatingIpsMap[inst.Id]
+		if fip != nil && !inst.PrivateOnly && inst.Virt != nil {
+			inst.Virt.FloatingIp = fip.GetVirt()
+		}
+	}
+
 	recrds, err := domain.GetRecordAll(db, &bson.M{
 		"node": node.Self.Id,
 	})
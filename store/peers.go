@@ -0,0 +1,44 @@
+package store
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"sync"
+	"time"
+)
+
+var (
+	peersStores     = map[bson.ObjectId]PeersStore{}
+	peersStoresLock = sync.Mutex{}
+)
+
+type PeersStore struct {
+	Peers     []string
+	Timestamp time.Time
+}
+
+func GetPeers(instId bson.ObjectId) (peersStore PeersStore, ok bool) {
+	peersStoresLock.Lock()
+	peersStore, ok = peersStores[instId]
+	peersStoresLock.Unlock()
+
+	if ok {
+		peersStore.Peers = append([]string{}, peersStore.Peers...)
+	}
+
+	return
+}
+
+func SetPeers(instId bson.ObjectId, peers []string) {
+	peersStoresLock.Lock()
+	peersStores[instId] = PeersStore{
+		Peers:     append([]string{}, peers...),
+		Timestamp: time.Now(),
+	}
+	peersStoresLock.Unlock()
+}
+
+func RemPeers(instId bson.ObjectId) {
+	peersStoresLock.Lock()
+	delete(peersStores, instId)
+	peersStoresLock.Unlock()
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/state"
+	"gopkg.in/mgo.v2/bson"
 	"time"
 )
 
@@ -33,7 +34,8 @@ func syncNodeFirewall() {
 	defer db.Close()
 
 	for i := 0; i < 2; i++ {
-		err := iptables.UpdateState(db, []*instance.Instance{}, []string{})
+		err := iptables.UpdateState(db, []*instance.Instance{},
+			map[bson.ObjectId]map[string][]string{}, []string{})
 		if err != nil {
 			if i < 1 {
 				err = nil
@@ -96,6 +98,10 @@ func vmRunner() {
 			break
 		}
 
+		if node.Self.IsFenced() {
+			continue
+		}
+
 		err := deployState()
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
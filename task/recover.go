@@ -0,0 +1,282 @@
+package task
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/placement"
+	"github.com/pritunl/pritunl-cloud/scheduler"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+var (
+	recoverStart = time.Now()
+)
+
+var instanceRecover = &Task{
+	Name:    "instance_recover",
+	Hours:   AllHours,
+	Mins:    AllMins,
+	Handler: instanceRecoverHandler,
+}
+
+func getRecoverDisks(db *database.Database, zne *zone.Zone,
+	inst *instance.Instance) (disks []*disk.Disk, ok bool, err error) {
+
+	disks, err = disk.GetInstance(db, inst.Id)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range disks {
+		if dsk.Node != inst.Node || zne.SharedStorage {
+			continue
+		}
+
+		img, e := image.GetDiskLatest(db, dsk.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if img == nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"disk_id":     dsk.Id.Hex(),
+			}).Warning("task: Cannot recover instance without disk snapshot")
+			return
+		}
+
+		dsk.State = disk.Restore
+		dsk.RestoreImage = img.Id
+	}
+
+	ok = true
+	return
+}
+
+func recoverInstance(db *database.Database, zne *zone.Zone,
+	sch *scheduler.Scheduler, inst *instance.Instance) (
+	recovered bool, err error) {
+
+	disks, ok, err := getRecoverDisks(db, zne, inst)
+	if err != nil || !ok {
+		return
+	}
+
+	sch.Filter = nil
+	if inst.PlacementGroup != "" {
+		filter, errData, e := placement.GetFilter(
+			db, inst.Organization, inst.PlacementGroup)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if errData == nil {
+			sch.Filter = filter
+		}
+	}
+
+	nde, errData := sch.Schedule(inst.Processors, inst.Memory)
+	if errData != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"zone_id":     zne.Id.Hex(),
+			"error":       errData.Message,
+		}).Warning("task: No node available to recover instance")
+		return
+	}
+
+	deadNodeId := inst.Node
+
+	for _, dsk := range disks {
+		if dsk.Node != deadNodeId {
+			continue
+		}
+
+		dsk.Node = nde.Id
+		err = dsk.CommitFields(db,
+			set.NewSet("node", "state", "restore_image"))
+		if err != nil {
+			return
+		}
+	}
+
+	inst.Node = nde.Id
+	inst.VmState = vm.Stopped
+	inst.Restart = false
+	err = inst.CommitFields(db, set.NewSet("node", "vm_state", "restart"))
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id":  inst.Id.Hex(),
+		"dead_node_id": deadNodeId.Hex(),
+		"node_id":      nde.Id.Hex(),
+	}).Warning("task: Recovered instance from fenced node")
+
+	recovered = true
+	return
+}
+
+func recoverZone(db *database.Database, zoneId bson.ObjectId,
+	insts []*instance.Instance) (changed bool, err error) {
+
+	zne, err := zone.Get(db, zoneId)
+	if err != nil {
+		return
+	}
+
+	lockId, err := zone.ReserveLock(db, zoneId)
+	if err != nil {
+		return
+	}
+	defer zone.ReserveUnlock(db, zoneId, lockId)
+
+	sch, errData, err := scheduler.New(db, zoneId, "")
+	if err != nil {
+		return
+	}
+
+	if errData != nil {
+		logrus.WithFields(logrus.Fields{
+			"zone_id": zoneId.Hex(),
+			"error":   errData.Message,
+		}).Error("task: Failed to create instance recover scheduler")
+		return
+	}
+
+	for _, inst := range insts {
+		recovered, e := recoverInstance(db, zne, sch, inst)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       e,
+			}).Error("task: Failed to recover instance")
+			continue
+		}
+
+		if recovered {
+			changed = true
+		}
+	}
+
+	return
+}
+
+func instanceRecoverHandler(db *database.Database) (err error) {
+	timeout := time.Duration(settings.Hypervisor.FenceTimeout) * time.Second
+
+	// Allow nodes to report after a cluster restart before fencing
+	if timeout == 0 || time.Since(recoverStart) < timeout {
+		return
+	}
+
+	nodes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	recoverIds := []bson.ObjectId{}
+	fencedZones := map[bson.ObjectId]bson.ObjectId{}
+	nodesChanged := false
+
+	for _, nde := range nodes {
+		if !nde.IsHypervisor() || nde.Zone == "" {
+			continue
+		}
+
+		if !nde.Fenced {
+			if time.Since(nde.Timestamp) < timeout {
+				continue
+			}
+
+			fenced, e := nde.Fence(db)
+			if e != nil {
+				err = e
+				return
+			}
+
+			if !fenced {
+				continue
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"node_id":   nde.Id.Hex(),
+				"timestamp": nde.Timestamp,
+			}).Error("task: Hypervisor stopped responding, fenced node")
+			nodesChanged = true
+		}
+
+		// Allow fenced nodes to read the fence and stop their instances
+		if time.Since(nde.Timestamp) < 2*timeout {
+			continue
+		}
+
+		recoverIds = append(recoverIds, nde.Id)
+		fencedZones[nde.Id] = nde.Zone
+	}
+
+	if nodesChanged {
+		event.PublishDispatch(db, "node.change")
+	}
+
+	if len(recoverIds) == 0 {
+		return
+	}
+
+	insts, err := instance.GetAll(db, &bson.M{
+		"node": &bson.M{
+			"$in": recoverIds,
+		},
+		"state":        instance.Start,
+		"auto_recover": true,
+	})
+	if err != nil {
+		return
+	}
+
+	zoneInsts := map[bson.ObjectId][]*instance.Instance{}
+	for _, inst := range insts {
+		zoneId := fencedZones[inst.Node]
+		zoneInsts[zoneId] = append(zoneInsts[zoneId], inst)
+	}
+
+	changed := false
+	for zoneId, instances := range zoneInsts {
+		recovered, e := recoverZone(db, zoneId, instances)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"zone_id": zoneId.Hex(),
+				"error":   e,
+			}).Error("task: Failed to recover zone instances")
+			continue
+		}
+
+		if recovered {
+			changed = true
+		}
+	}
+
+	if changed {
+		event.PublishDispatch(db, "disk.change")
+		event.PublishDispatch(db, "instance.change")
+	}
+
+	return
+}
+
+func init() {
+	register(instanceRecover)
+}
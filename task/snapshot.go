@@ -0,0 +1,190 @@
+package task
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/pritunl-cloud/data"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/organization"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+var snapshotSchedule = &Task{
+	Name:    "snapshot_schedule",
+	Hours:   AllHours,
+	Mins:    FiveMins,
+	Handler: snapshotScheduleHandler,
+}
+
+func getSnapshotInterval(schedule string) time.Duration {
+	switch schedule {
+	case disk.Hourly:
+		return 1 * time.Hour
+	case disk.Daily:
+		return 24 * time.Hour
+	case disk.Weekly:
+		return 168 * time.Hour
+	default:
+		return 0
+	}
+}
+
+func snapshotExpire(db *database.Database, dsk *disk.Disk,
+	retention int) (err error) {
+
+	imgs, err := image.GetScheduled(db, dsk.Id)
+	if err != nil {
+		return
+	}
+
+	if len(imgs) <= retention {
+		return
+	}
+
+	for _, img := range imgs[retention:] {
+		logrus.WithFields(logrus.Fields{
+			"disk_id":  dsk.Id.Hex(),
+			"image_id": img.Id.Hex(),
+		}).Info("task: Removing expired disk snapshot")
+
+		e := data.DeleteImage(db, img.Id)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id":  dsk.Id.Hex(),
+				"image_id": img.Id.Hex(),
+				"error":    e,
+			}).Error("task: Failed to remove expired disk snapshot")
+			continue
+		}
+	}
+
+	event.PublishDispatch(db, "image.change")
+
+	return
+}
+
+func scheduleSnapshot(db *database.Database,
+	org *organization.Organization, dsk *disk.Disk, timestamp time.Time) (
+	scheduled bool, err error) {
+
+	if org == nil || org.Quota == nil {
+		scheduled, err = disk.SetSnapshotScheduled(db, dsk.Id, timestamp)
+		return
+	}
+
+	lockId, err := organization.QuotaLock(db, org.Id)
+	if err != nil {
+		return
+	}
+	defer organization.QuotaUnlock(db, org.Id, lockId)
+
+	usage, err := organization.GetUsage(db, org.Id)
+	if err != nil {
+		return
+	}
+
+	errData := org.Quota.Check(usage, &organization.Usage{
+		Snapshots: 1,
+	})
+	if errData != nil {
+		logrus.WithFields(logrus.Fields{
+			"disk_id":         dsk.Id.Hex(),
+			"organization_id": org.Id.Hex(),
+			"error":           errData.Message,
+		}).Warning("task: Skipping disk snapshot, quota exceeded")
+		return
+	}
+
+	scheduled, err = disk.SetSnapshotScheduled(db, dsk.Id, timestamp)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func snapshotScheduleHandler(db *database.Database) (err error) {
+	orgs, err := organization.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	orgsMap := map[bson.ObjectId]*organization.Organization{}
+	for _, org := range orgs {
+		orgsMap[org.Id] = org
+	}
+
+	disks, err := disk.GetAll(db, &bson.M{})
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	changed := false
+
+	for _, dsk := range disks {
+		schedule := dsk.SnapshotSchedule
+		retention := dsk.SnapshotRetention
+
+		if schedule == "" {
+			org := orgsMap[dsk.Organization]
+			if org == nil {
+				continue
+			}
+
+			schedule = org.SnapshotSchedule
+			retention = org.SnapshotRetention
+		}
+
+		interval := getSnapshotInterval(schedule)
+		if interval == 0 {
+			continue
+		}
+
+		if dsk.State != disk.Available {
+			continue
+		}
+
+		if now.Sub(dsk.LastSnapshot) >= interval-time.Minute {
+			scheduled, e := scheduleSnapshot(db, orgsMap[dsk.Organization],
+				dsk, now)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"disk_id": dsk.Id.Hex(),
+					"error":   e,
+				}).Error("task: Failed to schedule disk snapshot")
+				continue
+			}
+
+			// Expire after the scheduled snapshot has been created
+			if scheduled {
+				changed = true
+				continue
+			}
+		}
+
+		if retention > 0 {
+			err = snapshotExpire(db, dsk, retention)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"disk_id": dsk.Id.Hex(),
+					"error":   err,
+				}).Error("task: Failed to expire disk snapshots")
+				err = nil
+			}
+		}
+	}
+
+	if changed {
+		event.PublishDispatch(db, "disk.change")
+	}
+
+	return
+}
+
+func init() {
+	register(snapshotSchedule)
+}
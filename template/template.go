@@ -0,0 +1,109 @@
+package template
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type Template struct {
+	Id           bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Name         string        `bson:"name" json:"name"`
+	Organization bson.ObjectId `bson:"organization,omitempty" json:"organization"`
+	Zone         bson.ObjectId `bson:"zone,omitempty" json:"zone"`
+	Vpc          bson.ObjectId `bson:"vpc,omitempty" json:"vpc"`
+	Node         bson.ObjectId `bson:"node,omitempty" json:"node"`
+	Image        bson.ObjectId `bson:"image,omitempty" json:"image"`
+	Domain       bson.ObjectId `bson:"domain,omitempty" json:"domain"`
+	InitDiskSize int           `bson:"init_disk_size" json:"init_disk_size"`
+	Memory       int           `bson:"memory" json:"memory"`
+	Processors   int           `bson:"processors" json:"processors"`
+	NetworkRoles []string      `bson:"network_roles" json:"network_roles"`
+	Vnc          bool          `bson:"vnc" json:"vnc"`
+	UserData     string        `bson:"user_data" json:"user_data"`
+}
+
+func (t *Template) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if t.Organization == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if t.InitDiskSize != 0 && t.InitDiskSize < 10 {
+		errData = &errortypes.ErrorData{
+			Error:   "init_disk_size_invalid",
+			Message: "Disk size below minimum",
+		}
+		return
+	}
+
+	errData = instance.ValidateUserData(t.UserData)
+	if errData != nil {
+		return
+	}
+
+	if t.Memory != 0 && t.Memory < 256 {
+		t.Memory = 256
+	}
+
+	if t.Processors < 0 {
+		t.Processors = 0
+	}
+
+	if t.NetworkRoles == nil {
+		t.NetworkRoles = []string{}
+	}
+
+	return
+}
+
+func (t *Template) Commit(db *database.Database) (err error) {
+	coll := db.Templates()
+
+	err = coll.Commit(t.Id, t)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (t *Template) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.Templates()
+
+	err = coll.CommitFields(t.Id, t, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (t *Template) Insert(db *database.Database) (err error) {
+	coll := db.Templates()
+
+	if t.Id != "" {
+		err = &errortypes.DatabaseError{
+			errors.New("template: Template already exists"),
+		}
+		return
+	}
+
+	err = coll.Insert(t)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
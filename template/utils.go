@@ -0,0 +1,147 @@
+package template
+
+import (
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func Get(db *database.Database, tmplId bson.ObjectId) (
+	tmpl *Template, err error) {
+
+	coll := db.Templates()
+	tmpl = &Template{}
+
+	err = coll.FindOneId(tmplId, tmpl)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, tmplId bson.ObjectId) (
+	tmpl *Template, err error) {
+
+	coll := db.Templates()
+	tmpl = &Template{}
+
+	err = coll.FindOne(&bson.M{
+		"_id":          tmplId,
+		"organization": orgId,
+	}, tmpl)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M, page, pageCount int) (
+	tmpls []*Template, count int, err error) {
+
+	coll := db.Templates()
+	tmpls = []*Template{}
+
+	qury := coll.Find(query)
+
+	count, err = qury.Count()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	skip := utils.Min(page*pageCount, utils.Max(0, count-pageCount))
+
+	cursor := qury.Sort("name").Skip(skip).Limit(pageCount).Iter()
+
+	tmpl := &Template{}
+	for cursor.Next(tmpl) {
+		tmpls = append(tmpls, tmpl)
+		tmpl = &Template{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, tmplId bson.ObjectId) (err error) {
+	coll := db.Templates()
+
+	err = coll.Remove(&bson.M{
+		"_id": tmplId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, tmplId bson.ObjectId) (
+	err error) {
+
+	coll := db.Templates()
+
+	err = coll.Remove(&bson.M{
+		"_id":          tmplId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, tmplIds []bson.ObjectId) (err error) {
+	coll := db.Templates()
+
+	_, err = coll.RemoveAll(&bson.M{
+		"_id": &bson.M{
+			"$in": tmplIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RemoveMultiOrg(db *database.Database, orgId bson.ObjectId,
+	tmplIds []bson.ObjectId) (err error) {
+
+	coll := db.Templates()
+
+	_, err = coll.RemoveAll(&bson.M{
+		"_id": &bson.M{
+			"$in": tmplIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -13,7 +13,10 @@ import (
 	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/organization"
+	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/zone"
 	"gopkg.in/mgo.v2/bson"
 	"strconv"
@@ -21,14 +24,17 @@ import (
 )
 
 type diskData struct {
-	Id       bson.ObjectId `json:"id"`
-	Name     string        `json:"name"`
-	Instance bson.ObjectId `json:"instance"`
-	Index    string        `json:"index"`
-	Node     bson.ObjectId `json:"node"`
-	Image    bson.ObjectId `json:"image"`
-	State    string        `json:"state"`
-	Size     int           `json:"size"`
+	Id                bson.ObjectId `json:"id"`
+	Name              string        `json:"name"`
+	Instance          bson.ObjectId `json:"instance"`
+	Index             string        `json:"index"`
+	Node              bson.ObjectId `json:"node"`
+	Image             bson.ObjectId `json:"image"`
+	State             string        `json:"state"`
+	Size              int           `json:"size"`
+	SnapshotSchedule  string        `json:"snapshot_schedule"`
+	SnapshotRetention int           `json:"snapshot_retention"`
+	RestoreImage      bson.ObjectId `json:"restore_image"`
 }
 
 type disksMultiData struct {
@@ -62,6 +68,13 @@ func diskPut(c *gin.Context) {
 		return
 	}
 
+	lockId, err := organization.QuotaLock(db, userOrg)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer organization.QuotaUnlock(db, userOrg, lockId)
+
 	dsk, err := disk.GetOrg(db, userOrg, diskId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -82,9 +95,76 @@ func diskPut(c *gin.Context) {
 	dsk.Name = dta.Name
 	dsk.Instance = dta.Instance
 	dsk.Index = dta.Index
+	dsk.SnapshotSchedule = dta.SnapshotSchedule
+	dsk.SnapshotRetention = dta.SnapshotRetention
 
 	if dsk.State == disk.Available && dta.State == disk.Snapshot {
 		dsk.State = disk.Snapshot
+	} else if dsk.State == disk.Available && dta.Size != 0 &&
+		dta.Size != dsk.Size {
+
+		dsk.State = disk.Resize
+		dsk.NewSize = dta.Size
+	} else if dsk.State == disk.RestoreFailed &&
+		dta.State == disk.Available {
+
+		dsk.State = disk.Available
+		dsk.RestoreImage = ""
+	} else if (dsk.State == disk.Available ||
+		dsk.State == disk.RestoreFailed) && dta.State == disk.Restore {
+
+		img, err := image.GetOrg(db, userOrg, dta.RestoreImage)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if img.Type != storage.Private {
+			errData := &errortypes.ErrorData{
+				Error:   "restore_image_invalid",
+				Message: "Restore image must be a snapshot",
+			}
+			c.JSON(400, errData)
+			return
+		}
+
+		if dsk.Instance != "" {
+			inst, err := instance.Get(db, dsk.Instance)
+			if err != nil {
+				utils.AbortWithError(c, 500, err)
+				return
+			}
+
+			if inst.State != instance.Stop || inst.VmState != vm.Stopped {
+				errData := &errortypes.ErrorData{
+					Error:   "instance_running",
+					Message: "Instance must be stopped to restore disk",
+				}
+				c.JSON(400, errData)
+				return
+			}
+		}
+
+		dsk.State = disk.Restore
+		dsk.RestoreImage = img.Id
+	}
+
+	quotaReq := &organization.Usage{}
+	if dsk.State == disk.Snapshot {
+		quotaReq.Snapshots = 1
+	} else if dsk.State == disk.Resize {
+		quotaReq.Disk = dsk.NewSize - dsk.Size
+	}
+
+	errData, err := organization.CheckQuota(db, userOrg, quotaReq)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
 	}
 
 	fields := set.NewSet(
@@ -92,9 +172,13 @@ func diskPut(c *gin.Context) {
 		"name",
 		"instance",
 		"index",
+		"new_size",
+		"restore_image",
+		"snapshot_schedule",
+		"snapshot_retention",
 	)
 
-	errData, err := dsk.Validate(db)
+	errData, err = dsk.Validate(db)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
@@ -177,17 +261,39 @@ func diskPost(c *gin.Context) {
 		}
 	}
 
-	dsk := &disk.Disk{
-		Name:         dta.Name,
-		Organization: userOrg,
-		Instance:     dta.Instance,
-		Index:        dta.Index,
-		Node:         dta.Node,
-		Image:        dta.Image,
-		Size:         dta.Size,
+	lockId, err := organization.QuotaLock(db, userOrg)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
 	}
+	defer organization.QuotaUnlock(db, userOrg, lockId)
 
-	errData, err := dsk.Validate(db)
+	errData, err := organization.CheckQuota(db, userOrg, &organization.Usage{
+		Disk: utils.Max(dta.Size, 10),
+	})
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	dsk := &disk.Disk{
+		Name:              dta.Name,
+		Organization:      userOrg,
+		Instance:          dta.Instance,
+		Index:             dta.Index,
+		Node:              dta.Node,
+		Image:             dta.Image,
+		Size:              dta.Size,
+		SnapshotSchedule:  dta.SnapshotSchedule,
+		SnapshotRetention: dta.SnapshotRetention,
+	}
+
+	errData, err = dsk.Validate(db)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
@@ -19,6 +19,8 @@ type firewallData struct {
 	Name         string           `json:"name"`
 	NetworkRoles []string         `json:"network_roles"`
 	Ingress      []*firewall.Rule `json:"ingress"`
+	Egress       []*firewall.Rule `json:"egress"`
+	LogDrop      bool             `json:"log_drop"`
 }
 
 type firewallsData struct {
@@ -56,12 +58,16 @@ func firewallPut(c *gin.Context) {
 	fire.Name = data.Name
 	fire.NetworkRoles = data.NetworkRoles
 	fire.Ingress = data.Ingress
+	fire.Egress = data.Egress
+	fire.LogDrop = data.LogDrop
 
 	fields := set.NewSet(
 		"state",
 		"name",
 		"network_roles",
 		"ingress",
+		"egress",
+		"log_drop",
 	)
 
 	errData, err := fire.Validate(db)
@@ -108,6 +114,8 @@ func firewallPost(c *gin.Context) {
 		Organization: userOrg,
 		NetworkRoles: data.NetworkRoles,
 		Ingress:      data.Ingress,
+		Egress:       data.Egress,
+		LogDrop:      data.LogDrop,
 	}
 
 	errData, err := fire.Validate(db)
@@ -199,6 +207,12 @@ func firewallGet(c *gin.Context) {
 		return
 	}
 
+	err = fire.LoadCounters(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	c.JSON(200, fire)
 }
 
@@ -77,6 +77,12 @@ func Register(engine *gin.Engine) {
 	orgGroup.DELETE("/firewall", firewallsDelete)
 	orgGroup.DELETE("/firewall/:firewall_id", firewallDelete)
 
+	orgGroup.GET("/floating_ip", floatingIpsGet)
+	orgGroup.GET("/floating_ip/:fip_id", floatingIpGet)
+	orgGroup.PUT("/floating_ip/:fip_id", floatingIpPut)
+	orgGroup.POST("/floating_ip", floatingIpPost)
+	orgGroup.DELETE("/floating_ip/:fip_id", floatingIpDelete)
+
 	orgGroup.GET("/image", imagesGet)
 	orgGroup.GET("/image/:image_id", imageGet)
 	orgGroup.PUT("/image/:image_id", imagePut)
@@ -87,6 +93,7 @@ func Register(engine *gin.Engine) {
 	orgGroup.PUT("/instance", instancesPut)
 	orgGroup.GET("/instance/:instance_id", instanceGet)
 	orgGroup.PUT("/instance/:instance_id", instancePut)
+	orgGroup.GET("/instance/:instance_id/console", instanceConsoleGet)
 	orgGroup.POST("/instance", instancePost)
 	orgGroup.DELETE("/instance", instancesDelete)
 	orgGroup.DELETE("/instance/:instance_id", instanceDelete)
@@ -96,16 +103,38 @@ func Register(engine *gin.Engine) {
 	orgGroup.GET("/node", nodesGet)
 
 	csrfGroup.GET("/organization", organizationsGet)
+	orgGroup.GET("/organization/usage", organizationUsageGet)
+
+	orgGroup.GET("/placement_group", placementGroupsGet)
+	orgGroup.GET("/placement_group/:group_id", placementGroupGet)
+	orgGroup.PUT("/placement_group/:group_id", placementGroupPut)
+	orgGroup.POST("/placement_group", placementGroupPost)
+	orgGroup.DELETE("/placement_group", placementGroupsDelete)
+	orgGroup.DELETE("/placement_group/:group_id", placementGroupDelete)
+
+	orgGroup.GET("/template", templatesGet)
+	orgGroup.GET("/template/:template_id", templateGet)
+	orgGroup.PUT("/template/:template_id", templatePut)
+	orgGroup.POST("/template", templatePost)
+	orgGroup.DELETE("/template", templatesDelete)
+	orgGroup.DELETE("/template/:template_id", templateDelete)
 
 	csrfGroup.PUT("/theme", themePut)
 
 	orgGroup.GET("/vpc", vpcsGet)
 	orgGroup.GET("/vpc/:vpc_id", vpcGet)
+	orgGroup.GET("/vpc/:vpc_id/link_history", vpcLinkHistoryGet)
 	orgGroup.PUT("/vpc/:vpc_id", vpcPut)
 	orgGroup.POST("/vpc", vpcPost)
 	orgGroup.DELETE("/vpc", vpcsDelete)
 	orgGroup.DELETE("/vpc/:vpc_id", vpcDelete)
 
+	orgGroup.GET("/vpc_peering", peeringsGet)
+	orgGroup.GET("/vpc_peering/:peering_id", peeringGet)
+	orgGroup.PUT("/vpc_peering/:peering_id", peeringPut)
+	orgGroup.POST("/vpc_peering", peeringPost)
+	orgGroup.DELETE("/vpc_peering/:peering_id", peeringDelete)
+
 	orgGroup.GET("/zone", zonesGet)
 
 	engine.GET("/robots.txt", middlewear.RobotsGet)
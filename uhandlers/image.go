@@ -194,6 +194,9 @@ func imagesGet(c *gin.Context) {
 			query = &bson.M{
 				"organization": userOrg,
 				"storage":      dc.PrivateStorage,
+				"deleted": &bson.M{
+					"$ne": true,
+				},
 			}
 
 			images2, err := image.GetAllNames(db, query)
@@ -272,6 +275,10 @@ func imagesGet(c *gin.Context) {
 			query["type"] = typ
 		}
 
+		query["deleted"] = &bson.M{
+			"$ne": true,
+		}
+
 		images, count, err := image.GetAll(db, &query, page, pageCount)
 		if err != nil {
 			utils.AbortWithError(c, 500, err)
@@ -5,14 +5,20 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/pritunl-cloud/aggregate"
+	"github.com/pritunl/pritunl-cloud/console"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/datacenter"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/domain"
+	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/organization"
+	"github.com/pritunl/pritunl-cloud/placement"
+	"github.com/pritunl/pritunl-cloud/scheduler"
+	"github.com/pritunl/pritunl-cloud/template"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/vpc"
@@ -23,19 +29,66 @@ import (
 )
 
 type instanceData struct {
-	Id           bson.ObjectId `json:"id"`
-	Zone         bson.ObjectId `json:"zone"`
-	Vpc          bson.ObjectId `json:"vpc"`
-	Node         bson.ObjectId `json:"node"`
-	Image        bson.ObjectId `json:"image"`
-	Domain       bson.ObjectId `json:"domain"`
-	Name         string        `json:"name"`
-	State        string        `json:"state"`
-	InitDiskSize int           `json:"init_disk_size"`
-	Memory       int           `json:"memory"`
-	Processors   int           `json:"processors"`
-	NetworkRoles []string      `json:"network_roles"`
-	Count        int           `json:"count"`
+	Id             bson.ObjectId   `json:"id"`
+	Zone           bson.ObjectId   `json:"zone"`
+	Vpc            bson.ObjectId   `json:"vpc"`
+	SecondaryVpcs  []bson.ObjectId `json:"secondary_vpcs"`
+	Node           bson.ObjectId   `json:"node"`
+	Image          bson.ObjectId   `json:"image"`
+	Domain         bson.ObjectId   `json:"domain"`
+	PlacementGroup bson.ObjectId   `json:"placement_group"`
+	Name           string          `json:"name"`
+	State          string          `json:"state"`
+	InitDiskSize   int             `json:"init_disk_size"`
+	Memory         int             `json:"memory"`
+	Processors     int             `json:"processors"`
+	NetworkRoles   []string        `json:"network_roles"`
+	Vnc            bool            `json:"vnc"`
+	PrivateOnly    bool            `json:"private_only"`
+	AutoRecover    bool            `json:"auto_recover"`
+	UserData       string          `json:"user_data"`
+	DnsServers     []string        `json:"dns_servers"`
+	SearchDomains  []string        `json:"search_domains"`
+	MigrateNode    bson.ObjectId   `json:"migrate_node"`
+	Template       bson.ObjectId   `json:"template"`
+	Scheduler      string          `json:"scheduler"`
+	Count          int             `json:"count"`
+}
+
+func (d *instanceData) applyTemplate(tmpl *template.Template, keys set.Set) {
+	if !keys.Contains("zone") {
+		d.Zone = tmpl.Zone
+	}
+	if !keys.Contains("vpc") {
+		d.Vpc = tmpl.Vpc
+	}
+	if !keys.Contains("node") {
+		d.Node = tmpl.Node
+	}
+	if !keys.Contains("image") {
+		d.Image = tmpl.Image
+	}
+	if !keys.Contains("domain") {
+		d.Domain = tmpl.Domain
+	}
+	if !keys.Contains("init_disk_size") {
+		d.InitDiskSize = tmpl.InitDiskSize
+	}
+	if !keys.Contains("memory") {
+		d.Memory = tmpl.Memory
+	}
+	if !keys.Contains("processors") {
+		d.Processors = tmpl.Processors
+	}
+	if !keys.Contains("network_roles") {
+		d.NetworkRoles = tmpl.NetworkRoles
+	}
+	if !keys.Contains("vnc") {
+		d.Vnc = tmpl.Vnc
+	}
+	if !keys.Contains("user_data") {
+		d.UserData = tmpl.UserData
+	}
 }
 
 type instanceMultiData struct {
@@ -69,6 +122,13 @@ func instancePut(c *gin.Context) {
 		return
 	}
 
+	lockId, err := organization.QuotaLock(db, userOrg)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer organization.QuotaUnlock(db, userOrg, lockId)
+
 	inst, err := instance.GetOrg(db, userOrg, instanceId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -85,6 +145,18 @@ func instancePut(c *gin.Context) {
 		return
 	}
 
+	for _, vpcId := range data.SecondaryVpcs {
+		exists, err := vpc.ExistsOrg(db, userOrg, vpcId)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+		if !exists {
+			utils.AbortWithStatus(c, 405)
+			return
+		}
+	}
+
 	if data.Domain != "" {
 		exists, err := domain.ExistsOrg(db, userOrg, data.Domain)
 		if err != nil {
@@ -97,30 +169,104 @@ func instancePut(c *gin.Context) {
 		}
 	}
 
+	if inst.State == instance.Migrate {
+		errData := &errortypes.ErrorData{
+			Error:   "instance_migrating",
+			Message: "Instance cannot be modified while migrating",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	errData, err := organization.CheckQuota(db, userOrg, &organization.Usage{
+		Processors: utils.Max(data.Processors, 1) - inst.Processors,
+		Memory:     utils.Max(data.Memory, 256) - inst.Memory,
+	})
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
 	inst.PreCommit()
 
 	inst.Name = data.Name
+	inst.PlacementGroup = data.PlacementGroup
 	inst.Vpc = data.Vpc
-	if data.State != "" {
+	inst.SecondaryVpcs = data.SecondaryVpcs
+	if data.State == instance.Migrate {
+		errData, err := inst.SetMigrate(db, data.MigrateNode)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if errData != nil {
+			c.JSON(400, errData)
+			return
+		}
+	} else if data.State != "" {
 		inst.State = data.State
 	}
 	inst.Memory = data.Memory
 	inst.Processors = data.Processors
 	inst.NetworkRoles = data.NetworkRoles
+	inst.Vnc = data.Vnc
+	inst.PrivateOnly = data.PrivateOnly
+	inst.AutoRecover = data.AutoRecover
+	inst.UserData = data.UserData
+	inst.DnsServers = data.DnsServers
+	inst.SearchDomains = data.SearchDomains
 	inst.Domain = data.Domain
 
 	fields := set.NewSet(
 		"name",
 		"vpc",
+		"secondary_vpcs",
 		"state",
 		"restart",
 		"memory",
 		"processors",
 		"network_roles",
+		"vnc",
+		"private_only",
+		"auto_recover",
+		"user_data",
+		"dns_servers",
+		"search_domains",
 		"domain",
+		"placement_group",
+		"migrate_node",
+		"migrate_state",
+		"migrate_addr",
+		"migrate_nbd_addr",
+		"migrate_tls",
+		"migrate_disks",
 	)
 
-	errData, err := inst.Validate(db)
+	errData, err = inst.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	resLockId, err := zone.ReserveLock(db, inst.Zone)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer zone.ReserveUnlock(db, inst.Zone, resLockId)
+
+	errData, err = scheduler.CheckInstance(db, inst)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
@@ -159,12 +305,22 @@ func instancePost(c *gin.Context) {
 		Name: "New Instance",
 	}
 
-	err := c.Bind(data)
+	keys, err := utils.BindKeys(c, data)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	if data.Template != "" {
+		tmpl, err := template.GetOrg(db, userOrg, data.Template)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		data.applyTemplate(tmpl, keys)
+	}
+
 	zne, err := zone.Get(db, data.Zone)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -181,15 +337,17 @@ func instancePost(c *gin.Context) {
 		return
 	}
 
-	nde, err := node.Get(db, data.Node)
-	if err != nil {
-		utils.AbortWithError(c, 500, err)
-		return
-	}
+	if data.Node != "" {
+		nde, err := node.Get(db, data.Node)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
 
-	if nde.Zone != zne.Id {
-		utils.AbortWithStatus(c, 405)
-		return
+		if nde.Zone != zne.Id {
+			utils.AbortWithStatus(c, 405)
+			return
+		}
 	}
 
 	exists, err = vpc.ExistsOrg(db, userOrg, data.Vpc)
@@ -202,6 +360,18 @@ func instancePost(c *gin.Context) {
 		return
 	}
 
+	for _, vpcId := range data.SecondaryVpcs {
+		exists, err := vpc.ExistsOrg(db, userOrg, vpcId)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+		if !exists {
+			utils.AbortWithStatus(c, 405)
+			return
+		}
+	}
+
 	if data.Domain != "" {
 		exists, err := domain.ExistsOrg(db, userOrg, data.Domain)
 		if err != nil {
@@ -230,6 +400,64 @@ func instancePost(c *gin.Context) {
 		data.Count = 1
 	}
 
+	lockId, err := organization.QuotaLock(db, userOrg)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer organization.QuotaUnlock(db, userOrg, lockId)
+
+	errData, err := organization.CheckQuota(db, userOrg, &organization.Usage{
+		Instances:  data.Count,
+		Processors: data.Count * utils.Max(data.Processors, 1),
+		Memory:     data.Count * utils.Max(data.Memory, 256),
+		Disk:       data.Count * data.InitDiskSize,
+	})
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	resLockId, err := zone.ReserveLock(db, data.Zone)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer zone.ReserveUnlock(db, data.Zone, resLockId)
+
+	var sch *scheduler.Scheduler
+	if data.Node == "" {
+		schr, errData, err := scheduler.New(db, data.Zone, data.Scheduler)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if errData != nil {
+			c.JSON(400, errData)
+			return
+		}
+
+		sch = schr
+	}
+
+	// Remove the created instances if any replica fails
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+
+		for _, inst := range insts {
+			instance.Remove(db, inst.Id)
+		}
+	}()
+
 	for i := 0; i < data.Count; i++ {
 		name := ""
 		if strings.Contains(data.Name, "%") {
@@ -239,18 +467,53 @@ func instancePost(c *gin.Context) {
 		}
 
 		inst := &instance.Instance{
-			State:        data.State,
-			Organization: userOrg,
-			Zone:         data.Zone,
-			Vpc:          data.Vpc,
-			Node:         data.Node,
-			Image:        data.Image,
-			Name:         name,
-			InitDiskSize: data.InitDiskSize,
-			Memory:       data.Memory,
-			Processors:   data.Processors,
-			NetworkRoles: data.NetworkRoles,
-			Domain:       data.Domain,
+			State:          data.State,
+			Organization:   userOrg,
+			Zone:           data.Zone,
+			Vpc:            data.Vpc,
+			SecondaryVpcs:  data.SecondaryVpcs,
+			Node:           data.Node,
+			Image:          data.Image,
+			Name:           name,
+			InitDiskSize:   data.InitDiskSize,
+			Memory:         data.Memory,
+			Processors:     data.Processors,
+			NetworkRoles:   data.NetworkRoles,
+			Vnc:            data.Vnc,
+			PrivateOnly:    data.PrivateOnly,
+			AutoRecover:    data.AutoRecover,
+			UserData:       data.UserData,
+			DnsServers:     data.DnsServers,
+			SearchDomains:  data.SearchDomains,
+			Domain:         data.Domain,
+			PlacementGroup: data.PlacementGroup,
+		}
+
+		if sch != nil {
+			if data.PlacementGroup != "" {
+				filter, errData, err := placement.GetFilter(
+					db, userOrg, data.PlacementGroup)
+				if err != nil {
+					utils.AbortWithError(c, 500, err)
+					return
+				}
+
+				if errData != nil {
+					c.JSON(400, errData)
+					return
+				}
+
+				sch.Filter = filter
+			}
+
+			inst.DefaultResources()
+
+			nde, errData := sch.Schedule(inst.Processors, inst.Memory)
+			if errData != nil {
+				c.JSON(400, errData)
+				return
+			}
+			inst.Node = nde.Id
 		}
 
 		errData, err := inst.Validate(db)
@@ -264,6 +527,19 @@ func instancePost(c *gin.Context) {
 			return
 		}
 
+		if sch == nil {
+			errData, err = scheduler.CheckInstance(db, inst)
+			if err != nil {
+				utils.AbortWithError(c, 500, err)
+				return
+			}
+
+			if errData != nil {
+				c.JSON(400, errData)
+				return
+			}
+		}
+
 		err = inst.Insert(db)
 		if err != nil {
 			utils.AbortWithError(c, 500, err)
@@ -272,6 +548,7 @@ func instancePost(c *gin.Context) {
 
 		insts = append(insts, inst)
 	}
+	committed = true
 
 	event.PublishDispatch(db, "instance.change")
 
@@ -297,6 +574,15 @@ func instancesPut(c *gin.Context) {
 		return
 	}
 
+	if data.State == instance.Migrate {
+		errData := &errortypes.ErrorData{
+			Error:   "invalid_state",
+			Message: "Migration must be started per instance",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
 	doc := bson.M{
 		"state": data.State,
 	}
@@ -394,6 +680,12 @@ func instanceGet(c *gin.Context) {
 		return
 	}
 
+	err = inst.LoadPlacementViolation(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	if demo.IsDemo() {
 		inst.State = instance.Start
 		inst.VmState = vm.Running
@@ -409,6 +701,47 @@ func instanceGet(c *gin.Context) {
 	c.JSON(200, inst)
 }
 
+func instanceConsoleGet(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(bson.ObjectId)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	typ := c.Query("type")
+	if typ == "" {
+		typ = console.Serial
+	}
+
+	inst, err := instance.GetOrg(db, userOrg, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if inst.VmState != vm.Running {
+		errData := &errortypes.ErrorData{
+			Error:   "instance_not_running",
+			Message: "Instance must be running to open console",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = console.Proxy(db, c.Writer, c.Request, inst, typ)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+}
+
 func instancesGet(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 	userOrg := c.MustGet("organization").(bson.ObjectId)
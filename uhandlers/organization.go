@@ -6,8 +6,14 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/organization"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"gopkg.in/mgo.v2/bson"
 )
 
+type organizationUsageData struct {
+	Quota *organization.Quota `json:"quota"`
+	Usage *organization.Usage `json:"usage"`
+}
+
 func organizationsGet(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
@@ -26,3 +32,25 @@ func organizationsGet(c *gin.Context) {
 
 	c.JSON(200, orgs)
 }
+
+func organizationUsageGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(bson.ObjectId)
+
+	org, err := organization.Get(db, userOrg)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usage, err := organization.GetUsage(db, userOrg)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, &organizationUsageData{
+		Quota: org.Quota,
+		Usage: usage,
+	})
+}
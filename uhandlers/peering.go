@@ -0,0 +1,234 @@
+package uhandlers
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type peeringData struct {
+	Id           bson.ObjectId `json:"id"`
+	Name         string        `json:"name"`
+	Vpc          bson.ObjectId `json:"vpc"`
+	PeerVpc      bson.ObjectId `json:"peer_vpc"`
+	Accepted     bool          `json:"accepted"`
+	PeerAccepted bool          `json:"peer_accepted"`
+}
+
+func peeringPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(bson.ObjectId)
+	data := &peeringData{}
+
+	peeringId, ok := utils.ParseObjectId(c.Param("peering_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	peer, err := vpc.GetPeeringOrg(db, userOrg, peeringId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	fields := set.NewSet(
+		"organization",
+		"peer_organization",
+		"active",
+	)
+
+	// Each side of the peering can only accept for its own organization
+	if peer.Organization == userOrg {
+		peer.Name = data.Name
+		peer.Accepted = data.Accepted
+		fields.Add("name")
+		fields.Add("accepted")
+	}
+	if peer.PeerOrganization == userOrg {
+		peer.PeerAccepted = data.PeerAccepted
+		fields.Add("peer_accepted")
+	}
+
+	errData, err := peer.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = peer.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "vpc.change")
+
+	c.JSON(200, peer)
+}
+
+func peeringPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(bson.ObjectId)
+	data := &peeringData{
+		Name: "New Peering",
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	exists, err := vpc.ExistsOrg(db, userOrg, data.Vpc)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	if !exists {
+		utils.AbortWithStatus(c, 405)
+		return
+	}
+
+	peer := &vpc.Peering{
+		Name:     data.Name,
+		Vpc:      data.Vpc,
+		PeerVpc:  data.PeerVpc,
+		Accepted: true,
+	}
+
+	errData, err := peer.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	// Peering within an organization is accepted by the requester
+	if peer.PeerOrganization == userOrg {
+		peer.PeerAccepted = true
+		peer.Active = true
+	}
+
+	err = peer.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "vpc.change")
+
+	c.JSON(200, peer)
+}
+
+func peeringDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(bson.ObjectId)
+
+	peeringId, ok := utils.ParseObjectId(c.Param("peering_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := vpc.RemovePeeringOrg(db, userOrg, peeringId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "vpc.change")
+
+	c.JSON(200, nil)
+}
+
+func peeringGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(bson.ObjectId)
+
+	peeringId, ok := utils.ParseObjectId(c.Param("peering_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	peer, err := vpc.GetPeeringOrg(db, userOrg, peeringId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, peer)
+}
+
+func peeringsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(bson.ObjectId)
+
+	query := bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"organization": userOrg,
+			},
+			&bson.M{
+				"peer_organization": userOrg,
+			},
+		},
+	}
+
+	vpcId, ok := utils.ParseObjectId(c.Query("vpc"))
+	if ok {
+		query["$and"] = []*bson.M{
+			&bson.M{
+				"$or": []*bson.M{
+					&bson.M{
+						"vpc": vpcId,
+					},
+					&bson.M{
+						"peer_vpc": vpcId,
+					},
+				},
+			},
+		}
+	}
+
+	peers, err := vpc.GetPeeringAll(db, &query)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, peers)
+}
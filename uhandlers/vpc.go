@@ -8,6 +8,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/datacenter"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/organization"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vpc"
 	"gopkg.in/mgo.v2/bson"
@@ -16,12 +17,16 @@ import (
 )
 
 type vpcData struct {
-	Id         bson.ObjectId `json:"id"`
-	Name       string        `json:"name"`
-	Network    string        `json:"network"`
-	Datacenter bson.ObjectId `json:"datacenter"`
-	Routes     []*vpc.Route  `json:"routes"`
-	LinkUris   []string      `json:"link_uris"`
+	Id            bson.ObjectId   `json:"id"`
+	Name          string          `json:"name"`
+	Network       string          `json:"network"`
+	Datacenter    bson.ObjectId   `json:"datacenter"`
+	Routes        []*vpc.Route    `json:"routes"`
+	LinkUris      []string        `json:"link_uris"`
+	LinkConfig    *vpc.LinkConfig `json:"link_config"`
+	DnsServers    []string        `json:"dns_servers"`
+	SearchDomains []string        `json:"search_domains"`
+	DnsResolver   bool            `json:"dns_resolver"`
 }
 
 type vpcsData struct {
@@ -29,6 +34,11 @@ type vpcsData struct {
 	Count int        `json:"count"`
 }
 
+type linkHistoryData struct {
+	History []*vpc.LinkHistory `json:"history"`
+	Count   int                `json:"count"`
+}
+
 func vpcPut(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -74,12 +84,28 @@ func vpcPut(c *gin.Context) {
 	vc.Name = data.Name
 	vc.Routes = data.Routes
 	vc.LinkUris = data.LinkUris
+	if data.LinkConfig != nil {
+		// Link certificates can only be set by an administrator
+		if vc.LinkConfig != nil {
+			data.LinkConfig.Certificate = vc.LinkConfig.Certificate
+		} else {
+			data.LinkConfig.Certificate = ""
+		}
+		vc.LinkConfig = data.LinkConfig
+	}
+	vc.DnsServers = data.DnsServers
+	vc.SearchDomains = data.SearchDomains
+	vc.DnsResolver = data.DnsResolver
 
 	fields := set.NewSet(
 		"state",
 		"name",
 		"routes",
 		"link_uris",
+		"link_config",
+		"dns_servers",
+		"search_domains",
+		"dns_resolver",
 	)
 
 	errData, err := vc.Validate(db)
@@ -133,18 +159,46 @@ func vpcPost(c *gin.Context) {
 		return
 	}
 
+	if data.LinkConfig != nil {
+		data.LinkConfig.Certificate = ""
+	}
+
 	vc := &vpc.Vpc{
-		Name:         data.Name,
-		Network:      data.Network,
-		Organization: userOrg,
-		Datacenter:   data.Datacenter,
-		Routes:       data.Routes,
-		LinkUris:     data.LinkUris,
+		Name:          data.Name,
+		Network:       data.Network,
+		Organization:  userOrg,
+		Datacenter:    data.Datacenter,
+		Routes:        data.Routes,
+		LinkUris:      data.LinkUris,
+		LinkConfig:    data.LinkConfig,
+		DnsServers:    data.DnsServers,
+		SearchDomains: data.SearchDomains,
+		DnsResolver:   data.DnsResolver,
+	}
+
+	lockId, err := organization.QuotaLock(db, userOrg)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer organization.QuotaUnlock(db, userOrg, lockId)
+
+	errData, err := organization.CheckQuota(db, userOrg, &organization.Usage{
+		Vpcs: 1,
+	})
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
 	}
 
 	vc.GenerateVpcId()
 
-	errData, err := vc.Validate(db)
+	errData, err = vc.Validate(db)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
@@ -327,3 +381,45 @@ func vpcsGet(c *gin.Context) {
 		c.JSON(200, data)
 	}
 }
+
+func vpcLinkHistoryGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(bson.ObjectId)
+
+	vpcId, ok := utils.ParseObjectId(c.Param("vpc_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageCount, _ := strconv.Atoi(c.Query("page_count"))
+
+	query := bson.M{
+		"vpc":          vpcId,
+		"organization": userOrg,
+	}
+
+	lnk := strings.TrimSpace(c.Query("link"))
+	if lnk != "" {
+		query["link"] = lnk
+	}
+
+	status := strings.TrimSpace(c.Query("status"))
+	if status != "" {
+		query["status"] = status
+	}
+
+	hists, count, err := vpc.GetLinkHistoryPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &linkHistoryData{
+		History: hists,
+		Count:   count,
+	}
+
+	c.JSON(200, data)
+}
@@ -1,12 +1,18 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/render"
+	"github.com/pritunl/pritunl-cloud/errortypes"
 	"gopkg.in/mgo.v2/bson"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strings"
 )
@@ -107,6 +113,39 @@ func AbortWithError(c *gin.Context, code int, err error) {
 	c.Error(err)
 }
 
+func BindKeys(c *gin.Context, data interface{}) (keys set.Set, err error) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "utils: Failed to read request body"),
+		}
+		return
+	}
+
+	c.Request.Body = NopCloser{bytes.NewReader(body)}
+
+	err = c.Bind(data)
+	if err != nil {
+		return
+	}
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(body, &fields)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "utils: Failed to parse request body"),
+		}
+		return
+	}
+
+	keys = set.NewSet()
+	for key := range fields {
+		keys.Add(key)
+	}
+
+	return
+}
+
 func WriteStatus(w http.ResponseWriter, code int) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
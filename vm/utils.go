@@ -132,3 +132,7 @@ func GetLinkNamespace(id bson.ObjectId, n int) string {
 	hashSum := base32.StdEncoding.EncodeToString(hash.Sum(nil))[:12]
 	return fmt.Sprintf("x%s%d", strings.ToLower(hashSum), n)
 }
+
+func GetIfacePeer(vlan int) string {
+	return fmt.Sprintf("peer%d", vlan)
+}
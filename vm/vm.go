@@ -2,6 +2,7 @@ package vm
 
 import (
 	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/node"
 	"gopkg.in/mgo.v2/bson"
 	"path"
 	"strings"
@@ -13,6 +14,9 @@ type VirtualMachine struct {
 	Image           bson.ObjectId     `json:"image"`
 	Processors      int               `json:"processors"`
 	Memory          int               `json:"memory"`
+	Vnc             bool              `json:"vnc"`
+	PrivateOnly     bool              `json:"private_only"`
+	FloatingIp      *FloatingIp       `json:"floating_ip,omitempty"`
 	Disks           []*Disk           `json:"disks"`
 	NetworkAdapters []*NetworkAdapter `json:"network_adapters"`
 }
@@ -30,6 +34,20 @@ func (d *Disk) GetId() bson.ObjectId {
 	return ""
 }
 
+type FloatingIp struct {
+	Address  string `json:"address"`
+	Gateway  string `json:"gateway"`
+	Address6 string `json:"address6,omitempty"`
+	Gateway6 string `json:"gateway6,omitempty"`
+}
+
+func (f *FloatingIp) Equal(other *FloatingIp) bool {
+	if f == nil || other == nil {
+		return f == other
+	}
+	return *f == *other
+}
+
 type NetworkAdapter struct {
 	Type       string        `json:"type"`
 	MacAddress string        `json:"mac_address"`
@@ -53,7 +71,10 @@ func (v *VirtualMachine) Commit(db *database.Database) (err error) {
 		}
 	}
 
-	err = coll.UpdateId(v.Id, &bson.M{
+	err = coll.Update(&bson.M{
+		"_id":  v.Id,
+		"node": node.Self.Id,
+	}, &bson.M{
 		"$set": &bson.M{
 			"vm_state":    v.State,
 			"public_ips":  addrs,
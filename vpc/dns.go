@@ -0,0 +1,69 @@
+package vpc
+
+import (
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var (
+	DefaultDnsServers = []string{
+		"8.8.8.8",
+		"8.8.4.4",
+	}
+	searchDomainReg = regexp.MustCompile(
+		`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*` +
+			`[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+func FormatDnsServers(servers []string) (
+	formatted []string, errData *errortypes.ErrorData) {
+
+	formatted = []string{}
+
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+
+		ip := net.ParseIP(server)
+		if ip == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "dns_server_invalid",
+				Message: "DNS server address invalid",
+			}
+			return
+		}
+
+		formatted = append(formatted, ip.String())
+	}
+
+	return
+}
+
+func FormatSearchDomains(domains []string) (
+	formatted []string, errData *errortypes.ErrorData) {
+
+	formatted = []string{}
+
+	for _, domain := range domains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" {
+			continue
+		}
+
+		if len(domain) > 253 || !searchDomainReg.MatchString(domain) {
+			errData = &errortypes.ErrorData{
+				Error:   "search_domain_invalid",
+				Message: "DNS search domain invalid",
+			}
+			return
+		}
+
+		formatted = append(formatted, domain)
+	}
+
+	return
+}
@@ -0,0 +1,80 @@
+package vpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatDnsServers(t *testing.T) {
+	tests := []struct {
+		servers   []string
+		formatted []string
+		err       string
+	}{
+		{nil, []string{}, ""},
+		{[]string{"8.8.8.8"}, []string{"8.8.8.8"}, ""},
+		{[]string{" 1.1.1.1 ", ""}, []string{"1.1.1.1"}, ""},
+		{[]string{"2001:4860:4860:0:0:0:0:8888"},
+			[]string{"2001:4860:4860::8888"}, ""},
+		{[]string{"8.8.8.8", "dns.example.com"}, nil, "dns_server_invalid"},
+		{[]string{"256.1.1.1"}, nil, "dns_server_invalid"},
+	}
+
+	for _, test := range tests {
+		formatted, errData := FormatDnsServers(test.servers)
+		if test.err != "" {
+			if errData == nil || errData.Error != test.err {
+				t.Errorf("FormatDnsServers(%v) error = %v, want %s",
+					test.servers, errData, test.err)
+			}
+			continue
+		}
+
+		if errData != nil {
+			t.Errorf("FormatDnsServers(%v) unexpected error %s",
+				test.servers, errData.Error)
+			continue
+		}
+
+		if !reflect.DeepEqual(formatted, test.formatted) {
+			t.Errorf("FormatDnsServers(%v) = %v, want %v",
+				test.servers, formatted, test.formatted)
+		}
+	}
+}
+
+func TestFormatSearchDomains(t *testing.T) {
+	tests := []struct {
+		domains   []string
+		formatted []string
+		err       string
+	}{
+		{nil, []string{}, ""},
+		{[]string{"Example.COM."}, []string{"example.com"}, ""},
+		{[]string{" ", "corp.local"}, []string{"corp.local"}, ""},
+		{[]string{"bad_domain.com"}, nil, "search_domain_invalid"},
+		{[]string{"-bad.com"}, nil, "search_domain_invalid"},
+	}
+
+	for _, test := range tests {
+		formatted, errData := FormatSearchDomains(test.domains)
+		if test.err != "" {
+			if errData == nil || errData.Error != test.err {
+				t.Errorf("FormatSearchDomains(%v) error = %v, want %s",
+					test.domains, errData, test.err)
+			}
+			continue
+		}
+
+		if errData != nil {
+			t.Errorf("FormatSearchDomains(%v) unexpected error %s",
+				test.domains, errData.Error)
+			continue
+		}
+
+		if !reflect.DeepEqual(formatted, test.formatted) {
+			t.Errorf("FormatSearchDomains(%v) = %v, want %v",
+				test.domains, formatted, test.formatted)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package vpc
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+type LinkHistory struct {
+	Id             bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Vpc            bson.ObjectId `bson:"vpc" json:"vpc"`
+	Organization   bson.ObjectId `bson:"organization" json:"organization"`
+	Node           bson.ObjectId `bson:"node" json:"node"`
+	Link           string        `bson:"link" json:"link"`
+	Status         string        `bson:"status" json:"status"`
+	PreviousStatus string        `bson:"previous_status" json:"previous_status"`
+	Errors         []string      `bson:"errors" json:"errors"`
+	Timestamp      time.Time     `bson:"timestamp" json:"timestamp"`
+}
+
+func (h *LinkHistory) Insert(db *database.Database) (err error) {
+	coll := db.VpcsLinkHistory()
+
+	if h.Id != "" {
+		err = &errortypes.DatabaseError{
+			errors.New("vpc: Link history already exists"),
+		}
+		return
+	}
+
+	h.Id = bson.NewObjectId()
+
+	if h.Errors == nil {
+		h.Errors = []string{}
+	}
+
+	if h.Timestamp.IsZero() {
+		h.Timestamp = time.Now()
+	}
+
+	err = coll.Insert(h)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetLinkHistoryPaged(db *database.Database, query *bson.M,
+	page, pageCount int) (hists []*LinkHistory, count int, err error) {
+
+	coll := db.VpcsLinkHistory()
+	hists = []*LinkHistory{}
+
+	qury := coll.Find(query)
+
+	count, err = qury.Count()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	skip := utils.Min(page*pageCount, utils.Max(0, count-pageCount))
+
+	cursor := qury.Sort("-timestamp").Skip(skip).Limit(pageCount).Iter()
+
+	hist := &LinkHistory{}
+	for cursor.Next(hist) {
+		hists = append(hists, hist)
+		hist = &LinkHistory{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func removeLinkHistory(db *database.Database, vcIds []bson.ObjectId) (
+	err error) {
+
+	coll := db.VpcsLinkHistory()
+
+	_, err = coll.RemoveAll(&bson.M{
+		"vpc": &bson.M{
+			"$in": vcIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,287 @@
+package vpc
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"gopkg.in/mgo.v2/bson"
+	"regexp"
+	"strings"
+)
+
+const (
+	IkeV1 = "ikev1"
+	IkeV2 = "ikev2"
+
+	PskAuth         = "psk"
+	CertificateAuth = "certificate"
+
+	DpdRestart = "restart"
+	DpdClear   = "clear"
+	DpdHold    = "hold"
+	DpdNone    = "none"
+)
+
+var (
+	proposalReg = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*!?$`)
+	remoteIdReg = regexp.MustCompile(`^[a-zA-Z0-9.:@=,_ -]+$`)
+)
+
+type LinkConfig struct {
+	IkeVersion   string        `bson:"ike_version" json:"ike_version"`
+	IkeProposals []string      `bson:"ike_proposals" json:"ike_proposals"`
+	EspProposals []string      `bson:"esp_proposals" json:"esp_proposals"`
+	IkeLifetime  int           `bson:"ike_lifetime" json:"ike_lifetime"`
+	Lifetime     int           `bson:"lifetime" json:"lifetime"`
+	DpdDelay     int           `bson:"dpd_delay" json:"dpd_delay"`
+	DpdTimeout   int           `bson:"dpd_timeout" json:"dpd_timeout"`
+	DpdAction    string        `bson:"dpd_action" json:"dpd_action"`
+	AuthType     string        `bson:"auth_type" json:"auth_type"`
+	Certificate  bson.ObjectId `bson:"certificate,omitempty" json:"certificate"`
+	RemoteCa     string        `bson:"remote_ca" json:"remote_ca"`
+	RemoteId     string        `bson:"remote_id" json:"remote_id"`
+}
+
+func formatProposals(proposals []string) (formatted []string, valid bool) {
+	formatted = []string{}
+
+	for _, proposal := range proposals {
+		proposal = strings.ToLower(strings.TrimSpace(proposal))
+		if proposal == "" {
+			continue
+		}
+
+		if !proposalReg.MatchString(proposal) {
+			return
+		}
+
+		formatted = append(formatted, proposal)
+	}
+
+	valid = true
+	return
+}
+
+func (l *LinkConfig) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	switch l.IkeVersion {
+	case IkeV1, IkeV2:
+		break
+	case "":
+		l.IkeVersion = IkeV2
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "link_ike_version_invalid",
+			Message: "Link IKE version invalid",
+		}
+		return
+	}
+
+	ikeProposals, valid := formatProposals(l.IkeProposals)
+	if !valid {
+		errData = &errortypes.ErrorData{
+			Error:   "link_ike_proposals_invalid",
+			Message: "Link IKE proposals invalid",
+		}
+		return
+	}
+	l.IkeProposals = ikeProposals
+
+	espProposals, valid := formatProposals(l.EspProposals)
+	if !valid {
+		errData = &errortypes.ErrorData{
+			Error:   "link_esp_proposals_invalid",
+			Message: "Link ESP proposals invalid",
+		}
+		return
+	}
+	l.EspProposals = espProposals
+
+	if l.IkeLifetime == 0 {
+		l.IkeLifetime = 28800
+	}
+	if l.Lifetime == 0 {
+		l.Lifetime = 3600
+	}
+	if l.IkeLifetime < 0 || l.Lifetime < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "link_lifetime_invalid",
+			Message: "Link lifetime invalid",
+		}
+		return
+	}
+
+	switch l.DpdAction {
+	case DpdRestart, DpdClear, DpdHold, DpdNone:
+		break
+	case "":
+		l.DpdAction = DpdRestart
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "link_dpd_action_invalid",
+			Message: "Link DPD action invalid",
+		}
+		return
+	}
+
+	if l.DpdDelay == 0 {
+		l.DpdDelay = 5
+	}
+	if l.DpdTimeout == 0 {
+		l.DpdTimeout = 20
+	}
+	if l.DpdDelay < 0 || l.DpdTimeout < l.DpdDelay {
+		errData = &errortypes.ErrorData{
+			Error:   "link_dpd_invalid",
+			Message: "Link DPD timeout must be greater than delay",
+		}
+		return
+	}
+
+	l.RemoteId = strings.TrimSpace(l.RemoteId)
+	if l.RemoteId != "" && !remoteIdReg.MatchString(l.RemoteId) {
+		errData = &errortypes.ErrorData{
+			Error:   "link_remote_id_invalid",
+			Message: "Link remote ID invalid",
+		}
+		return
+	}
+
+	switch l.AuthType {
+	case PskAuth, "":
+		l.AuthType = PskAuth
+		l.Certificate = ""
+		l.RemoteCa = ""
+		break
+	case CertificateAuth:
+		if l.Certificate == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "link_certificate_required",
+				Message: "Missing required link certificate",
+			}
+			return
+		}
+
+		cert, e := certificate.Get(db, l.Certificate)
+		if e != nil {
+			err = e
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = nil
+				errData = &errortypes.ErrorData{
+					Error:   "link_certificate_not_found",
+					Message: "Link certificate does not exist",
+				}
+			}
+			return
+		}
+
+		if cert.Key == "" || cert.Certificate == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "link_certificate_invalid",
+				Message: "Link certificate missing key or certificate",
+			}
+			return
+		}
+
+		l.RemoteCa = strings.TrimSpace(l.RemoteCa)
+		if l.RemoteCa == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "link_remote_ca_required",
+				Message: "Missing required link remote CA",
+			}
+			return
+		}
+
+		block, _ := pem.Decode([]byte(l.RemoteCa))
+		if block == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "link_remote_ca_invalid",
+				Message: "Link remote CA invalid",
+			}
+			return
+		}
+
+		_, e = x509.ParseCertificate(block.Bytes)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "link_remote_ca_invalid",
+				Message: "Link remote CA invalid",
+			}
+			return
+		}
+
+		if l.RemoteId == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "link_remote_id_required",
+				Message: "Missing required link remote ID",
+			}
+			return
+		}
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "link_auth_type_invalid",
+			Message: "Link authentication type invalid",
+		}
+		return
+	}
+
+	return
+}
+
+func (l *LinkConfig) RekeyMargin() int {
+	lifetime := l.Lifetime
+	if l.IkeLifetime < lifetime {
+		lifetime = l.IkeLifetime
+	}
+
+	margin := 540
+	if lifetime/3 < margin {
+		margin = lifetime / 3
+	}
+
+	return margin
+}
+
+func (l *LinkConfig) Hash() string {
+	return fmt.Sprintf("%s:%s:%s:%d:%d:%d:%d:%s:%s:%s:%s:%s",
+		l.IkeVersion,
+		strings.Join(l.IkeProposals, ","),
+		strings.Join(l.EspProposals, ","),
+		l.IkeLifetime,
+		l.Lifetime,
+		l.DpdDelay,
+		l.DpdTimeout,
+		l.DpdAction,
+		l.AuthType,
+		l.Certificate.Hex(),
+		l.RemoteCa,
+		l.RemoteId,
+	)
+}
+
+func (v *Vpc) GetLinkConfig() (conf *LinkConfig) {
+	if v.LinkConfig != nil {
+		conf = v.LinkConfig
+		return
+	}
+
+	conf = &LinkConfig{
+		IkeVersion:   IkeV2,
+		IkeProposals: []string{},
+		EspProposals: []string{},
+		IkeLifetime:  28800,
+		Lifetime:     3600,
+		DpdDelay:     5,
+		DpdTimeout:   20,
+		DpdAction:    DpdRestart,
+		AuthType:     PskAuth,
+	}
+	return
+}
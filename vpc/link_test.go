@@ -0,0 +1,157 @@
+package vpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLinkConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		conf *LinkConfig
+		want *LinkConfig
+		err  string
+	}{
+		{
+			name: "defaults",
+			conf: &LinkConfig{},
+			want: &LinkConfig{
+				IkeVersion:   IkeV2,
+				IkeProposals: []string{},
+				EspProposals: []string{},
+				IkeLifetime:  28800,
+				Lifetime:     3600,
+				DpdDelay:     5,
+				DpdTimeout:   20,
+				DpdAction:    DpdRestart,
+				AuthType:     PskAuth,
+			},
+		},
+		{
+			name: "psk_clears_certificate",
+			conf: &LinkConfig{
+				IkeVersion:   IkeV1,
+				IkeProposals: []string{" AES256-SHA256-MODP2048 ", ""},
+				EspProposals: []string{"aes128gcm16!"},
+				IkeLifetime:  3600,
+				Lifetime:     1800,
+				DpdDelay:     10,
+				DpdTimeout:   30,
+				DpdAction:    DpdClear,
+				AuthType:     PskAuth,
+				Certificate:  "aaaaaaaaaaaa",
+				RemoteCa:     "ca",
+				RemoteId:     " vpn.example.com ",
+			},
+			want: &LinkConfig{
+				IkeVersion:   IkeV1,
+				IkeProposals: []string{"aes256-sha256-modp2048"},
+				EspProposals: []string{"aes128gcm16!"},
+				IkeLifetime:  3600,
+				Lifetime:     1800,
+				DpdDelay:     10,
+				DpdTimeout:   30,
+				DpdAction:    DpdClear,
+				AuthType:     PskAuth,
+				RemoteId:     "vpn.example.com",
+			},
+		},
+		{
+			name: "ike_version",
+			conf: &LinkConfig{IkeVersion: "ikev3"},
+			err:  "link_ike_version_invalid",
+		},
+		{
+			name: "ike_proposals",
+			conf: &LinkConfig{IkeProposals: []string{"aes256;sha1"}},
+			err:  "link_ike_proposals_invalid",
+		},
+		{
+			name: "esp_proposals",
+			conf: &LinkConfig{EspProposals: []string{"aes256--sha1"}},
+			err:  "link_esp_proposals_invalid",
+		},
+		{
+			name: "lifetime",
+			conf: &LinkConfig{Lifetime: -1},
+			err:  "link_lifetime_invalid",
+		},
+		{
+			name: "dpd_action",
+			conf: &LinkConfig{DpdAction: "reset"},
+			err:  "link_dpd_action_invalid",
+		},
+		{
+			name: "dpd_timeout",
+			conf: &LinkConfig{DpdDelay: 30, DpdTimeout: 10},
+			err:  "link_dpd_invalid",
+		},
+		{
+			name: "remote_id",
+			conf: &LinkConfig{RemoteId: "vpn\"example"},
+			err:  "link_remote_id_invalid",
+		},
+		{
+			name: "certificate_required",
+			conf: &LinkConfig{AuthType: CertificateAuth},
+			err:  "link_certificate_required",
+		},
+		{
+			name: "auth_type",
+			conf: &LinkConfig{AuthType: "rsa"},
+			err:  "link_auth_type_invalid",
+		},
+	}
+
+	for _, test := range tests {
+		errData, err := test.conf.Validate(nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+			continue
+		}
+
+		if test.err != "" {
+			if errData == nil || errData.Error != test.err {
+				t.Errorf("%s: error = %v, want %s",
+					test.name, errData, test.err)
+			}
+			continue
+		}
+
+		if errData != nil {
+			t.Errorf("%s: unexpected error %s", test.name, errData.Error)
+			continue
+		}
+
+		if !reflect.DeepEqual(test.conf, test.want) {
+			t.Errorf("%s: config = %+v, want %+v",
+				test.name, test.conf, test.want)
+		}
+	}
+}
+
+func TestLinkConfigRekeyMargin(t *testing.T) {
+	tests := []struct {
+		ikeLifetime int
+		lifetime    int
+		margin      int
+	}{
+		{28800, 3600, 540},
+		{3600, 28800, 540},
+		{1200, 3600, 400},
+		{3600, 900, 300},
+	}
+
+	for _, test := range tests {
+		conf := &LinkConfig{
+			IkeLifetime: test.ikeLifetime,
+			Lifetime:    test.lifetime,
+		}
+
+		margin := conf.RekeyMargin()
+		if margin != test.margin {
+			t.Errorf("RekeyMargin(%d, %d) = %d, want %d",
+				test.ikeLifetime, test.lifetime, margin, test.margin)
+		}
+	}
+}
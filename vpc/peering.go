@@ -0,0 +1,435 @@
+package vpc
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"gopkg.in/mgo.v2/bson"
+	"net"
+)
+
+type Peering struct {
+	Id               bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Name             string        `bson:"name" json:"name"`
+	Organization     bson.ObjectId `bson:"organization" json:"organization"`
+	Vpc              bson.ObjectId `bson:"vpc" json:"vpc"`
+	PeerOrganization bson.ObjectId `bson:"peer_organization" json:"peer_organization"`
+	PeerVpc          bson.ObjectId `bson:"peer_vpc" json:"peer_vpc"`
+	Accepted         bool          `bson:"accepted" json:"accepted"`
+	PeerAccepted     bool          `bson:"peer_accepted" json:"peer_accepted"`
+	Active           bool          `bson:"active" json:"active"`
+}
+
+func networksOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func getPeerNetworks(vc *Vpc) (network, network6 *net.IPNet, err error) {
+	network, err = vc.GetNetwork()
+	if err != nil {
+		return
+	}
+
+	network6, err = vc.GetNetwork6()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Peering) checkPeers(db *database.Database, target, vc *Vpc) (
+	errData *errortypes.ErrorData, err error) {
+
+	network, network6, err := getPeerNetworks(vc)
+	if err != nil {
+		return
+	}
+
+	peerIds, err := GetPeerIds(db, target.Id, p.Id)
+	if err != nil {
+		return
+	}
+
+	for _, peerId := range peerIds {
+		if peerId == vc.Id {
+			errData = &errortypes.ErrorData{
+				Error:   "peering_exists",
+				Message: "VPCs are already peered",
+			}
+			return
+		}
+
+		peerVc, e := Get(db, peerId)
+		if e != nil {
+			err = e
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = nil
+				continue
+			}
+			return
+		}
+
+		peerNetwork, peerNetwork6, e := getPeerNetworks(peerVc)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if networksOverlap(network, peerNetwork) ||
+			networksOverlap(network6, peerNetwork6) {
+
+			errData = &errortypes.ErrorData{
+				Error:   "peer_network_overlap",
+				Message: "Network overlaps with existing VPC peer",
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (p *Peering) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if p.Vpc == "" || p.PeerVpc == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_required",
+			Message: "Missing required VPC",
+		}
+		return
+	}
+
+	if p.Vpc == p.PeerVpc {
+		errData = &errortypes.ErrorData{
+			Error:   "peer_vpc_invalid",
+			Message: "VPC cannot be peered with itself",
+		}
+		return
+	}
+
+	vc, err := Get(db, p.Vpc)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "vpc_not_found",
+				Message: "VPC does not exist",
+			}
+		}
+		return
+	}
+
+	peerVc, err := Get(db, p.PeerVpc)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "peer_vpc_not_found",
+				Message: "Peer VPC does not exist",
+			}
+		}
+		return
+	}
+
+	p.Organization = vc.Organization
+	p.PeerOrganization = peerVc.Organization
+
+	if vc.Datacenter != peerVc.Datacenter {
+		errData = &errortypes.ErrorData{
+			Error:   "peer_datacenter_invalid",
+			Message: "Peer VPC must be in the same datacenter",
+		}
+		return
+	}
+
+	network, network6, err := getPeerNetworks(vc)
+	if err != nil {
+		return
+	}
+
+	peerNetwork, peerNetwork6, err := getPeerNetworks(peerVc)
+	if err != nil {
+		return
+	}
+
+	if networksOverlap(network, peerNetwork) {
+		errData = &errortypes.ErrorData{
+			Error:   "peer_network_overlap",
+			Message: "Peer VPC network overlaps with VPC network",
+		}
+		return
+	}
+
+	if networksOverlap(network6, peerNetwork6) {
+		errData = &errortypes.ErrorData{
+			Error:   "peer_network6_overlap",
+			Message: "Peer VPC IPv6 network overlaps with VPC network",
+		}
+		return
+	}
+
+	errData, err = p.checkPeers(db, vc, peerVc)
+	if err != nil || errData != nil {
+		return
+	}
+
+	errData, err = p.checkPeers(db, peerVc, vc)
+	if err != nil || errData != nil {
+		return
+	}
+
+	p.Active = p.Accepted && p.PeerAccepted
+
+	return
+}
+
+func (p *Peering) Commit(db *database.Database) (err error) {
+	coll := db.VpcsPeering()
+
+	err = coll.Commit(p.Id, p)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Peering) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.VpcsPeering()
+
+	err = coll.CommitFields(p.Id, p, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Peering) Insert(db *database.Database) (err error) {
+	coll := db.VpcsPeering()
+
+	if p.Id != "" {
+		err = &errortypes.DatabaseError{
+			errors.New("vpc: Peering already exists"),
+		}
+		return
+	}
+
+	p.Id = bson.NewObjectId()
+
+	err = coll.Insert(p)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetPeering(db *database.Database, peerId bson.ObjectId) (
+	peer *Peering, err error) {
+
+	coll := db.VpcsPeering()
+	peer = &Peering{}
+
+	err = coll.FindOneId(peerId, peer)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetPeeringOrg(db *database.Database, orgId, peerId bson.ObjectId) (
+	peer *Peering, err error) {
+
+	coll := db.VpcsPeering()
+	peer = &Peering{}
+
+	err = coll.FindOne(&bson.M{
+		"_id": peerId,
+		"$or": []*bson.M{
+			&bson.M{
+				"organization": orgId,
+			},
+			&bson.M{
+				"peer_organization": orgId,
+			},
+		},
+	}, peer)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetPeeringAll(db *database.Database, query *bson.M) (
+	peers []*Peering, err error) {
+
+	coll := db.VpcsPeering()
+	peers = []*Peering{}
+
+	cursor := coll.Find(query).Sort("name").Iter()
+
+	peer := &Peering{}
+	for cursor.Next(peer) {
+		peers = append(peers, peer)
+		peer = &Peering{}
+	}
+
+	err = cursor.Close()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetPeerIds(db *database.Database, vcId, excludeId bson.ObjectId) (
+	vcIds []bson.ObjectId, err error) {
+
+	query := bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"vpc": vcId,
+			},
+			&bson.M{
+				"peer_vpc": vcId,
+			},
+		},
+	}
+	if excludeId != "" {
+		query["_id"] = &bson.M{
+			"$ne": excludeId,
+		}
+	}
+
+	peers, err := GetPeeringAll(db, &query)
+	if err != nil {
+		return
+	}
+
+	vcIds = []bson.ObjectId{}
+	for _, peer := range peers {
+		if peer.Vpc == vcId {
+			vcIds = append(vcIds, peer.PeerVpc)
+		} else {
+			vcIds = append(vcIds, peer.Vpc)
+		}
+	}
+
+	return
+}
+
+func GetPeersActive(db *database.Database, vcIds []bson.ObjectId) (
+	peersMap map[bson.ObjectId][]bson.ObjectId, err error) {
+
+	peers, err := GetPeeringAll(db, &bson.M{
+		"active": true,
+		"$or": []*bson.M{
+			&bson.M{
+				"vpc": &bson.M{
+					"$in": vcIds,
+				},
+			},
+			&bson.M{
+				"peer_vpc": &bson.M{
+					"$in": vcIds,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	peersMap = map[bson.ObjectId][]bson.ObjectId{}
+	for _, peer := range peers {
+		peersMap[peer.Vpc] = append(peersMap[peer.Vpc], peer.PeerVpc)
+		peersMap[peer.PeerVpc] = append(peersMap[peer.PeerVpc], peer.Vpc)
+	}
+
+	return
+}
+
+func RemovePeering(db *database.Database, peerId bson.ObjectId) (err error) {
+	coll := db.VpcsPeering()
+
+	err = coll.Remove(&bson.M{
+		"_id": peerId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemovePeeringOrg(db *database.Database, orgId, peerId bson.ObjectId) (
+	err error) {
+
+	coll := db.VpcsPeering()
+
+	err = coll.Remove(&bson.M{
+		"_id": peerId,
+		"$or": []*bson.M{
+			&bson.M{
+				"organization": orgId,
+			},
+			&bson.M{
+				"peer_organization": orgId,
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func removePeerings(db *database.Database, vcIds []bson.ObjectId) (
+	err error) {
+
+	coll := db.VpcsPeering()
+
+	_, err = coll.RemoveAll(&bson.M{
+		"$or": []*bson.M{
+			&bson.M{
+				"vpc": &bson.M{
+					"$in": vcIds,
+				},
+			},
+			&bson.M{
+				"peer_vpc": &bson.M{
+					"$in": vcIds,
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
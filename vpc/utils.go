@@ -193,6 +193,16 @@ func DistinctIds(db *database.Database, matchIds []bson.ObjectId) (
 }
 
 func Remove(db *database.Database, vcId bson.ObjectId) (err error) {
+	err = removePeerings(db, []bson.ObjectId{vcId})
+	if err != nil {
+		return
+	}
+
+	err = removeLinkHistory(db, []bson.ObjectId{vcId})
+	if err != nil {
+		return
+	}
+
 	coll := db.VpcsIp()
 
 	_, err = coll.RemoveAll(&bson.M{
@@ -222,6 +232,23 @@ func Remove(db *database.Database, vcId bson.ObjectId) (err error) {
 }
 
 func RemoveOrg(db *database.Database, orgId, vcId bson.ObjectId) (err error) {
+	exists, err := ExistsOrg(db, orgId, vcId)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		err = removePeerings(db, []bson.ObjectId{vcId})
+		if err != nil {
+			return
+		}
+
+		err = removeLinkHistory(db, []bson.ObjectId{vcId})
+		if err != nil {
+			return
+		}
+	}
+
 	coll := db.VpcsIp()
 
 	_, err = coll.RemoveAll(&bson.M{
@@ -252,6 +279,16 @@ func RemoveOrg(db *database.Database, orgId, vcId bson.ObjectId) (err error) {
 }
 
 func RemoveMulti(db *database.Database, vcIds []bson.ObjectId) (err error) {
+	err = removePeerings(db, vcIds)
+	if err != nil {
+		return
+	}
+
+	err = removeLinkHistory(db, vcIds)
+	if err != nil {
+		return
+	}
+
 	coll := db.VpcsIp()
 
 	_, err = coll.RemoveAll(&bson.M{
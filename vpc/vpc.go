@@ -34,6 +34,10 @@ type Vpc struct {
 	Datacenter    bson.ObjectId `bson:"datacenter" json:"datacenter"`
 	Routes        []*Route      `bson:"routes" json:"routes"`
 	LinkUris      []string      `bson:"link_uris" json:"link_uris"`
+	LinkConfig    *LinkConfig   `bson:"link_config" json:"link_config"`
+	DnsServers    []string      `bson:"dns_servers" json:"dns_servers"`
+	SearchDomains []string      `bson:"search_domains" json:"search_domains"`
+	DnsResolver   bool          `bson:"dns_resolver" json:"dns_resolver"`
 	LinkNode      bson.ObjectId `bson:"link_node,omitempty" json:"link_node"`
 	LinkTimestamp time.Time     `bson:"link_timestamp" json:"link_timestamp"`
 }
@@ -85,6 +89,40 @@ func (v *Vpc) Validate(db *database.Database) (
 
 	v.Network = network.String()
 
+	if v.Id != "" {
+		peerIds, e := GetPeerIds(db, v.Id, "")
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, peerId := range peerIds {
+			peerVc, e := Get(db, peerId)
+			if e != nil {
+				err = e
+				if _, ok := err.(*database.NotFoundError); ok {
+					err = nil
+					continue
+				}
+				return
+			}
+
+			peerNetwork, e := peerVc.GetNetwork()
+			if e != nil {
+				err = e
+				return
+			}
+
+			if networksOverlap(network, peerNetwork) {
+				errData = &errortypes.ErrorData{
+					Error:   "peer_network_overlap",
+					Message: "Network overlaps with peered VPC network",
+				}
+				return
+			}
+		}
+	}
+
 	if v.Routes == nil {
 		v.Routes = []*Route{}
 	}
@@ -101,6 +139,25 @@ func (v *Vpc) Validate(db *database.Database) (
 	}
 	v.LinkUris = linkUris
 
+	if v.LinkConfig == nil {
+		v.LinkConfig = &LinkConfig{}
+	}
+
+	errData, err = v.LinkConfig.Validate(db)
+	if err != nil || errData != nil {
+		return
+	}
+
+	v.DnsServers, errData = FormatDnsServers(v.DnsServers)
+	if errData != nil {
+		return
+	}
+
+	v.SearchDomains, errData = FormatSearchDomains(v.SearchDomains)
+	if errData != nil {
+		return
+	}
+
 	destinations := set.NewSet()
 	for _, route := range v.Routes {
 		if destinations.Contains(route.Destination) {
@@ -5,6 +5,7 @@ export const FILTER = 'firewall.filter';
 export const CHANGE = 'firewall.change';
 
 export interface Rule {
+	id?: string;
 	protocol: string;
 	port?: string;
 	source_ips?: string[];
@@ -1,10 +1,15 @@
 package zone
 
 import (
+	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
 	"gopkg.in/mgo.v2/bson"
+	"time"
 )
 
+const reserveLockTimeout = 15 * time.Second
+
 func Get(db *database.Database, zoneId bson.ObjectId) (
 	zne *Zone, err error) {
 
@@ -85,3 +90,67 @@ func Remove(db *database.Database, zoneId bson.ObjectId) (err error) {
 
 	return
 }
+
+func ReserveLock(db *database.Database, zoneId bson.ObjectId) (
+	lockId bson.ObjectId, err error) {
+
+	if zoneId == "" {
+		return
+	}
+
+	coll := db.Zones()
+	lockId = bson.NewObjectId()
+	start := time.Now()
+
+	for {
+		err = coll.Update(&bson.M{
+			"_id": zoneId,
+			"$or": []*bson.M{
+				&bson.M{
+					"reserve_lock": nil,
+				},
+				&bson.M{
+					"reserve_timestamp": &bson.M{
+						"$lt": time.Now().Add(-reserveLockTimeout),
+					},
+				},
+			},
+		}, &bson.M{
+			"$set": &bson.M{
+				"reserve_lock":      lockId,
+				"reserve_timestamp": time.Now(),
+			},
+		})
+		if err == nil {
+			return
+		}
+
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); !ok {
+			return
+		}
+
+		if time.Since(start) > reserveLockTimeout {
+			err = &errortypes.DatabaseError{
+				errors.New("zone: Reserve lock timeout"),
+			}
+			return
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func ReserveUnlock(db *database.Database, zoneId, lockId bson.ObjectId) {
+	coll := db.Zones()
+
+	coll.Update(&bson.M{
+		"_id":          zoneId,
+		"reserve_lock": lockId,
+	}, &bson.M{
+		"$unset": &bson.M{
+			"reserve_lock":      "",
+			"reserve_timestamp": "",
+		},
+	})
+}
@@ -6,12 +6,18 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"gopkg.in/mgo.v2/bson"
+	"time"
 )
 
 type Zone struct {
-	Id         bson.ObjectId `bson:"_id,omitempty" json:"id"`
-	Datacenter bson.ObjectId `bson:"datacenter,omitempty" json:"datacenter"`
-	Name       string        `bson:"name" json:"name"`
+	Id               bson.ObjectId `bson:"_id,omitempty" json:"id"`
+	Datacenter       bson.ObjectId `bson:"datacenter,omitempty" json:"datacenter"`
+	Name             string        `bson:"name" json:"name"`
+	CpuOvercommit    float64       `bson:"cpu_overcommit" json:"cpu_overcommit"`
+	MemoryOvercommit float64       `bson:"memory_overcommit" json:"memory_overcommit"`
+	SharedStorage    bool          `bson:"shared_storage" json:"shared_storage"`
+	ReserveLock      bson.ObjectId `bson:"reserve_lock,omitempty" json:"-"`
+	ReserveTimestamp time.Time     `bson:"reserve_timestamp,omitempty" json:"-"`
 }
 
 func (z *Zone) Validate(db *database.Database) (
@@ -25,6 +31,14 @@ func (z *Zone) Validate(db *database.Database) (
 		return
 	}
 
+	if z.CpuOvercommit < 0 || z.MemoryOvercommit < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "zone_overcommit_invalid",
+			Message: "Zone overcommit ratio cannot be negative",
+		}
+		return
+	}
+
 	return
 }
 